@@ -4,17 +4,22 @@ import (
 	"flag"
 	"log"
 	"os"
+	"time"
 
 	"apiserver/configs"
 	"apiserver/docs"
+	"apiserver/internal/cache"
 	"apiserver/internal/database"
 	"apiserver/internal/middleware"
 	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/approval"
 	"apiserver/internal/modules/audit"
+	"apiserver/internal/modules/auth"
 	"apiserver/internal/modules/group"
 	"apiserver/internal/modules/permission"
 	"apiserver/internal/modules/configuration"
 	"apiserver/internal/modules/example"
+	"apiserver/internal/types"
 	"apiserver/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -43,6 +48,10 @@ func main() {
 	// Load configuration
 	config := configs.LoadConfig()
 
+	// Configure API key hashing before any key is stored or looked up
+	utils.SetAPIKeyPepper(config.APIKeyPepper)
+	utils.SetSecretEncryptionKey(config.SigningSecretKey)
+
 	// Initialize docs
 	docs.SwaggerInfo.Title = config.APIName
 	docs.SwaggerInfo.Description = config.APIDescription
@@ -56,11 +65,16 @@ func main() {
 
 	// Auto-migrate models
 	db := database.GetDB()
-	err := db.AutoMigrate(&access.User{}, &example.Example{}, &permission.Permission{}, &group.Group{}, &audit.AuditLog{}, &configuration.Configuration{})
+	err := db.AutoMigrate(&access.User{}, &access.AccessKey{}, &access.StatusChange{}, &access.TemporaryGrant{}, &example.Example{}, &permission.Permission{}, &group.Group{}, &group.GroupPermissionVersion{}, &approval.PendingChange{}, &audit.AuditLog{}, &auth.SigningKey{}, &configuration.Configuration{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Convert any plaintext API keys left from older versions
+	if err := access.MigrateLegacyAPIKeys(db); err != nil {
+		log.Fatal("Failed to migrate API keys:", err)
+	}
+
 	// Seed database with test data (if flag is provided or first run)
 	if *seedFlag {
 		database.SeedDatabase(db)
@@ -74,31 +88,72 @@ func main() {
 	permissionRepo := permission.NewRepository(db)
 	groupRepo := group.NewRepository(db)
 	auditRepo := audit.NewRepository(db)
+	approvalRepo := approval.NewRepository(db)
+
+	// Initialize auth repository wrapper, cached unless AUTH_CACHE_TTL is 0
+	var authRepo types.AuthRepository = access.NewAuthRepository(accessRepo)
+	var principalInvalidator cache.Invalidator = cache.NopInvalidator{}
+	var principalCache cache.StatsProvider
+	if config.AuthCacheTTL > 0 {
+		cachedAuthRepo := access.NewCachedAuthRepository(authRepo, config.AuthCacheSize, config.AuthCacheTTL)
+		authRepo, principalInvalidator, principalCache = cachedAuthRepo, cachedAuthRepo, cachedAuthRepo
+	}
 
 	// Initialize handlers
-	accessHandler := access.NewHandler(accessRepo)
+	accessHandler := access.NewHandler(accessRepo, groupRepo, permissionRepo, principalInvalidator, config.APIKeyRotationGrace, config.TemporaryGrantMaxDuration)
 	exampleHandler := example.NewHandler(exampleRepo)
-	permissionHandler := permission.NewHandler(permissionRepo)
-	groupHandler := group.NewHandler(groupRepo)
+	groupHandler := group.NewHandler(groupRepo, principalInvalidator)
 	auditHandler := audit.NewHandler(auditRepo)
 
-	// Initialize middleware with auth repository wrapper
-	authRepo := access.NewAuthRepository(accessRepo)
-	authMiddleware := middleware.NewAuthMiddleware(authRepo)
+	// Initialize middleware
+	// HMAC request signing is only available when signing secrets can be stored encrypted
+	var signatureVerifier *middleware.SignatureVerifier
+	if utils.SecretEncryptionEnabled() {
+		signatureVerifier = middleware.NewSignatureVerifier(config.SignatureMaxSkew)
+	}
+
+	// OAuth access tokens signed with rotating Ed25519 keys
+	tokenIssuer := auth.NewTokenIssuer(auth.NewRepository(db), config.JWTIssuer, config.JWTTokenTTL, config.JWTKeyRotation)
+	if err := tokenIssuer.Init(); err != nil {
+		log.Fatal("Failed to initialize token signing keys:", err)
+	}
+	tokenIssuer.StartRotation(time.Hour)
+
+	// Expired temporary grants stop counting immediately; the sweep deletes and audits them
+	access.StartGrantSweep(accessRepo, groupRepo, principalInvalidator, config.TemporaryGrantSweepInterval, audit.RecordExpiredGrant(auditRepo))
+
+	authMiddleware := middleware.NewAuthMiddleware(authRepo, signatureVerifier, tokenIssuer)
 	auditMiddleware := audit.NewAuditMiddleware(auditRepo)
 
 	// Initialize rate limiter middleware (default: 120 requests per minute)
 	rateLimiter := middleware.NewRateLimiter(120)
 	rateLimitMiddleware := middleware.RateLimitMiddleware(rateLimiter)
 
+	// Initialize OAuth, introspection, forward-auth and cache monitoring handlers
+	forwardRules, err := auth.LoadForwardRules(config.ForwardAuthRulesFile)
+	if err != nil {
+		log.Fatal("Failed to load forward-auth rules:", err)
+	}
+	authHandler := auth.NewHandler(authRepo, tokenIssuer, principalCache, rateLimiter, forwardRules)
+
 	// Initialize configuration module
 	configurationRepo := configuration.NewRepository(db)
 	configurationHandler := configuration.NewHandler(configurationRepo)
 
 	// Initialize your custom module here
 
+	// Client IPs for allowlists and audit logs. Fiber's c.IP() takes the leftmost entry of
+	// X-Forwarded-For, which the client controls, so the resolver reads the list from the right.
+	clientIPResolver, err := utils.NewClientIPResolver(config.ProxyHeader, config.TrustedProxies, config.EnableTrustedProxyCheck)
+	if err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
+		// X-Forwarded-Host and X-Forwarded-Proto are only read from trusted proxies
+		EnableTrustedProxyCheck: config.EnableTrustedProxyCheck,
+		TrustedProxies:          config.TrustedProxies,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -172,6 +227,7 @@ func main() {
 	// })
 
 	// Middleware
+	app.Use(clientIPResolver.Middleware())
 	app.Use(logger.New())
 	app.Use(cors.New())
 	app.Use(auditMiddleware) // Add audit logging middleware
@@ -196,17 +252,51 @@ func main() {
 		})
 	})
 
+	// Record the permissions each route requires
+	routePermissions := middleware.NewRoutePermissions(app)
+
+	// Deleting a permission reports the registered routes that depend on it
+	permissionHandler := permission.NewHandler(permissionRepo, principalInvalidator, routePermissions)
+
+	// Requests matching an approval rule become pending changes that another admin approves
+	approvalRules, err := approval.LoadRules(config.ApprovalRulesFile)
+	if err != nil {
+		log.Fatal("Failed to load approval rules:", err)
+	}
+	approvalGate := approval.NewGate(approvalRules, approvalRepo, permissionRepo, groupRepo, accessRepo, auditRepo, config.ApprovalTTL)
+	requirePermission := approvalGate.Wrap(routePermissions.Require)
+	approvalExecutor := approval.NewExecutor(app, func(accessID string) (types.User, error) {
+		user, err := accessRepo.FindActiveByID(accessID)
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	})
+	approvalHandler := approval.NewHandler(approvalRepo, auditRepo, approvalExecutor)
+
 	// Register routes with auth, rate limit, and permission middleware
-	access.RegisterAccessRoutes(app, accessHandler, authMiddleware, rateLimitMiddleware, middleware.RequirePermission)
-	example.RegisterExampleRoutes(app, exampleHandler, authMiddleware, rateLimitMiddleware, middleware.RequirePermission)
-	permission.RegisterPermissionRoutes(app, permissionHandler, authMiddleware, rateLimitMiddleware, middleware.RequirePermission)
-	group.RegisterGroupRoutes(app, groupHandler, authMiddleware, rateLimitMiddleware, middleware.RequirePermission)
-	audit.RegisterAuditRoutes(app, auditHandler, authMiddleware, rateLimitMiddleware, middleware.RequirePermission)
-	configuration.RegisterConfigurationRoutes(app, configurationHandler, authMiddleware, rateLimitMiddleware, middleware.RequirePermission)
+	access.RegisterAccessRoutes(app, accessHandler, authMiddleware, rateLimitMiddleware, requirePermission)
+	example.RegisterExampleRoutes(app, exampleHandler, authMiddleware, rateLimitMiddleware, requirePermission)
+	permission.RegisterPermissionRoutes(app, permissionHandler, authMiddleware, rateLimitMiddleware, requirePermission)
+	group.RegisterGroupRoutes(app, groupHandler, authMiddleware, rateLimitMiddleware, requirePermission)
+	audit.RegisterAuditRoutes(app, auditHandler, authMiddleware, rateLimitMiddleware, requirePermission)
+	configuration.RegisterConfigurationRoutes(app, configurationHandler, authMiddleware, rateLimitMiddleware, requirePermission)
+	auth.RegisterAuthRoutes(app, authHandler, authMiddleware, rateLimitMiddleware, requirePermission)
+	approval.RegisterApprovalRoutes(app, approvalHandler, authMiddleware, rateLimitMiddleware, requirePermission)
 
 	// Register your module route here
 
+	// Create the permissions required by registered routes that are missing from the database
+	routePermissions.Seal()
+	routePolicy, err := database.LoadRoutePermissionPolicy(config.RoutePermissionPolicyFile)
+	if err != nil {
+		log.Fatal("Failed to load route permission policy:", err)
+	}
+	if err := database.SyncRoutePermissions(permissionRepo, groupRepo, routePermissions.Routes(), routePolicy, config.RoutePermissionSync); err != nil {
+		log.Fatal("Failed to sync route permissions:", err)
+	}
+
 	// Start server
 	log.Printf("Server starting on port %s", config.ServerPort)
 	log.Fatal(app.Listen(":" + config.ServerPort))
-}
\ No newline at end of file
+}
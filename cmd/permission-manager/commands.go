@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+
+	"gorm.io/gorm"
+)
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// PermissionManager runs commands through the repositories the API server uses
+type PermissionManager struct {
+	db          *gorm.DB
+	access      access.Repository
+	groups      group.Repository
+	permissions permission.Repository
+}
+
+func NewPermissionManager(db *gorm.DB) *PermissionManager {
+	return &PermissionManager{
+		db:          db,
+		access:      access.NewRepository(db),
+		groups:      group.NewRepository(db),
+		permissions: permission.NewRepository(db),
+	}
+}
+
+// change runs fn in a transaction, which is rolled back for a dry run
+func (pm *PermissionManager) change(dryRun bool, fn func(tx *PermissionManager) error) error {
+	err := pm.db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(NewPermissionManager(tx)); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// findGroup finds an active group with its permissions by name or ID
+func (pm *PermissionManager) findGroup(nameOrID string) (*group.Group, error) {
+	id, err := strconv.ParseUint(nameOrID, 10, 64)
+	if err != nil {
+		byName, err := pm.groups.GetGroupByName(nameOrID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, notFoundError("group '%s' not found", nameOrID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		id = uint64(byName.ID)
+	}
+
+	g, err := pm.groups.GetGroupWithPermissions(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, notFoundError("group '%s' not found", nameOrID)
+	}
+	return g, err
+}
+
+// findPermission finds an active permission by resource and action
+func (pm *PermissionManager) findPermission(resource, action string) (*permission.Permission, error) {
+	perm, err := pm.permissions.GetPermissionByKey(resource, action)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && (perm.StatusID == nil || *perm.StatusID != 0)) {
+		return nil, notFoundError("permission '%s:%s' not found in database", resource, action)
+	}
+	return perm, err
+}
+
+// findAccess loads an access with its groups and permissions
+func (pm *PermissionManager) findAccess(accessID string) (*access.User, error) {
+	if !isAccessID(accessID) {
+		return nil, usageError("access_id must be a valid UUID format")
+	}
+	user, err := pm.access.GetAccessWithPermissions(accessID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, notFoundError("access ID '%s' not found", accessID)
+	}
+	return user, err
+}
+
+// primaryGroupOf returns the name of the primary group of an access, for the legacy grant form
+func (pm *PermissionManager) primaryGroupOf(accessID string) (string, error) {
+	user, err := pm.findAccess(accessID)
+	if err != nil {
+		return "", err
+	}
+	if user.Group == nil {
+		return "", notFoundError("access ID '%s' is not assigned to any group", accessID)
+	}
+	return user.Group.Name, nil
+}
+
+func hasPermission(permissions []permission.Permission, id uint) bool {
+	for _, p := range permissions {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionIDs returns the IDs of permissions, leaving out except
+func permissionIDs(permissions []permission.Permission, except uint) []uint {
+	ids := make([]uint, 0, len(permissions))
+	for _, p := range permissions {
+		if p.ID != except {
+			ids = append(ids, p.ID)
+		}
+	}
+	return ids
+}
+
+func runGrant(pm *PermissionManager, args []string, opts *options) error {
+	groupName, resource, action := args[0], args[1], args[2]
+	if isAccessID(groupName) {
+		name, err := pm.primaryGroupOf(groupName)
+		if err != nil {
+			return err
+		}
+		groupName = name
+	}
+
+	result := changeResult{Permission: resource + ":" + action, DryRun: opts.dryRun}
+	err := pm.change(opts.dryRun, func(tx *PermissionManager) error {
+		g, err := tx.findGroup(groupName)
+		if err != nil {
+			return err
+		}
+		perm, err := tx.findPermission(resource, action)
+		if err != nil {
+			return err
+		}
+
+		result.Group = g.Name
+		if hasPermission(g.Permissions, perm.ID) {
+			result.Status = StatusUnchanged
+			return nil
+		}
+		result.Status = StatusGranted
+		ids := append(permissionIDs(g.Permissions, 0), perm.ID)
+		_, err = tx.groups.ReplaceGroupPermissions(g.ID, ids, group.GroupPermissionVersion{Kind: group.VersionCLI})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return printChange(result, opts.output)
+}
+
+func runRevoke(pm *PermissionManager, args []string, opts *options) error {
+	groupName, resource, action := args[0], args[1], args[2]
+
+	result := changeResult{Permission: resource + ":" + action, DryRun: opts.dryRun}
+	err := pm.change(opts.dryRun, func(tx *PermissionManager) error {
+		g, err := tx.findGroup(groupName)
+		if err != nil {
+			return err
+		}
+		perm, err := tx.findPermission(resource, action)
+		if err != nil {
+			return err
+		}
+
+		result.Group = g.Name
+		if !hasPermission(g.Permissions, perm.ID) {
+			result.Status = StatusUnchanged
+			return nil
+		}
+		result.Status = StatusRevoked
+		_, err = tx.groups.ReplaceGroupPermissions(g.ID, permissionIDs(g.Permissions, perm.ID), group.GroupPermissionVersion{Kind: group.VersionCLI})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return printChange(result, opts.output)
+}
+
+func runListGroup(pm *PermissionManager, args []string, opts *options) error {
+	g, err := pm.findGroup(args[0])
+	if err != nil {
+		return err
+	}
+	return printGroup(g, opts.output)
+}
+
+func runListAccess(pm *PermissionManager, args []string, opts *options) error {
+	user, err := pm.findAccess(args[0])
+	if err != nil {
+		return err
+	}
+	return printAccess(user, opts.output)
+}
+
+func runCreatePermission(pm *PermissionManager, args []string, opts *options) error {
+	resource, action := args[0], args[1]
+	if _, _, ok := permission.SplitKey(resource + ":" + action); !ok || strings.Contains(resource, ":") {
+		return usageError("resource and action cannot be empty or contain ':'")
+	}
+	name := opts.name
+	if name == "" {
+		name = capitalize(action) + " " + capitalize(resource)
+	}
+
+	result := changeResult{Permission: resource + ":" + action, Status: StatusCreated, DryRun: opts.dryRun}
+	err := pm.change(opts.dryRun, func(tx *PermissionManager) error {
+		existing, err := tx.permissions.GetPermissionByKey(resource, action)
+		if err == nil {
+			return conflictError("permission '%s:%s' already exists as '%s'", resource, action, existing.Name)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		status := int16(0)
+		perm := &permission.Permission{Name: name, Description: opts.description, Resource: resource, Action: action, StatusID: &status}
+		if err := tx.permissions.CreatePermission(perm); err != nil {
+			return err
+		}
+		result.ID = perm.ID
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return printChange(result, opts.output)
+}
+
+func runCreateGroup(pm *PermissionManager, args []string, opts *options) error {
+	name := args[0]
+
+	result := changeResult{Group: name, Status: StatusCreated, DryRun: opts.dryRun}
+	err := pm.change(opts.dryRun, func(tx *PermissionManager) error {
+		if err := tx.ensureGroupNameFree(name); err != nil {
+			return err
+		}
+
+		var parentIDs []uint
+		for _, parentName := range strings.Split(opts.parents, ",") {
+			if parentName = strings.TrimSpace(parentName); parentName == "" {
+				continue
+			}
+			parent, err := tx.findGroup(parentName)
+			if err != nil {
+				return err
+			}
+			parentIDs = append(parentIDs, parent.ID)
+		}
+
+		status := int16(0)
+		g := &group.Group{Name: name, Description: opts.description, StatusID: &status}
+		if err := tx.groups.CreateGroup(g); err != nil {
+			return err
+		}
+		result.ID = g.ID
+		if len(parentIDs) == 0 {
+			return nil
+		}
+		return tx.groups.UpdateGroupParents(g.ID, parentIDs)
+	})
+	if err != nil {
+		return err
+	}
+	return printChange(result, opts.output)
+}
+
+func runCloneGroup(pm *PermissionManager, args []string, opts *options) error {
+	sourceName, name := args[0], args[1]
+
+	result := changeResult{Group: name, Status: StatusCreated, DryRun: opts.dryRun}
+	err := pm.change(opts.dryRun, func(tx *PermissionManager) error {
+		source, err := tx.findGroup(sourceName)
+		if err != nil {
+			return err
+		}
+		if err := tx.ensureGroupNameFree(name); err != nil {
+			return err
+		}
+
+		description := opts.description
+		if description == "" {
+			description = source.Description
+		}
+		clone, err := tx.groups.CloneGroup(source.ID, name, description)
+		if err != nil {
+			return err
+		}
+		result.ID = clone.ID
+		result.Source = source.Name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return printChange(result, opts.output)
+}
+
+func runWhoCan(pm *PermissionManager, args []string, opts *options) error {
+	resource, action := args[0], args[1]
+
+	users, err := pm.access.ListAccessesWithPermissions()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	allowed := make([]whoCanEntry, 0)
+	for i := range users {
+		check := users[i].CheckPermission(resource, action, now)
+		if !check.Allowed {
+			continue
+		}
+		entry := whoCanEntry{AccessID: users[i].ID, Name: users[i].Name, Email: users[i].Email, Reason: check.Reason}
+		for _, grant := range check.MatchedBy {
+			entry.MatchedBy = append(entry.MatchedBy, grant.Resource+":"+grant.Action)
+		}
+		allowed = append(allowed, entry)
+	}
+	return printWhoCan(resource+":"+action, allowed, opts.output)
+}
+
+// ensureGroupNameFree fails with a conflict when a group, active or deleted, already has the name
+func (pm *PermissionManager) ensureGroupNameFree(name string) error {
+	taken, err := pm.groups.IsNameTaken(name, 0)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return conflictError("group '%s' already exists", name)
+	}
+	return nil
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
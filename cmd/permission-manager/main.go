@@ -1,204 +1,224 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
 
 	"apiserver/configs"
 	"apiserver/internal/database"
-	"apiserver/internal/modules/access"
-	"apiserver/internal/modules/permission"
 
 	"gorm.io/gorm"
 )
 
+// Exit codes, so scripts can tell failures apart
 const (
-	ExitSuccess = 0
-	ExitError   = 1
+	ExitSuccess  = 0 // Done, or nothing had to change
+	ExitError    = 1 // Database or unexpected error
+	ExitUsage    = 2 // Unknown command, missing argument or invalid flag
+	ExitNotFound = 3 // Access, group or permission not found
+	ExitConflict = 4 // Permission or group already exists
+	ExitChanges  = 5 // "policy plan" found changes
 )
 
-type PermissionManager struct {
-	db *gorm.DB
-}
+// Output formats
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
 
-func NewPermissionManager(db *gorm.DB) *PermissionManager {
-	return &PermissionManager{db: db}
+// command describes a subcommand
+type command struct {
+	args        string
+	description string
+	mutates     bool // Supports --dry-run
+	run         func(pm *PermissionManager, args []string, opts *options) error
 }
 
-// validateAccessID checks if the access ID exists and returns the user with group
-func (pm *PermissionManager) validateAccessID(accessID string) (*access.User, error) {
-	var user access.User
-	err := pm.db.Preload("Group").Where("id = ? AND status_id = ?", accessID, 0).First(&user).Error
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("access ID '%s' not found", accessID)
-		}
-		return nil, fmt.Errorf("failed to query access ID: %v", err)
-	}
-
-	if user.Group == nil {
-		return nil, fmt.Errorf("access ID '%s' is not assigned to any group", accessID)
-	}
-
-	return &user, nil
+var commands = map[string]command{
+	"grant":             {"<group> <resource> <action>", "Grant a permission to a group", true, runGrant},
+	"revoke":            {"<group> <resource> <action>", "Revoke a permission granted directly to a group", true, runRevoke},
+	"list-group":        {"<group>", "List the direct, inherited and denied permissions of a group", false, runListGroup},
+	"list-access":       {"<access_id>", "List the groups and effective permissions of an access", false, runListAccess},
+	"create-permission": {"<resource> <action>", "Create a permission", true, runCreatePermission},
+	"create-group":      {"<name>", "Create a group", true, runCreateGroup},
+	"clone-group":       {"<group> <name>", "Create a group with the permissions, deny rules and parents of another", true, runCloneGroup},
+	"who-can":           {"<resource> <action>", "List the active accesses allowed to perform an action", false, runWhoCan},
 }
 
-// findPermission finds a permission by resource and action
-func (pm *PermissionManager) findPermission(resource, action string) (*permission.Permission, error) {
-	var perm permission.Permission
-	err := pm.db.Where("resource = ? AND action = ? AND status_id = ?", resource, action, 0).First(&perm).Error
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("permission '%s:%s' not found in database", resource, action)
-		}
-		return nil, fmt.Errorf("failed to query permission: %v", err)
-	}
+// commandOrder is the order commands are listed in the usage
+var commandOrder = []string{"grant", "revoke", "list-group", "list-access", "create-permission", "create-group", "clone-group", "who-can"}
 
-	return &perm, nil
+// options are the flags shared by every command plus the ones specific to a few
+type options struct {
+	dryRun      bool
+	output      string
+	name        string
+	description string
+	parents     string
 }
 
-// checkPermissionInGroup checks if a permission already exists in the group
-func (pm *PermissionManager) checkPermissionInGroup(groupID uint, permissionID uint) (bool, error) {
-	var count int64
-	err := pm.db.Table("group_permissions").
-		Where("group_id = ? AND permission_id = ?", groupID, permissionID).
-		Count(&count).Error
-
-	if err != nil {
-		return false, fmt.Errorf("failed to check permission in group: %v", err)
-	}
-
-	return count > 0, nil
+// cliError carries the exit code of a failure
+type cliError struct {
+	code int
+	err  error
 }
 
-// addPermissionToGroup adds a permission to a group
-func (pm *PermissionManager) addPermissionToGroup(groupID uint, permissionID uint) error {
-	// Use raw SQL to insert into the many-to-many table
-	err := pm.db.Exec("INSERT INTO group_permissions (group_id, permission_id) VALUES (?, ?)", groupID, permissionID).Error
-	if err != nil {
-		return fmt.Errorf("failed to add permission to group: %v", err)
-	}
-
-	return nil
+func (e *cliError) Error() string {
+	return e.err.Error()
 }
 
-// processPermissionRequest handles the main logic of adding permission to group
-func (pm *PermissionManager) processPermissionRequest(accessID, resource, action string) error {
-	// Step 1: Validate access ID and get user with group
-	user, err := pm.validateAccessID(accessID)
-	if err != nil {
-		return err
-	}
-
-	// Step 2: Find the permission
-	perm, err := pm.findPermission(resource, action)
-	if err != nil {
-		return err
-	}
+func usageError(format string, args ...interface{}) error {
+	return &cliError{code: ExitUsage, err: fmt.Errorf(format, args...)}
+}
 
-	// Step 3: Check if permission already exists in group
-	exists, err := pm.checkPermissionInGroup(user.Group.ID, perm.ID)
-	if err != nil {
-		return err
-	}
+func notFoundError(format string, args ...interface{}) error {
+	return &cliError{code: ExitNotFound, err: fmt.Errorf(format, args...)}
+}
 
-	if exists {
-		fmt.Printf("⚠ Warning: Permission '%s:%s' already exists in group '%s'\n", resource, action, user.Group.Name)
-		return nil
-	}
+func conflictError(format string, args ...interface{}) error {
+	return &cliError{code: ExitConflict, err: fmt.Errorf(format, args...)}
+}
 
-	// Step 4: Add permission to group
-	err = pm.addPermissionToGroup(user.Group.ID, perm.ID)
-	if err != nil {
-		return err
+// exitCode maps an error to the exit code of the process
+func exitCode(err error) int {
+	var cliErr *cliError
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &cliErr):
+		return cliErr.code
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ExitNotFound
+	default:
+		return ExitError
 	}
-
-	// Step 5: Success message
-	fmt.Printf("✓ Permission '%s:%s' successfully added to group '%s' for access ID '%s'\n",
-		resource, action, user.Group.Name, accessID)
-
-	return nil
 }
 
 func printUsage() {
-	fmt.Println("Usage: permission-manager [access_id] [resource] [action]")
+	fmt.Println("Usage: permission-manager <command> [arguments] [--dry-run] [--output table|json]")
+	fmt.Println("       permission-manager policy <export|plan|apply> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	for _, name := range commandOrder {
+		cmd := commands[name]
+		fmt.Printf("  %-18s %-30s %s\n", name, cmd.args, cmd.description)
+	}
+	fmt.Printf("  %-18s %-30s %s\n", "policy", "<export|plan|apply>", "Manage permissions and groups from a policy file")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  --dry-run       Validate and show the result of a change without saving it")
+	fmt.Println("  --output        Output format: table (default) or json")
+	fmt.Println("  --name          Name of the permission (create-permission)")
+	fmt.Println("  --description   Description of the permission or group (create-permission, create-group, clone-group)")
+	fmt.Println("  --parents       Comma separated parent groups (create-group)")
 	fmt.Println("")
-	fmt.Println("Arguments:")
-	fmt.Println("  access_id  UUID of the access/user to add permission to")
-	fmt.Println("  resource   Resource name (e.g., 'configurations', 'examples')")
-	fmt.Println("  action     Action name (e.g., 'create', 'read', 'update', 'delete')")
+	fmt.Println("A <group> is a group name or ID.")
 	fmt.Println("")
-	fmt.Println("Example:")
-	fmt.Println("  permission-manager 019847a9-4efb-72c1-92fb-2c5eab3335d1 configurations create")
+	fmt.Println("Exit codes:")
+	fmt.Println("  0  Success, or nothing had to change")
+	fmt.Println("  1  Database or unexpected error")
+	fmt.Println("  2  Invalid command, arguments or flags")
+	fmt.Println("  3  Access, group or permission not found")
+	fmt.Println("  4  Permission or group already exists")
+	fmt.Println("  5  policy plan found changes")
 	fmt.Println("")
-	fmt.Println("Description:")
-	fmt.Println("  This tool adds permissions to the group associated with the specified access ID.")
-	fmt.Println("  The permission must already exist in the database.")
+	fmt.Println("Examples:")
+	fmt.Println("  permission-manager grant Editor configurations read")
+	fmt.Println("  permission-manager revoke Editor configurations read --dry-run")
+	fmt.Println("  permission-manager who-can configurations update --output json")
+	fmt.Println("  permission-manager clone-group Editor Reviewer --description \"Editors without delete\"")
+	fmt.Println("")
+	fmt.Println("The legacy form 'permission-manager <access_id> <resource> <action>' grants the")
+	fmt.Println("permission to the primary group of the access.")
 }
 
-func validateArguments(args []string) error {
-	if len(args) != 4 {
-		return fmt.Errorf("invalid number of arguments")
+// parseArgs parses flags placed anywhere between the positional arguments
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
+}
 
-	accessID := strings.TrimSpace(args[1])
-	resource := strings.TrimSpace(args[2])
-	action := strings.TrimSpace(args[3])
-
-	if accessID == "" {
-		return fmt.Errorf("access_id cannot be empty")
-	}
+// isAccessID reports whether an argument looks like an access UUID
+func isAccessID(value string) bool {
+	return len(value) == 36 && strings.Count(value, "-") == 4
+}
 
-	if resource == "" {
-		return fmt.Errorf("resource cannot be empty")
+// run executes a command and returns the exit code
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		printUsage()
+		if len(args) == 0 {
+			return ExitUsage
+		}
+		return ExitSuccess
 	}
-
-	if action == "" {
-		return fmt.Errorf("action cannot be empty")
+	if args[0] == "policy" {
+		return runPolicy(args[1:])
 	}
 
-	// Basic UUID format validation (simple check)
-	if len(accessID) != 36 || strings.Count(accessID, "-") != 4 {
-		return fmt.Errorf("access_id must be a valid UUID format")
+	// Legacy form: <access_id> <resource> <action>
+	name := args[0]
+	if isAccessID(name) {
+		name, args = "grant", append([]string{"grant"}, args...)
 	}
-
-	return nil
-}
-
-func main() {
-	// Check for help flag
-	if len(os.Args) > 1 && (os.Args[1] == "-h" || os.Args[1] == "--help") {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "✗ Error: unknown command '%s'\n\n", name)
 		printUsage()
-		os.Exit(ExitSuccess)
+		return ExitUsage
 	}
 
-	// Validate arguments
-	if err := validateArguments(os.Args); err != nil {
-		fmt.Printf("✗ Error: %s\n\n", err)
-		printUsage()
-		os.Exit(ExitError)
+	opts := &options{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Do not save changes")
+	flags.StringVar(&opts.output, "output", OutputTable, "Output format: table or json")
+	flags.StringVar(&opts.name, "name", "", "Permission name")
+	flags.StringVar(&opts.description, "description", "", "Description")
+	flags.StringVar(&opts.parents, "parents", "", "Comma separated parent groups")
+	positional, err := parseArgs(flags, args[1:])
+	if err != nil {
+		return ExitUsage
+	}
+	if opts.output != OutputTable && opts.output != OutputJSON {
+		fmt.Fprintf(os.Stderr, "✗ Error: --output must be table or json\n")
+		return ExitUsage
+	}
+	if opts.dryRun && !cmd.mutates {
+		fmt.Fprintf(os.Stderr, "✗ Error: %s does not change anything, --dry-run is not supported\n", name)
+		return ExitUsage
+	}
+	if want := len(strings.Fields(cmd.args)); len(positional) != want {
+		fmt.Fprintf(os.Stderr, "✗ Error: %s expects %s\n", name, cmd.args)
+		return ExitUsage
 	}
 
-	accessID := strings.TrimSpace(os.Args[1])
-	resource := strings.TrimSpace(os.Args[2])
-	action := strings.TrimSpace(os.Args[3])
+	pm := NewPermissionManager(openDatabase())
+	if err := cmd.run(pm, positional, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Error: %s\n", err)
+		return exitCode(err)
+	}
+	return ExitSuccess
+}
 
-	// Load configuration
+func openDatabase() *gorm.DB {
 	config := configs.LoadConfig()
-
-	// Initialize database
 	database.InitDatabase(config)
-	db := database.GetDB()
-
-	// Create permission manager
-	pm := NewPermissionManager(db)
-
-	// Process the permission request
-	if err := pm.processPermissionRequest(accessID, resource, action); err != nil {
-		fmt.Printf("✗ Error: %s\n", err)
-		os.Exit(ExitError)
-	}
+	return database.GetDB()
+}
 
-	os.Exit(ExitSuccess)
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/group"
+)
+
+// Results of a change
+const (
+	StatusGranted   = "granted"
+	StatusRevoked   = "revoked"
+	StatusCreated   = "created"
+	StatusUnchanged = "unchanged"
+)
+
+// changeResult is the outcome of a command that changes permissions or groups
+type changeResult struct {
+	Status     string `json:"status"`
+	Group      string `json:"group,omitempty"`
+	Source     string `json:"source_group,omitempty"` // Group a clone was copied from
+	Permission string `json:"permission,omitempty"`
+	ID         uint   `json:"id,omitempty"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// message describes the change for the table output
+func (r changeResult) message() string {
+	switch {
+	case r.Status == StatusGranted:
+		return fmt.Sprintf("Permission '%s' successfully added to group '%s'", r.Permission, r.Group)
+	case r.Status == StatusRevoked:
+		return fmt.Sprintf("Permission '%s' successfully removed from group '%s'", r.Permission, r.Group)
+	case r.Status == StatusUnchanged && r.Permission != "":
+		return fmt.Sprintf("Permission '%s' is already in that state for group '%s', nothing to change", r.Permission, r.Group)
+	case r.Source != "":
+		return fmt.Sprintf("Group '%s' (ID %d) successfully cloned from '%s'", r.Group, r.ID, r.Source)
+	case r.Group != "":
+		return fmt.Sprintf("Group '%s' (ID %d) successfully created", r.Group, r.ID)
+	default:
+		return fmt.Sprintf("Permission '%s' (ID %d) successfully created", r.Permission, r.ID)
+	}
+}
+
+// whoCanEntry is an access allowed to perform an action
+type whoCanEntry struct {
+	AccessID  string   `json:"access_id"`
+	Name      string   `json:"name"`
+	Email     string   `json:"email"`
+	MatchedBy []string `json:"matched_by"`
+	Reason    string   `json:"reason"`
+}
+
+// accessListing is the list-access output
+type accessListing struct {
+	AccessID          string                       `json:"access_id"`
+	Name              string                       `json:"name"`
+	Email             string                       `json:"email"`
+	StatusID          int16                        `json:"status_id"`
+	Groups            []string                     `json:"groups"`
+	Permissions       []access.EffectivePermission `json:"permissions"`
+	DeniedPermissions []access.EffectivePermission `json:"denied_permissions"`
+}
+
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+func printChange(result changeResult, output string) error {
+	if output == OutputJSON {
+		return printJSON(result)
+	}
+
+	switch {
+	case result.Status == StatusUnchanged:
+		fmt.Printf("⚠ Warning: %s\n", result.message())
+	case result.DryRun:
+		fmt.Printf("✓ [dry-run] %s (not saved)\n", result.message())
+	default:
+		fmt.Printf("✓ %s\n", result.message())
+	}
+	return nil
+}
+
+func printGroup(g *group.Group, output string) error {
+	if output == OutputJSON {
+		return printJSON(g)
+	}
+
+	var parents []string
+	for _, parent := range g.Parents {
+		parents = append(parents, parent.Name)
+	}
+	fmt.Printf("Group: %s (ID %d)\n", g.Name, g.ID)
+	if g.Description != "" {
+		fmt.Printf("Description: %s\n", g.Description)
+	}
+	if len(parents) > 0 {
+		fmt.Printf("Parents: %s\n", strings.Join(parents, ", "))
+	}
+	fmt.Println("")
+
+	table := newTable()
+	fmt.Fprintln(table, "PERMISSION\tNAME\tRULE\tSOURCE")
+	for _, p := range g.Permissions {
+		fmt.Fprintf(table, "%s:%s\t%s\tallow\tdirect\n", p.Resource, p.Action, p.Name)
+	}
+	for _, p := range g.InheritedPermissions {
+		fmt.Fprintf(table, "%s:%s\t%s\tallow\tinherited from %s\n", p.Resource, p.Action, p.Name, p.InheritedFrom.Name)
+	}
+	for _, p := range g.DeniedPermissions {
+		fmt.Fprintf(table, "%s:%s\t%s\tdeny\tdirect\n", p.Resource, p.Action, p.Name)
+	}
+	for _, p := range g.InheritedDenies {
+		fmt.Fprintf(table, "%s:%s\t%s\tdeny\tinherited from %s\n", p.Resource, p.Action, p.Name, p.InheritedFrom.Name)
+	}
+	return table.Flush()
+}
+
+func printAccess(user *access.User, output string) error {
+	effective := user.EffectivePermissions()
+	listing := accessListing{
+		AccessID:          user.ID,
+		Name:              user.Name,
+		Email:             user.Email,
+		Groups:            make([]string, 0),
+		Permissions:       effective.Permissions,
+		DeniedPermissions: effective.DeniedPermissions,
+	}
+	if user.StatusID != nil {
+		listing.StatusID = *user.StatusID
+	}
+	for _, g := range user.GetGroups() {
+		listing.Groups = append(listing.Groups, g.Name)
+	}
+	if output == OutputJSON {
+		return printJSON(listing)
+	}
+
+	fmt.Printf("Access: %s (%s)\n", listing.Name, listing.AccessID)
+	fmt.Printf("Email: %s\n", listing.Email)
+	fmt.Printf("Status: %d\n", listing.StatusID)
+	fmt.Printf("Groups: %s\n\n", strings.Join(listing.Groups, ", "))
+
+	table := newTable()
+	fmt.Fprintln(table, "PERMISSION\tNAME\tRULE\tSOURCE")
+	for _, p := range listing.Permissions {
+		fmt.Fprintf(table, "%s:%s\t%s\tallow\t%s\n", p.Resource, p.Action, p.Name, sourceText(p.Sources))
+	}
+	for _, p := range listing.DeniedPermissions {
+		fmt.Fprintf(table, "%s:%s\t%s\tdeny\t%s\n", p.Resource, p.Action, p.Name, sourceText(p.Sources))
+	}
+	return table.Flush()
+}
+
+func printWhoCan(key string, entries []whoCanEntry, output string) error {
+	if output == OutputJSON {
+		return printJSON(map[string]interface{}{"permission": key, "accesses": entries})
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No active access can perform '%s'\n", key)
+		return nil
+	}
+	table := newTable()
+	fmt.Fprintln(table, "ACCESS ID\tNAME\tEMAIL\tMATCHED BY")
+	for _, entry := range entries {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", entry.AccessID, entry.Name, entry.Email, strings.Join(entry.MatchedBy, ", "))
+	}
+	return table.Flush()
+}
+
+// sourceText summarises where an effective permission comes from
+func sourceText(sources []access.PermissionSource) string {
+	var parts []string
+	for _, source := range sources {
+		switch {
+		case source.Type == access.SourceDirect:
+			parts = append(parts, "direct")
+		case source.Type == access.SourceTemporary:
+			parts = append(parts, "temporary until "+source.ExpiresAt.Format(time.RFC3339))
+		case source.InheritedFrom != nil && source.Group != nil:
+			parts = append(parts, fmt.Sprintf("group %s (inherited from %s)", source.Group.Name, source.InheritedFrom.Name))
+		case source.Group != nil:
+			parts = append(parts, "group "+source.Group.Name)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"apiserver/internal/middleware"
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/approval"
+	"apiserver/internal/modules/audit"
+	"apiserver/internal/modules/auth"
+	"apiserver/internal/modules/configuration"
+	"apiserver/internal/modules/example"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/policy"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func printPolicyUsage() {
+	fmt.Println("Usage: permission-manager policy <export|plan|apply> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  export  Write the current permissions, groups and grants as a policy file")
+	fmt.Println("  plan    Show the changes that would make the database match the policy file")
+	fmt.Println("  apply   Apply those changes in a single transaction")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  --file    Policy file, YAML unless it ends in .json (export writes to stdout when empty)")
+	fmt.Println("  --format  Output format of export when --file is empty: yaml or json")
+	fmt.Println("  --prune   Also remove permissions, groups and grants that are not in the file;")
+	fmt.Println("            permissions that API routes require are kept and fail the apply")
+	fmt.Println("")
+	fmt.Println("Exit codes:")
+	fmt.Println("  0  Success, or no changes to plan")
+	fmt.Println("  1  Error")
+	fmt.Println("  2  Invalid command or flags")
+	fmt.Println("  5  plan found changes")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("  permission-manager policy export --file rbac-policy.yaml")
+	fmt.Println("  permission-manager policy plan --file rbac-policy.yaml --prune")
+	fmt.Println("  permission-manager policy apply --file rbac-policy.yaml --prune")
+}
+
+// runPolicy runs a policy command and returns the exit code
+func runPolicy(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		printPolicyUsage()
+		return ExitSuccess
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("policy "+command, flag.ContinueOnError)
+	file := flags.String("file", "", "Policy file")
+	format := flags.String("format", policy.FormatYAML, "Output format of export: yaml or json")
+	prune := flags.Bool("prune", false, "Remove anything that is not in the policy file")
+	if err := flags.Parse(args[1:]); err != nil {
+		return ExitUsage
+	}
+
+	switch command {
+	case "export":
+		return exportPolicy(openDatabase(), *file, *format)
+	case "plan", "apply":
+		if *file == "" {
+			fmt.Fprintf(os.Stderr, "✗ Error: %s needs --file\n\n", command)
+			printPolicyUsage()
+			return ExitUsage
+		}
+		desired, err := policy.Load(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ Error: %s\n", err)
+			return ExitError
+		}
+		if command == "plan" {
+			return planPolicy(openDatabase(), desired, *prune)
+		}
+		return applyPolicy(openDatabase(), desired, *prune)
+	default:
+		fmt.Fprintf(os.Stderr, "✗ Error: unknown policy command '%s'\n\n", command)
+		printPolicyUsage()
+		return ExitUsage
+	}
+}
+
+func exportPolicy(db *gorm.DB, file, format string) int {
+	current, err := policy.Export(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Error: failed to export policy: %s\n", err)
+		return ExitError
+	}
+
+	if file == "" {
+		if err := current.Write(os.Stdout, format); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ Error: %s\n", err)
+			return ExitError
+		}
+		return ExitSuccess
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Error: %s\n", err)
+		return ExitError
+	}
+	defer out.Close()
+	if err := current.Write(out, policy.FormatOf(file)); err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Error: %s\n", err)
+		return ExitError
+	}
+	fmt.Printf("✓ Exported %d permissions and %d groups to %s\n", len(current.Permissions), len(current.Groups), file)
+	return ExitSuccess
+}
+
+func planPolicy(db *gorm.DB, desired *policy.Policy, prune bool) int {
+	current, err := policy.Export(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Error: failed to read current state: %s\n", err)
+		return ExitError
+	}
+
+	plan := policy.Diff(current, desired, prune)
+	plan.Write(os.Stdout)
+	if plan.Empty() {
+		return ExitSuccess
+	}
+	return ExitChanges
+}
+
+func applyPolicy(db *gorm.DB, desired *policy.Policy, prune bool) int {
+	plan, err := policy.Apply(db, desired, prune, registeredRoutes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "✗ Error: nothing was applied: %s\n", err)
+		return ExitError
+	}
+
+	plan.Write(os.Stdout)
+	if !plan.Empty() {
+		fmt.Println("\n✓ Policy applied")
+	}
+	return ExitSuccess
+}
+
+// registeredRoutes returns the permissions required by the routes of the API server. The modules
+// are registered as in cmd/api/main.go, without handlers, only to record what their routes require.
+func registeredRoutes() *middleware.RoutePermissions {
+	app := fiber.New()
+	routes := middleware.NewRoutePermissions(app)
+	next := func(c *fiber.Ctx) error { return c.Next() }
+
+	access.RegisterAccessRoutes(app, nil, next, next, routes.Require)
+	example.RegisterExampleRoutes(app, nil, next, next, routes.Require)
+	permission.RegisterPermissionRoutes(app, nil, next, next, routes.Require)
+	group.RegisterGroupRoutes(app, nil, next, next, routes.Require)
+	audit.RegisterAuditRoutes(app, nil, next, next, routes.Require)
+	configuration.RegisterConfigurationRoutes(app, nil, next, next, routes.Require)
+	auth.RegisterAuthRoutes(app, nil, next, next, routes.Require)
+	approval.RegisterApprovalRoutes(app, nil, next, next, routes.Require)
+
+	routes.Seal()
+	return routes
+}
@@ -3,6 +3,9 @@ package configs
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -21,6 +24,41 @@ type Config struct {
 	ServerPort string
 	DocFilter  string
 
+	// Security Configuration
+	APIKeyPepper        string
+	APIKeyRotationGrace time.Duration // How long a rotated-out key keeps working
+	SigningSecretKey    string        // Encrypts HMAC signing secrets; empty disables request signing
+	SignatureMaxSkew    time.Duration // Accepted clock difference for signed requests
+
+	// Auth Cache Configuration
+	AuthCacheTTL  time.Duration // How long a resolved principal is reused; 0 disables the cache
+	AuthCacheSize int           // Maximum number of cached principals
+
+	// OAuth Configuration
+	JWTIssuer      string        // "iss" claim of issued access tokens
+	JWTTokenTTL    time.Duration // Lifetime of access tokens issued by /oauth/token
+	JWTKeyRotation time.Duration // Age after which a new token signing key is created
+
+	// Forward Auth Configuration
+	ForwardAuthRulesFile string // JSON file mapping upstream routes to permissions
+
+	// Route Permission Configuration
+	RoutePermissionSync       bool   // Create permissions required by routes that are missing at startup
+	RoutePermissionPolicyFile string // JSON file naming the groups that receive created permissions
+
+	// Temporary Grant Configuration
+	TemporaryGrantMaxDuration   time.Duration // Longest lifetime of a time-bound permission grant
+	TemporaryGrantSweepInterval time.Duration // How often expired grants are removed and audited
+
+	// Approval Workflow Configuration
+	ApprovalRulesFile string        // JSON file listing the operations that need a second admin's approval
+	ApprovalTTL       time.Duration // How long a pending change can be approved before it expires
+
+	// Proxy Configuration
+	ProxyHeader             string   // Header holding the client IP, e.g. X-Forwarded-For
+	EnableTrustedProxyCheck bool     // Only read ProxyHeader from TrustedProxies
+	TrustedProxies          []string // IPs or CIDRs of the load balancers in front of the API
+
 	// AI Configuration
 	AIBaseURL string
 	AIAPIKey  string
@@ -59,6 +97,41 @@ func LoadConfig() *Config {
 		ServerPort: getEnv("SERVER_PORT", "3000"),
 		DocFilter:  getEnv("API_DOC_FILTER", ""),
 
+		// Security Configuration
+		APIKeyPepper:        getEnv("API_KEY_PEPPER", ""),
+		APIKeyRotationGrace: getDurationEnv("API_KEY_ROTATION_GRACE", 24*time.Hour),
+		SigningSecretKey:    getEnv("SIGNING_SECRET_KEY", ""),
+		SignatureMaxSkew:    getDurationEnv("SIGNATURE_MAX_SKEW", 5*time.Minute),
+
+		// Auth Cache Configuration
+		AuthCacheTTL:  getDurationEnv("AUTH_CACHE_TTL", 30*time.Second),
+		AuthCacheSize: getIntEnv("AUTH_CACHE_SIZE", 10000),
+
+		// OAuth Configuration
+		JWTIssuer:      getEnv("JWT_ISSUER", getEnv("API_NAME", "My API Name")),
+		JWTTokenTTL:    getDurationEnv("JWT_TOKEN_TTL", 15*time.Minute),
+		JWTKeyRotation: getDurationEnv("JWT_KEY_ROTATION", 30*24*time.Hour),
+
+		// Forward Auth Configuration
+		ForwardAuthRulesFile: getEnv("FORWARD_AUTH_RULES_FILE", "configs/forward-auth-rules.json"),
+
+		// Route Permission Configuration
+		RoutePermissionSync:       getEnv("ROUTE_PERMISSION_SYNC", "true") == "true",
+		RoutePermissionPolicyFile: getEnv("ROUTE_PERMISSION_POLICY_FILE", "configs/route-permission-policy.json"),
+
+		// Temporary Grant Configuration
+		TemporaryGrantMaxDuration:   getDurationEnv("TEMPORARY_GRANT_MAX_DURATION", 24*time.Hour),
+		TemporaryGrantSweepInterval: getDurationEnv("TEMPORARY_GRANT_SWEEP_INTERVAL", time.Minute),
+
+		// Approval Workflow Configuration
+		ApprovalRulesFile: getEnv("APPROVAL_RULES_FILE", "configs/approval-rules.json"),
+		ApprovalTTL:       getDurationEnv("APPROVAL_TTL", 72*time.Hour),
+
+		// Proxy Configuration
+		ProxyHeader:             getEnv("PROXY_HEADER", ""),
+		EnableTrustedProxyCheck: getEnv("TRUSTED_PROXY_CHECK", "true") == "true",
+		TrustedProxies:          getListEnv("TRUSTED_PROXIES"),
+
 		// AI Configuration
 		AIBaseURL: getEnv("AI_BASE_URL", "https://api.openai.com/v1"),
 		AIAPIKey:  getEnv("AI_API_KEY", ""),
@@ -76,4 +149,43 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
+}
+
+// getIntEnv reads an integer, falling back to the default when unset or invalid
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid integer for %s, using %d", key, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+// getListEnv reads a comma separated list, ignoring empty entries
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getDurationEnv reads a duration such as "24h" or "90m", falling back to the default when unset or invalid
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Warning: invalid duration for %s, using %s", key, defaultValue)
+		return defaultValue
+	}
+	return duration
 }
\ No newline at end of file
@@ -0,0 +1,16 @@
+package cache
+
+// Invalidator drops cached authentication data after the records it was built from change.
+// Modules that cannot import the access module use it to keep cached principals fresh.
+type Invalidator interface {
+	InvalidateAccess(accessID string)
+	InvalidateGroup(groupID uint)
+	InvalidateAll()
+}
+
+// NopInvalidator is used when caching is disabled
+type NopInvalidator struct{}
+
+func (NopInvalidator) InvalidateAccess(accessID string) {}
+func (NopInvalidator) InvalidateGroup(groupID uint)     {}
+func (NopInvalidator) InvalidateAll()                   {}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are the counters of a cache
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"` // Entries dropped for capacity, expiry or invalidation
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+	TTLMillis int64  `json:"ttl_ms"`
+}
+
+// StatsProvider is implemented by anything exposing cache counters
+type StatsProvider interface {
+	Stats() Stats
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// LRU is a size bounded cache whose entries also expire after a fixed TTL.
+// It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[K]*list.Element
+	order    *list.List // Front is the most recently used
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// NewLRU creates a cache holding at most capacity entries for at most ttl each
+func NewLRU[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns a cached value and records a hit or miss
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	e := element.Value.(*entry[K, V])
+	if time.Now().After(e.expiresAt) {
+		c.removeElement(element)
+		c.misses.Add(1)
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits.Add(1)
+	return e.value, true
+}
+
+// Set stores a value, evicting the least recently used entry when full
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// Delete removes a single entry
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// DeleteFunc removes every entry for which match returns true and reports how many were removed
+func (c *LRU[K, V]) DeleteFunc(match func(key K, value V) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		e := element.Value.(*entry[K, V])
+		if match(e.key, e.value) {
+			c.removeElement(element)
+			removed++
+		}
+		element = next
+	}
+	return removed
+}
+
+// Purge removes every entry
+func (c *LRU[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictions.Add(uint64(c.order.Len()))
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the current counters
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+		Capacity:  c.capacity,
+		TTLMillis: c.ttl.Milliseconds(),
+	}
+}
+
+func (c *LRU[K, V]) removeElement(element *list.Element) {
+	e := c.order.Remove(element).(*entry[K, V])
+	delete(c.items, e.key)
+	c.evictions.Add(1)
+}
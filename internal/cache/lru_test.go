@@ -0,0 +1,85 @@
+// USAGE
+//   go test ./internal/cache -v -run TestLRU
+
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	t.Run("Get returns stored values and counts hits and misses", func(t *testing.T) {
+		c := NewLRU[string, int](10, time.Minute)
+		c.Set("a", 1)
+
+		if v, ok := c.Get("a"); !ok || v != 1 {
+			t.Errorf("Get(a) = %v, %v, want 1, true", v, ok)
+		}
+		if _, ok := c.Get("b"); ok {
+			t.Error("Get(b) found a value that was never stored")
+		}
+
+		stats := c.Stats()
+		if stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+			t.Errorf("Stats() = %+v, want 1 hit, 1 miss, size 1", stats)
+		}
+	})
+
+	t.Run("Least recently used entry is evicted", func(t *testing.T) {
+		c := NewLRU[string, int](2, time.Minute)
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Get("a") // a is now more recent than b
+		c.Set("c", 3)
+
+		if _, ok := c.Get("b"); ok {
+			t.Error("Get(b) found the least recently used entry")
+		}
+		if _, ok := c.Get("a"); !ok {
+			t.Error("Get(a) did not find a recently used entry")
+		}
+		if _, ok := c.Get("c"); !ok {
+			t.Error("Get(c) did not find the newest entry")
+		}
+	})
+
+	t.Run("Entries expire after the TTL", func(t *testing.T) {
+		c := NewLRU[string, int](10, 10*time.Millisecond)
+		c.Set("a", 1)
+		time.Sleep(20 * time.Millisecond)
+
+		if _, ok := c.Get("a"); ok {
+			t.Error("Get(a) found an expired entry")
+		}
+		if size := c.Stats().Size; size != 0 {
+			t.Errorf("Stats().Size = %d, want 0", size)
+		}
+	})
+
+	t.Run("DeleteFunc removes matching entries", func(t *testing.T) {
+		c := NewLRU[string, int](10, time.Minute)
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Set("c", 3)
+
+		removed := c.DeleteFunc(func(key string, value int) bool { return value%2 == 1 })
+		if removed != 2 {
+			t.Errorf("DeleteFunc() removed %d entries, want 2", removed)
+		}
+		if _, ok := c.Get("b"); !ok {
+			t.Error("Get(b) did not find an entry that should remain")
+		}
+	})
+
+	t.Run("Purge removes every entry", func(t *testing.T) {
+		c := NewLRU[string, int](10, time.Minute)
+		c.Set("a", 1)
+		c.Set("b", 2)
+		c.Purge()
+
+		if size := c.Stats().Size; size != 0 {
+			t.Errorf("Stats().Size = %d, want 0", size)
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"apiserver/internal/middleware"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+
+	"gorm.io/gorm"
+)
+
+// RoutePermissionPolicy names the groups that receive permissions created at startup
+type RoutePermissionPolicy struct {
+	Grants []RoutePermissionGrant `json:"grants"`
+}
+
+// RoutePermissionGrant grants newly created permissions matching any of the patterns to a group
+type RoutePermissionGrant struct {
+	Group       string   `json:"group"`       // Group name
+	Permissions []string `json:"permissions"` // "resource:action" patterns, "*" matches any part
+}
+
+// LoadRoutePermissionPolicy reads the grant policy from a JSON file. A missing file yields
+// an empty policy, so created permissions are not granted to any group.
+func LoadRoutePermissionPolicy(path string) (*RoutePermissionPolicy, error) {
+	policy := &RoutePermissionPolicy{}
+	if path == "" {
+		return policy, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid route permission policy in %s: %w", path, err)
+	}
+	for i, grant := range policy.Grants {
+		if grant.Group == "" {
+			return nil, fmt.Errorf("grant %d in %s needs a group", i, path)
+		}
+		for _, pattern := range grant.Permissions {
+			if _, _, ok := permission.SplitKey(pattern); !ok {
+				return nil, fmt.Errorf("grant %d in %s has an invalid permission %q", i, path, pattern)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// SyncRoutePermissions makes sure every permission required by a registered route exists.
+// Missing permissions are created when create is true and granted to the groups the policy
+// names; otherwise they are only reported. It logs the routes whose permissions were missing.
+func SyncRoutePermissions(permissionRepo permission.Repository, groupRepo group.Repository, routes []middleware.RoutePermission, policy *RoutePermissionPolicy, create bool) error {
+	byKey := make(map[string][]middleware.RoutePermission)
+	var keys []string
+	for _, route := range routes {
+		if _, ok := byKey[route.Key()]; !ok {
+			keys = append(keys, route.Key())
+		}
+		byKey[route.Key()] = append(byKey[route.Key()], route)
+	}
+
+	var created []permission.Permission
+	missing := 0
+	for _, key := range keys {
+		resource, action, _ := permission.SplitKey(key)
+		existing, err := permissionRepo.GetPermissionByKey(resource, action)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if existing != nil && existing.StatusID != nil && *existing.StatusID == 0 {
+			continue
+		}
+
+		missing++
+		status := "missing"
+		switch {
+		case existing != nil:
+			status = "inactive"
+		case create:
+			perm, err := createRoutePermission(permissionRepo, resource, action)
+			if err != nil {
+				return fmt.Errorf("failed to create permission %s: %w", key, err)
+			}
+			created = append(created, *perm)
+			status = "created"
+		}
+		log.Printf("Route permission %s %s, required by %s", key, status, describeRoutes(byKey[key]))
+	}
+
+	if err := grantRoutePermissions(groupRepo, policy, created); err != nil {
+		return err
+	}
+	log.Printf("Route permissions: %d routes require %d permissions, %d missing, %d created", len(routes), len(keys), missing, len(created))
+	return nil
+}
+
+// createRoutePermission creates an active permission named after its action and resource,
+// e.g. "Read Examples", falling back to the "resource:action" key when the name is taken
+func createRoutePermission(permissionRepo permission.Repository, resource, action string) (*permission.Permission, error) {
+	perm := &permission.Permission{
+		Name:        capitalize(action) + " " + capitalize(resource),
+		Description: "Registered automatically for routes requiring " + resource + ":" + action,
+		Resource:    resource,
+		Action:      action,
+		StatusID:    int16Ptr(0),
+	}
+	if err := permissionRepo.CreatePermission(perm); err == nil {
+		return perm, nil
+	}
+
+	perm.ID = 0
+	perm.Name = resource + ":" + action
+	if err := permissionRepo.CreatePermission(perm); err != nil {
+		return nil, err
+	}
+	return perm, nil
+}
+
+// grantRoutePermissions grants the created permissions to the groups named by the policy
+func grantRoutePermissions(groupRepo group.Repository, policy *RoutePermissionPolicy, created []permission.Permission) error {
+	if policy == nil || len(created) == 0 {
+		return nil
+	}
+
+	for _, grant := range policy.Grants {
+		var granted []permission.Permission
+		var names []string
+		for _, perm := range created {
+			for _, pattern := range grant.Permissions {
+				if permission.KeyMatches(pattern, perm.Resource, perm.Action) {
+					granted = append(granted, perm)
+					names = append(names, perm.Resource+":"+perm.Action)
+					break
+				}
+			}
+		}
+		if len(granted) == 0 {
+			continue
+		}
+
+		target, err := groupRepo.GetGroupByName(grant.Group)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Warning: route permission policy names unknown group %q", grant.Group)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		target, err = groupRepo.GetGroupWithPermissions(target.ID)
+		if err != nil {
+			return err
+		}
+		ids := make([]uint, 0, len(target.Permissions)+len(granted))
+		for _, perm := range append(target.Permissions, granted...) {
+			ids = append(ids, perm.ID)
+		}
+		change := group.GroupPermissionVersion{Kind: group.VersionRouteSync}
+		if _, err := groupRepo.ReplaceGroupPermissions(target.ID, ids, change); err != nil {
+			return fmt.Errorf("failed to grant route permissions to group %s: %w", grant.Group, err)
+		}
+		log.Printf("Granted %s to group %s", strings.Join(names, ", "), grant.Group)
+	}
+	return nil
+}
+
+func describeRoutes(routes []middleware.RoutePermission) string {
+	described := make([]string, len(routes))
+	for i, route := range routes {
+		described[i] = route.Method + " " + route.Path
+	}
+	return strings.Join(described, ", ")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
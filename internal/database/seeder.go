@@ -8,6 +8,7 @@ import (
 	"apiserver/internal/modules/example"
 	"apiserver/internal/modules/group"
 	"apiserver/internal/modules/permission"
+	"apiserver/internal/utils"
 
 	"gorm.io/gorm"
 )
@@ -48,43 +49,46 @@ func timePtr(t time.Time) *time.Time {
 func seedUsers(db *gorm.DB) {
 	// Calculate dates for expiration examples
 	now := time.Now()
-	futureDate := now.AddDate(0, 3, 0)  // 3 months in future
-	pastDate := now.AddDate(0, -1, 0)   // 1 month in past
+	futureDate := now.AddDate(0, 3, 0) // 3 months in future
+	pastDate := now.AddDate(0, -1, 0)  // 1 month in past
 
 	users := []access.User{
 		{
-			Name:       "Admin User",
-			Email:      "admin@example.com",
-			APIKey:     "admin-api-key-789",
-			GroupID:    uintPtr(1),
-			StatusID:   int16Ptr(0), // Active
-			RateLimit:  1000,        // High rate limit for admin
+			Name:         "Admin User",
+			Email:        "admin@example.com",
+			APIKeyHash:   utils.HashAPIKey("admin-api-key-789"),
+			APIKeyPrefix: utils.APIKeyPrefix("admin-api-key-789"),
+			GroupID:      uintPtr(1),
+			StatusID:     int16Ptr(0), // Active
+			RateLimit:    1000,        // High rate limit for admin
 			// No ExpiredDate = never expires
 		},
 		{
-			Name:       "John Doe",
-			Email:      "john@example.com",
-			APIKey:     "test-api-key-123",
-			GroupID:    uintPtr(4),
-			StatusID:   int16Ptr(0), // Active
-			RateLimit:  120,          // Set to 10 for testing rate limit
-			ExpiredDate: timePtr(futureDate), // Expires in 3 months
+			Name:         "John Doe",
+			Email:        "john@example.com",
+			APIKeyHash:   utils.HashAPIKey("test-api-key-123"),
+			APIKeyPrefix: utils.APIKeyPrefix("test-api-key-123"),
+			GroupID:      uintPtr(4),
+			StatusID:     int16Ptr(0),         // Active
+			RateLimit:    120,                 // Set to 10 for testing rate limit
+			ExpiredDate:  timePtr(futureDate), // Expires in 3 months
 		},
 		{
-			Name:       "Jane Smith",
-			Email:      "jane@example.com",
-			RateLimit:  60,          // Lower rate limit
-			ExpiredDate: timePtr(pastDate), // Already expired
-			APIKey:   "test-api-key-456",
-			GroupID:   uintPtr(4),
-			StatusID:  int16Ptr(0), // Active
+			Name:         "Jane Smith",
+			Email:        "jane@example.com",
+			RateLimit:    60,                // Lower rate limit
+			ExpiredDate:  timePtr(pastDate), // Already expired
+			APIKeyHash:   utils.HashAPIKey("test-api-key-456"),
+			APIKeyPrefix: utils.APIKeyPrefix("test-api-key-456"),
+			GroupID:      uintPtr(4),
+			StatusID:     int16Ptr(0), // Active
 		},
 	}
 
 	for _, u := range users {
 		var existingUser access.User
 		result := db.Where("email = ?", u.Email).First(&existingUser)
-		
+
 		if result.Error == gorm.ErrRecordNotFound {
 			// Debug: Print status_id before create
 			log.Printf("Creating user %s with status_id: %d", u.Email, u.StatusID)
@@ -92,7 +96,7 @@ func seedUsers(db *gorm.DB) {
 			if err := db.Select("*").Create(&u).Error; err != nil {
 				log.Printf("Failed to create user %s: %v", u.Email, err)
 			} else {
-				log.Printf("Created user: %s with API key: %s", u.Email, u.APIKey)
+				log.Printf("Created user: %s with API key prefix: %s", u.Email, u.APIKeyPrefix)
 
 				// Debug: Verify status_id after create
 				var createdUser access.User
@@ -138,7 +142,7 @@ func seedExamples(db *gorm.DB) {
 	for _, e := range examples {
 		var existingExample example.Example
 		result := db.Where("name = ?", e.Name).First(&existingExample)
-		
+
 		if result.Error == gorm.ErrRecordNotFound {
 			if err := db.Create(&e).Error; err != nil {
 				log.Printf("Failed to create example %s: %v", e.Name, err)
@@ -153,6 +157,13 @@ func seedExamples(db *gorm.DB) {
 
 func seedPermissions(db *gorm.DB) {
 	permissions := []permission.Permission{
+		{
+			Name:        "All Permissions",
+			Description: "Wildcard permission granting every action on every resource",
+			Resource:    "*",
+			Action:      "*",
+			StatusID:    int16Ptr(0), // Active
+		},
 		{
 			Name:        "Create Examples",
 			Description: "Permission to create new examples",
@@ -230,6 +241,13 @@ func seedPermissions(db *gorm.DB) {
 			Action:      "manage",
 			StatusID:    int16Ptr(0), // Active
 		},
+		{
+			Name:        "Introspect Tokens",
+			Description: "Permission for services to validate presented API keys and tokens",
+			Resource:    "auth",
+			Action:      "introspect",
+			StatusID:    int16Ptr(0), // Active
+		},
 		// Configuration permissions (Admin only)
 		{
 			Name:        "Create Configurations",
@@ -295,8 +313,10 @@ func seedGroups(db *gorm.DB) {
 		permissionMap[p.Name] = p.ID
 	}
 
+	// Groups inherit the permissions of their parents: Admin extends Editor, which extends Viewer
 	groups := []struct {
 		Group       group.Group
+		Parents     []string
 		Permissions []string
 	}{
 		{
@@ -305,10 +325,11 @@ func seedGroups(db *gorm.DB) {
 				Description: "Full access to all resources",
 				StatusID:    int16Ptr(0), // Active
 			},
+			Parents: []string{"Editor"},
 			Permissions: []string{
-				"Create Examples", "Read Examples", "Update Examples", "Delete Examples",
-				"Manage Permissions", "Manage Groups", "View Profile",
-				"Read Audit Logs", "Manage Audit Logs", "Manage Access",
+				"All Permissions", "Delete Examples",
+				"Manage Permissions", "Manage Groups",
+				"Read Audit Logs", "Manage Audit Logs", "Manage Access", "Introspect Tokens",
 				"Create Configurations", "Read Configurations", "Update Configurations",
 				"Delete Configurations", "Manage Configurations",
 			},
@@ -319,8 +340,9 @@ func seedGroups(db *gorm.DB) {
 				Description: "Can create, read, and update examples",
 				StatusID:    int16Ptr(0), // Active
 			},
+			Parents: []string{"Viewer"},
 			Permissions: []string{
-				"Create Examples", "Read Examples", "Update Examples", "View Profile",
+				"Create Examples", "Update Examples",
 			},
 		},
 		{
@@ -345,6 +367,7 @@ func seedGroups(db *gorm.DB) {
 		},
 	}
 
+	created := make(map[string]uint)
 	for _, g := range groups {
 		var existingGroup group.Group
 		result := db.Where("name = ?", g.Group.Name).First(&existingGroup)
@@ -355,6 +378,7 @@ func seedGroups(db *gorm.DB) {
 				log.Printf("Failed to create group %s: %v", g.Group.Name, err)
 				continue
 			}
+			created[g.Group.Name] = g.Group.ID
 
 			// Assign permissions to group
 			var groupPermissions []permission.Permission
@@ -381,6 +405,24 @@ func seedGroups(db *gorm.DB) {
 		}
 	}
 
+	// Link parents once every group exists, so a group may extend one listed after it
+	for _, g := range groups {
+		groupID, ok := created[g.Group.Name]
+		if !ok || len(g.Parents) == 0 {
+			continue
+		}
+		var parents []group.Group
+		if err := db.Where("name IN ?", g.Parents).Find(&parents).Error; err != nil || len(parents) != len(g.Parents) {
+			log.Printf("Failed to find parents of group %s: %v", g.Group.Name, err)
+			continue
+		}
+		if err := db.Model(&group.Group{ID: groupID}).Association("Parents").Append(parents); err != nil {
+			log.Printf("Failed to assign parents to group %s: %v", g.Group.Name, err)
+		} else {
+			log.Printf("Group %s inherits from %v", g.Group.Name, g.Parents)
+		}
+	}
+
 	// Update users with groups
 	updateUsersWithGroups(db)
 }
@@ -408,4 +450,4 @@ func updateUsersWithGroups(db *gorm.DB) {
 			}
 		}
 	}
-}
\ No newline at end of file
+}
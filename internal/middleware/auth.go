@@ -1,15 +1,43 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 
 	"apiserver/internal/types"
+	"apiserver/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func NewAuthMiddleware(authRepo types.AuthRepository) fiber.Handler {
+// Locals set by the server when it replays an approved change on behalf of the access that proposed it.
+// Clients cannot set locals, so a request carrying them was built by the server.
+const (
+	LocalApprovedChange    = "approved_change"    // ID of the pending change being executed
+	LocalApprovedPrincipal = "approved_principal" // types.User that proposed it
+)
+
+// NewAuthMiddleware authenticates requests with a bearer API key or, when tokens is not nil, a bearer JWT.
+// With a non-nil signatures verifier, requests carrying signature headers are authenticated by HMAC instead.
+func NewAuthMiddleware(authRepo types.AuthRepository, signatures *SignatureVerifier, tokens types.TokenVerifier) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		// Approved changes run as the access that proposed them, which was loaded by the server
+		if access, ok := c.Locals(LocalApprovedPrincipal).(types.User); ok {
+			c.Locals("rate_limit_key", "approval:"+access.GetID())
+			setPrincipal(c, access)
+			return c.Next()
+		}
+
+		// Server-to-server callers may sign requests instead of sending a bearer token
+		if signatures != nil && c.Get(HeaderSignature) != "" {
+			access, err := signatures.Authenticate(c, authRepo)
+			if err != nil {
+				return authError(c, err)
+			}
+			c.Locals("rate_limit_key", "hmac:"+access.GetID())
+			return authorize(c, access)
+		}
+
 		// Get Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
@@ -36,21 +64,78 @@ func NewAuthMiddleware(authRepo types.AuthRepository) fiber.Handler {
 			})
 		}
 
+		// Access tokens are verified by signature, without a database lookup
+		if tokens != nil && utils.LooksLikeJWT(token) {
+			access, err := tokens.VerifyToken(token)
+			if err != nil {
+				return authError(c, err)
+			}
+			// Every token of an access shares one rate limit bucket
+			c.Locals("rate_limit_key", "jwt:"+access.GetID())
+			return authorize(c, access)
+		}
+
 		// Validate token against database
 		access, err := authRepo.FindByAPIKey(token)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Invalid or expired token",
-			})
+			return authError(c, err)
 		}
 
-		// Store user information in context
-		c.Locals("access_id", access.GetID())
-		c.Locals("access", access)
-		c.Locals("userID", access.GetID())
-		c.Locals("user", access)
+		return authorize(c, access)
+	}
+}
 
-		return c.Next()
+// authorize applies the checks shared by every authentication mode and stores the access in the context
+func authorize(c *fiber.Ctx, access types.User) error {
+	// Enforce the IP allowlist of the access and of the key used
+	if !access.IsIPAllowed(utils.ClientIP(c)) {
+		return authError(c, types.ErrIPNotAllowed)
 	}
-}
\ No newline at end of file
+
+	setPrincipal(c, access)
+	return c.Next()
+}
+
+// setPrincipal stores the authenticated access in the context
+func setPrincipal(c *fiber.Ctx, access types.User) {
+	c.Locals("access_id", access.GetID())
+	c.Locals("access", access)
+	c.Locals("userID", access.GetID())
+	c.Locals("user", access)
+}
+
+// authError maps an authentication failure to a response with a machine-readable code
+func authError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusUnauthorized
+	code := "invalid_api_key"
+	message := "Invalid or expired token"
+
+	switch {
+	case errors.Is(err, types.ErrAccessSuspended):
+		status, code, message = fiber.StatusForbidden, "access_suspended", "Access has been suspended"
+	case errors.Is(err, types.ErrAccessPending):
+		status, code, message = fiber.StatusForbidden, "access_pending", "Access is pending activation"
+	case errors.Is(err, types.ErrInvalidToken):
+		code, message = "invalid_token", "Invalid access token"
+	case errors.Is(err, types.ErrTokenExpired):
+		code, message = "token_expired", "Access token has expired"
+	case errors.Is(err, errInvalidSignature):
+		code, message = "invalid_signature", "Invalid request signature"
+	case errors.Is(err, errSignatureExpired):
+		code, message = "signature_expired", "Request timestamp is outside the allowed window"
+	case errors.Is(err, errNonceReused):
+		code, message = "nonce_reused", "Nonce has already been used"
+	case errors.Is(err, types.ErrIPNotAllowed):
+		status, code, message = fiber.StatusForbidden, "ip_not_allowed", "Access denied: IP address not allowed"
+	case errors.Is(err, types.ErrAccessRevoked):
+		code, message = "access_revoked", "Access has been revoked"
+	case errors.Is(err, types.ErrAccessExpired):
+		code, message = "access_expired", "Access has expired"
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"status":  "error",
+		"code":    code,
+		"message": message,
+	})
+}
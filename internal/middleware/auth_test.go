@@ -0,0 +1,238 @@
+// USAGE
+//   go test ./internal/middleware -v -run 'TestAuthMiddleware|TestRequirePermission'
+
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
+	"apiserver/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// testUser is an authenticated access with fixed permissions, scopes and allowlist
+type testUser struct {
+	id           string
+	permissions  []permission.Permission
+	denies       []permission.Permission
+	scopes       []string
+	allowedCIDRs []string
+}
+
+func (u *testUser) GetID() string                                 { return u.id }
+func (u *testUser) GetName() string                               { return u.id }
+func (u *testUser) GetEmail() string                              { return u.id + "@example.com" }
+func (u *testUser) GetGroup() *group.Group                        { return nil }
+func (u *testUser) GetGroups() []group.Group                      { return nil }
+func (u *testUser) GetPermissions() []permission.Permission       { return u.permissions }
+func (u *testUser) GetDeniedPermissions() []permission.Permission { return u.denies }
+func (u *testUser) GetRateLimit() int                             { return 120 }
+func (u *testUser) GetScopes() []string                           { return u.scopes }
+func (u *testUser) IsIPAllowed(ip string) bool {
+	return len(u.allowedCIDRs) == 0 || utils.IPInCIDRs(ip, u.allowedCIDRs)
+}
+
+// keyRepository authenticates the API keys it knows and fails the others with their error
+type keyRepository struct {
+	types.AuthRepository
+	users  map[string]types.User
+	errors map[string]error
+}
+
+func (r keyRepository) FindByAPIKey(apiKey string) (types.User, error) {
+	if err, ok := r.errors[apiKey]; ok {
+		return nil, err
+	}
+	if user, ok := r.users[apiKey]; ok {
+		return user, nil
+	}
+	return nil, types.ErrInvalidAPIKey
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	repo := keyRepository{
+		users: map[string]types.User{
+			"sk-open":    &testUser{id: "open"},
+			"sk-office":  &testUser{id: "office", allowedCIDRs: []string{"203.0.113.0/24"}},
+			"sk-partner": &testUser{id: "partner", allowedCIDRs: []string{"198.51.100.7/32", "2001:db8::/32"}},
+		},
+		errors: map[string]error{
+			"sk-revoked":   types.ErrAccessRevoked,
+			"sk-suspended": types.ErrAccessSuspended,
+			"sk-expired":   types.ErrAccessExpired,
+		},
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		clientIP      string
+		wantStatus    int
+		wantCode      string
+	}{
+		{name: "Missing header", wantStatus: fiber.StatusUnauthorized},
+		{name: "Not a bearer token", authorization: "Basic c2stb3Blbg==", wantStatus: fiber.StatusUnauthorized},
+		{name: "Unknown key", authorization: "Bearer sk-unknown", wantStatus: fiber.StatusUnauthorized, wantCode: "invalid_api_key"},
+		{name: "Revoked access", authorization: "Bearer sk-revoked", wantStatus: fiber.StatusUnauthorized, wantCode: "access_revoked"},
+		{name: "Suspended access", authorization: "Bearer sk-suspended", wantStatus: fiber.StatusForbidden, wantCode: "access_suspended"},
+		{name: "Expired access", authorization: "Bearer sk-expired", wantStatus: fiber.StatusUnauthorized, wantCode: "access_expired"},
+		{name: "No allowlist", authorization: "Bearer sk-open", clientIP: "192.0.2.1", wantStatus: fiber.StatusOK},
+		{name: "Inside the allowlist", authorization: "Bearer sk-office", clientIP: "203.0.113.42", wantStatus: fiber.StatusOK},
+		{name: "Outside the allowlist", authorization: "Bearer sk-office", clientIP: "192.0.2.1", wantStatus: fiber.StatusForbidden, wantCode: "ip_not_allowed"},
+		{name: "Single address allowlist", authorization: "Bearer sk-partner", clientIP: "198.51.100.7", wantStatus: fiber.StatusOK},
+		{name: "IPv6 allowlist", authorization: "Bearer sk-partner", clientIP: "2001:db8::1", wantStatus: fiber.StatusOK},
+		{name: "Neighbour of a single address", authorization: "Bearer sk-partner", clientIP: "198.51.100.8", wantStatus: fiber.StatusForbidden, wantCode: "ip_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/v1/examples", func(c *fiber.Ctx) error {
+				c.Locals(utils.LocalClientIP, tt.clientIP)
+				return c.Next()
+			}, NewAuthMiddleware(repo, nil, nil), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/v1/examples", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantCode != "" {
+				var body struct {
+					Code string `json:"code"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("invalid response: %v", err)
+				}
+				if body.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	perm := func(resource, action string) permission.Permission {
+		return permission.Permission{Resource: resource, Action: action}
+	}
+
+	tests := []struct {
+		name       string
+		user       *testUser
+		wantStatus int
+	}{
+		{name: "Exact grant", user: &testUser{id: "a", permissions: []permission.Permission{perm("examples", "delete")}}, wantStatus: fiber.StatusOK},
+		{name: "Manage implies delete", user: &testUser{id: "a", permissions: []permission.Permission{perm("examples", "manage")}}, wantStatus: fiber.StatusOK},
+		{name: "Wildcard", user: &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")}}, wantStatus: fiber.StatusOK},
+		{name: "Other action", user: &testUser{id: "a", permissions: []permission.Permission{perm("examples", "read")}}, wantStatus: fiber.StatusForbidden},
+		{name: "No permissions", user: &testUser{id: "a"}, wantStatus: fiber.StatusForbidden},
+		{name: "Deny wins over a wildcard", user: &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")},
+			denies: []permission.Permission{perm("examples", "delete")}}, wantStatus: fiber.StatusForbidden},
+		{name: "Key scope narrows a wildcard", user: &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")},
+			scopes: []string{"examples:read"}}, wantStatus: fiber.StatusForbidden},
+		{name: "Deny on a broader permission", user: &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")},
+			denies: []permission.Permission{perm("examples", "*")}}, wantStatus: fiber.StatusForbidden},
+		{name: "Deny on another action", user: &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")},
+			denies: []permission.Permission{perm("examples", "read")}}, wantStatus: fiber.StatusOK},
+		{name: "Key scope covering the route", user: &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")},
+			scopes: []string{"examples:*"}}, wantStatus: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Delete("/v1/examples/:id", func(c *fiber.Ctx) error {
+				c.Locals("user", tt.user)
+				return c.Next()
+			}, RequirePermission("examples", "delete"), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, "/v1/examples/1", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// A deny on part of what a route requires denies the route
+func TestRequirePermissionPartialDeny(t *testing.T) {
+	perm := func(resource, action string) permission.Permission {
+		return permission.Permission{Resource: resource, Action: action}
+	}
+	admin := &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")}}
+	restricted := &testUser{id: "a", permissions: []permission.Permission{perm("*", "*")},
+		denies: []permission.Permission{perm("configurations", "delete")}}
+
+	tests := []struct {
+		name        string
+		user        *testUser
+		require     fiber.Handler
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "Route requiring manage", user: restricted, require: RequirePermission("configurations", "manage"),
+			wantStatus: fiber.StatusForbidden, wantMessage: "Access denied: Deny rule matched (configurations:delete)"},
+		{name: "Route requiring manage without deny", user: admin, require: RequirePermission("configurations", "manage"),
+			wantStatus: fiber.StatusOK},
+		{name: "Route requiring read", user: restricted, require: RequirePermission("configurations", "read"),
+			wantStatus: fiber.StatusOK},
+		{name: "Any of manage only", user: restricted,
+			require:    RequireAnyPermission([]struct{ Resource, Action string }{{"configurations", "manage"}}),
+			wantStatus: fiber.StatusForbidden, wantMessage: "Access denied: Deny rule matched (configurations:delete)"},
+		{name: "Any of manage or read", user: restricted,
+			require:    RequireAnyPermission([]struct{ Resource, Action string }{{"configurations", "manage"}, {"configurations", "read"}}),
+			wantStatus: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Put("/v1/configurations/:id", func(c *fiber.Ctx) error {
+				c.Locals("user", tt.user)
+				return c.Next()
+			}, tt.require, func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodPut, "/v1/configurations/1", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			var body struct {
+				Message string `json:"message"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", body.Message, tt.wantMessage)
+			}
+		})
+	}
+}
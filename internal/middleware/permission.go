@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"apiserver/internal/modules/permission"
 	"apiserver/internal/types"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,42 +10,58 @@ import (
 // RequirePermission creates a middleware that checks if the user has the required permission
 func RequirePermission(resource, action string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get user from context (set by auth middleware)
-		user, ok := c.Locals("user").(types.User)
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"status":  "error",
-				"message": "User not authenticated",
-			})
+		if ok, err := AuthorizePermission(c, resource, action); !ok {
+			return err
 		}
+		return c.Next()
+	}
+}
 
-		// Check if user has a group
-		group := user.GetGroup()
-		if group == nil {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Access denied: No group assigned",
-			})
-		}
+// AuthorizePermission runs the checks of RequirePermission without continuing the chain.
+// When the access is not allowed it writes the error response and returns false.
+func AuthorizePermission(c *fiber.Ctx, resource, action string) (bool, error) {
+	// Get user from context (set by auth middleware)
+	user, ok := c.Locals("user").(types.User)
+	if !ok {
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not authenticated",
+		})
+	}
 
-		// Check if user's group has the required permission
-		hasPermission := false
-		for _, permission := range group.Permissions {
-			if permission.Resource == resource && permission.Action == action {
-				hasPermission = true
-				break
-			}
-		}
+	// Check if user has a group or a direct grant
+	granted := user.GetPermissions()
+	if len(granted) == 0 && len(user.GetGroups()) == 0 {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Access denied: No group assigned",
+		})
+	}
 
-		if !hasPermission {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"status":  "error",
-				"message": "Access denied: Insufficient permissions",
-			})
-		}
+	// Deny rules win over any allow. A deny on part of what the route requires, such as
+	// examples:delete on a route requiring examples:manage, denies the whole route.
+	required := permission.Permission{Resource: resource, Action: action}
+	if denied, ok := permission.FindOverlapping(user.GetDeniedPermissions(), required); ok {
+		return false, deniedByRule(c, denied)
+	}
 
-		return c.Next()
+	// Check the effective permissions of every group and direct grant (wildcards and "manage" included)
+	if !permission.Allows(granted, resource, action) {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Access denied: Insufficient permissions",
+		})
 	}
+
+	// A scoped key can only use the permissions listed in its scopes
+	if !inScope(user.GetScopes(), resource, action) {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Access denied: Permission outside of key scope",
+		})
+	}
+
+	return true, nil
 }
 
 // RequireAnyPermission creates a middleware that checks if the user has any of the required permissions
@@ -59,29 +76,38 @@ func RequireAnyPermission(permissions []struct{ Resource, Action string }) fiber
 			})
 		}
 
-		// Check if user has a group
-		group := user.GetGroup()
-		if group == nil {
+		// Check if user has a group or a direct grant
+		granted := user.GetPermissions()
+		if len(granted) == 0 && len(user.GetGroups()) == 0 {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Access denied: No group assigned",
 			})
 		}
 
-		// Check if user's group has any of the required permissions
+		// Check if the effective permissions include any of the required permissions that is not denied
 		hasPermission := false
-		for _, userPerm := range group.Permissions {
-			for _, reqPerm := range permissions {
-				if userPerm.Resource == reqPerm.Resource && userPerm.Action == reqPerm.Action {
-					hasPermission = true
-					break
+		var deniedBy *permission.Permission
+		scopes := user.GetScopes()
+		denies := user.GetDeniedPermissions()
+		for _, reqPerm := range permissions {
+			required := permission.Permission{Resource: reqPerm.Resource, Action: reqPerm.Action}
+			if denied, ok := permission.FindOverlapping(denies, required); ok {
+				if deniedBy == nil {
+					deniedBy = &denied
 				}
+				continue
 			}
-			if hasPermission {
+			if permission.Allows(granted, reqPerm.Resource, reqPerm.Action) &&
+				inScope(scopes, reqPerm.Resource, reqPerm.Action) {
+				hasPermission = true
 				break
 			}
 		}
 
+		if !hasPermission && deniedBy != nil {
+			return deniedByRule(c, *deniedBy)
+		}
 		if !hasPermission {
 			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 				"status":  "error",
@@ -91,4 +117,26 @@ func RequireAnyPermission(permissions []struct{ Resource, Action string }) fiber
 
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// deniedByRule rejects a request matched by a deny rule
+func deniedByRule(c *fiber.Ctx, denied permission.Permission) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Access denied: Deny rule matched (" + denied.Resource + ":" + denied.Action + ")",
+	})
+}
+
+// inScope reports whether resource:action is allowed by the scopes of a key.
+// Keys without scopes are not restricted.
+func inScope(scopes []string, resource, action string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		if permission.KeyMatches(scope, resource, action) {
+			return true
+		}
+	}
+	return false
+}
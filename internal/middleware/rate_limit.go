@@ -35,16 +35,20 @@ func RateLimitMiddleware(limiter *RateLimiter) fiber.Handler {
 			return c.Next()
 		}
 
-		// Extract API key from Authorization header
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Next()
-		}
+		// Use the key chosen by the auth middleware, e.g. for signed requests
+		apiKey, _ := c.Locals("rate_limit_key").(string)
+		if apiKey == "" {
+			// Extract API key from Authorization header
+			authHeader := c.Get("Authorization")
+			if authHeader == "" {
+				return c.Next()
+			}
 
-		// Remove "Bearer " prefix to get the actual API key
-		apiKey := authHeader
-		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-			apiKey = authHeader[7:]
+			// Remove "Bearer " prefix to get the actual API key
+			apiKey = authHeader
+			if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+				apiKey = authHeader[7:]
+			}
 		}
 
 		// Get rate limit for this user
@@ -81,7 +85,7 @@ func RateLimitMiddleware(limiter *RateLimiter) fiber.Handler {
 			c.Set("X-RateLimit-Limit", strconv.Itoa(rateLimit))
 			c.Set("X-RateLimit-Remaining", "0")
 			c.Set("X-RateLimit-Reset", strconv.FormatInt(oneMinuteAgo.Add(time.Minute).Unix(), 10))
-			
+
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Rate limit exceeded. Try again later.",
@@ -99,4 +103,32 @@ func RateLimitMiddleware(limiter *RateLimiter) fiber.Handler {
 
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// Remaining reports the unused budget of a rate limit key in the current window and when it resets,
+// without counting a request. Keys are the raw API key, "jwt:<access id>" or "hmac:<access id>".
+func (limiter *RateLimiter) Remaining(key string, limit int) (int, time.Time) {
+	limiter.RLock()
+	defer limiter.RUnlock()
+
+	now := time.Now()
+	oneMinuteAgo := now.Add(-time.Minute)
+
+	// The budget grows again once the oldest request in the window is a minute old
+	used := 0
+	reset := now
+	for _, t := range limiter.requests[key] {
+		if t.After(oneMinuteAgo) {
+			if used == 0 {
+				reset = t.Add(time.Minute)
+			}
+			used++
+		}
+	}
+
+	remaining := limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, reset
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+
+	"apiserver/internal/modules/permission"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RoutePermission is a resource/action pair required by a registered route
+type RoutePermission = permission.Route
+
+type permissionPair struct {
+	resource string
+	action   string
+}
+
+// RoutePermissions records the permissions that Register*Routes functions declare.
+// Its Require method is passed as their permissionMiddleware; every call is attached
+// to the route registered next, so the registry knows which route needs which permission.
+type RoutePermissions struct {
+	mu       sync.Mutex
+	pending  []permissionPair
+	headPath string
+	head     []permissionPair
+	routes   []RoutePermission
+	sealed   bool
+}
+
+// NewRoutePermissions creates a registry that captures routes added to the app
+func NewRoutePermissions(app *fiber.App) *RoutePermissions {
+	registry := &RoutePermissions{}
+	app.Hooks().OnRoute(registry.onRoute)
+	return registry
+}
+
+// Require returns RequirePermission for the pair and, until the registry is sealed,
+// records it for the route being registered
+func (r *RoutePermissions) Require(resource, action string) fiber.Handler {
+	r.mu.Lock()
+	if !r.sealed {
+		r.pending = append(r.pending, permissionPair{resource: resource, action: action})
+	}
+	r.mu.Unlock()
+	return RequirePermission(resource, action)
+}
+
+// Seal stops recording. Handlers that build permission checks per request,
+// such as forward-auth, are not routes and must not be recorded.
+func (r *RoutePermissions) Seal() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sealed = true
+	r.pending = nil
+}
+
+// Routes returns every recorded route permission sorted by path and method
+func (r *RoutePermissions) Routes() []RoutePermission {
+	r.mu.Lock()
+	routes := append([]RoutePermission(nil), r.routes...)
+	r.mu.Unlock()
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// onRoute attaches the pending permissions to a newly registered route. Fiber registers
+// a HEAD route before every GET route, so the pairs of a HEAD route are kept for the
+// GET route with the same path and HEAD itself is not reported.
+func (r *RoutePermissions) onRoute(route fiber.Route) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sealed {
+		return nil
+	}
+
+	pairs := r.pending
+	r.pending = nil
+	if route.Method == fiber.MethodHead {
+		r.headPath, r.head = route.Path, pairs
+		return nil
+	}
+	if route.Method == fiber.MethodGet && len(pairs) == 0 && route.Path == r.headPath {
+		pairs = r.head
+	}
+	r.headPath, r.head = "", nil
+
+	for _, pair := range pairs {
+		r.routes = append(r.routes, RoutePermission{
+			Method:   route.Method,
+			Path:     route.Path,
+			Resource: pair.resource,
+			Action:   pair.action,
+		})
+	}
+	return nil
+}
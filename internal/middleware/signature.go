@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"apiserver/internal/types"
+	"apiserver/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Headers of an HMAC signed request
+const (
+	HeaderSignatureKeyID     = "X-Signature-Key-Id"    // Access ID owning the signing secret
+	HeaderSignatureTimestamp = "X-Signature-Timestamp" // Unix seconds
+	HeaderSignatureNonce     = "X-Signature-Nonce"     // Unique per request
+	HeaderSignature          = "X-Signature"           // Hex HMAC-SHA256 of utils.RequestSigningString
+)
+
+// Errors of HMAC signed requests
+var (
+	errInvalidSignature = errors.New("invalid request signature")
+	errSignatureExpired = errors.New("request timestamp outside the allowed window")
+	errNonceReused      = errors.New("nonce already used")
+)
+
+// SignatureVerifier authenticates HMAC signed requests and remembers the nonces it has seen
+type SignatureVerifier struct {
+	sync.Mutex
+	maxSkew   time.Duration
+	nonces    map[string]time.Time // Map of access ID and nonce to the time it can be forgotten
+	lastSweep time.Time
+}
+
+// NewSignatureVerifier creates a verifier accepting timestamps within maxSkew of the server clock
+func NewSignatureVerifier(maxSkew time.Duration) *SignatureVerifier {
+	return &SignatureVerifier{
+		maxSkew:   maxSkew,
+		nonces:    make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Authenticate verifies the signature headers of a request and returns the signing access
+func (v *SignatureVerifier) Authenticate(c *fiber.Ctx, authRepo types.AuthRepository) (types.User, error) {
+	keyID := c.Get(HeaderSignatureKeyID)
+	timestamp := c.Get(HeaderSignatureTimestamp)
+	nonce := c.Get(HeaderSignatureNonce)
+	signature := c.Get(HeaderSignature)
+	if keyID == "" || timestamp == "" || nonce == "" || signature == "" || len(nonce) > 128 {
+		return nil, errInvalidSignature
+	}
+
+	// Reject timestamps outside the skew window
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errInvalidSignature
+	}
+	signedAt := time.Unix(unix, 0)
+	now := time.Now()
+	if signedAt.Before(now.Add(-v.maxSkew)) || signedAt.After(now.Add(v.maxSkew)) {
+		return nil, errSignatureExpired
+	}
+
+	access, secret, err := authRepo.FindSigningSecret(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !utils.VerifyRequestSignature(signature, secret, c.Method(), c.OriginalURL(), timestamp, nonce, c.Body()) {
+		return nil, errInvalidSignature
+	}
+
+	// Only remember nonces of valid signatures so they cannot be burned by third parties
+	if !v.useNonce(keyID+":"+nonce, signedAt.Add(v.maxSkew), now) {
+		return nil, errNonceReused
+	}
+
+	return access, nil
+}
+
+// useNonce records a nonce and reports false if it was already used.
+// A nonce only needs to be remembered until its timestamp leaves the skew window.
+func (v *SignatureVerifier) useNonce(key string, forgetAt, now time.Time) bool {
+	v.Lock()
+	defer v.Unlock()
+
+	// Clean up expired nonces
+	if now.Sub(v.lastSweep) > v.maxSkew {
+		for k, t := range v.nonces {
+			if now.After(t) {
+				delete(v.nonces, k)
+			}
+		}
+		v.lastSweep = now
+	}
+
+	if t, exists := v.nonces[key]; exists && !now.After(t) {
+		return false
+	}
+	v.nonces[key] = forgetAt
+	return true
+}
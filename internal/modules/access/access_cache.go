@@ -0,0 +1,110 @@
+package access
+
+import (
+	"time"
+
+	"apiserver/internal/cache"
+	"apiserver/internal/types"
+	"apiserver/internal/utils"
+)
+
+// cachedPrincipal is a resolved access together with its HMAC signing secret, if any
+type cachedPrincipal struct {
+	user   *User
+	secret string
+}
+
+// CachedAuthRepository caches the principals resolved by an AuthRepository.
+// Entries are keyed by API key digest (or access ID for signing secrets) and are
+// dropped explicitly through the cache.Invalidator methods when their source data changes.
+// Named key last-used timestamps are therefore refreshed at most once per TTL.
+type CachedAuthRepository struct {
+	next       types.AuthRepository
+	principals *cache.LRU[string, cachedPrincipal]
+}
+
+// NewCachedAuthRepository wraps an AuthRepository with an LRU cache of the given size and TTL
+func NewCachedAuthRepository(next types.AuthRepository, size int, ttl time.Duration) *CachedAuthRepository {
+	return &CachedAuthRepository{
+		next:       next,
+		principals: cache.NewLRU[string, cachedPrincipal](size, ttl),
+	}
+}
+
+func (r *CachedAuthRepository) FindByAPIKey(apiKey string) (types.User, error) {
+	cacheKey := "key:" + utils.HashAPIKey(apiKey)
+	if cached, ok := r.principals.Get(cacheKey); ok {
+		// Expiry must apply immediately even while the entry is cached
+		if err := cached.user.checkCachedAccess(time.Now()); err == nil {
+			return cached.user, nil
+		}
+		r.principals.Delete(cacheKey)
+	}
+
+	user, err := r.next.FindByAPIKey(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if accessUser, ok := user.(*User); ok {
+		r.principals.Set(cacheKey, cachedPrincipal{user: accessUser})
+	}
+	return user, nil
+}
+
+func (r *CachedAuthRepository) FindSigningSecret(accessID string) (types.User, string, error) {
+	cacheKey := "hmac:" + accessID
+	if cached, ok := r.principals.Get(cacheKey); ok {
+		if err := cached.user.checkCachedAccess(time.Now()); err == nil {
+			return cached.user, cached.secret, nil
+		}
+		r.principals.Delete(cacheKey)
+	}
+
+	user, secret, err := r.next.FindSigningSecret(accessID)
+	if err != nil {
+		return nil, "", err
+	}
+	if accessUser, ok := user.(*User); ok {
+		r.principals.Set(cacheKey, cachedPrincipal{user: accessUser, secret: secret})
+	}
+	return user, secret, nil
+}
+
+// InvalidateAccess drops every cached principal of an access
+func (r *CachedAuthRepository) InvalidateAccess(accessID string) {
+	r.principals.DeleteFunc(func(_ string, p cachedPrincipal) bool {
+		return p.user.ID == accessID
+	})
+}
+
+// InvalidateGroup drops every cached principal that is a member of a group
+func (r *CachedAuthRepository) InvalidateGroup(groupID uint) {
+	r.principals.DeleteFunc(func(_ string, p cachedPrincipal) bool {
+		return memberOf(p.user, groupID)
+	})
+}
+
+// InvalidateAll drops every cached principal
+func (r *CachedAuthRepository) InvalidateAll() {
+	r.principals.Purge()
+}
+
+// Stats returns the hit and miss counters of the cache
+func (r *CachedAuthRepository) Stats() cache.Stats {
+	return r.principals.Stats()
+}
+
+// checkCachedAccess re-applies the time based rules of FindByAPIKey to a cached principal
+func (u *User) checkCachedAccess(now time.Time) error {
+	if err := u.CheckAccess(now); err != nil {
+		return err
+	}
+	if u.Credential == CredentialPrevious &&
+		(u.PreviousAPIKeyExpiresAt == nil || !u.PreviousAPIKeyExpiresAt.After(now)) {
+		return types.ErrInvalidAPIKey
+	}
+	if u.ActiveKey != nil {
+		return u.ActiveKey.CheckAccess(now)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package access
+
+import (
+	"log"
+	"time"
+
+	"apiserver/internal/cache"
+	"apiserver/internal/modules/group"
+)
+
+// SweepExpiredGrants deletes the temporary grants that expired at or before now, drops the cached
+// principals they applied to and reports each of them to onExpired
+func SweepExpiredGrants(repo Repository, groupRepo group.Repository, invalidator cache.Invalidator, now time.Time, onExpired func(TemporaryGrant)) error {
+	expired, err := repo.DeleteExpiredTemporaryGrants(now)
+	if err != nil {
+		return err
+	}
+
+	for _, grant := range expired {
+		switch {
+		case grant.AccessID != nil:
+			invalidator.InvalidateAccess(*grant.AccessID)
+		case grant.GroupID != nil:
+			invalidateGroupTree(groupRepo, invalidator, *grant.GroupID)
+		}
+		if onExpired != nil {
+			onExpired(grant)
+		}
+	}
+	return nil
+}
+
+// StartGrantSweep periodically removes expired temporary grants. Expired grants already stop
+// counting in permission checks, the sweep keeps the table small and records their expiry.
+func StartGrantSweep(repo Repository, groupRepo group.Repository, invalidator cache.Invalidator, interval time.Duration, onExpired func(TemporaryGrant)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if err := SweepExpiredGrants(repo, groupRepo, invalidator, now, onExpired); err != nil {
+				log.Printf("Failed to sweep expired temporary grants: %v", err)
+			}
+		}
+	}()
+}
+
+// invalidateGroupTree drops the cached members of a group and of every group inheriting from it.
+// Everything is dropped when the descendants cannot be loaded.
+func invalidateGroupTree(groupRepo group.Repository, invalidator cache.Invalidator, id uint) {
+	descendants, err := groupRepo.GetDescendantIDs(id)
+	if err != nil {
+		invalidator.InvalidateAll()
+		return
+	}
+	invalidator.InvalidateGroup(id)
+	for _, descendantID := range descendants {
+		invalidator.InvalidateGroup(descendantID)
+	}
+}
@@ -0,0 +1,84 @@
+// USAGE
+//   go test ./internal/modules/access -v -run TestSweepExpiredGrants
+
+package access
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"apiserver/internal/modules/group"
+)
+
+type expiringRepository struct {
+	Repository
+	expired []TemporaryGrant
+}
+
+func (r *expiringRepository) DeleteExpiredTemporaryGrants(time.Time) ([]TemporaryGrant, error) {
+	return r.expired, nil
+}
+
+// groupTree knows that group 1 is inherited by 2, which is inherited by 3
+type groupTree struct {
+	group.Repository
+	err error
+}
+
+func (r groupTree) GetDescendantIDs(id uint) ([]uint, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if id == 1 {
+		return []uint{2, 3}, nil
+	}
+	return nil, nil
+}
+
+// recordingInvalidator records what was dropped from the cache
+type recordingInvalidator struct {
+	accesses []string
+	groups   []uint
+	all      bool
+}
+
+func (r *recordingInvalidator) InvalidateAccess(id string) { r.accesses = append(r.accesses, id) }
+func (r *recordingInvalidator) InvalidateGroup(id uint)    { r.groups = append(r.groups, id) }
+func (r *recordingInvalidator) InvalidateAll()             { r.all = true }
+
+func TestSweepExpiredGrants(t *testing.T) {
+	accessID, groupID := "access", uint(1)
+	tests := []struct {
+		name         string
+		grants       []TemporaryGrant
+		treeErr      error
+		wantAccesses []string
+		wantGroups   []uint
+		wantAll      bool
+	}{
+		{name: "Grant to an access", grants: []TemporaryGrant{{ID: "a", AccessID: &accessID}}, wantAccesses: []string{"access"}},
+		{name: "Grant to a group with descendants", grants: []TemporaryGrant{{ID: "g", GroupID: &groupID}}, wantGroups: []uint{1, 2, 3}},
+		{name: "Descendants cannot be loaded", grants: []TemporaryGrant{{ID: "g", GroupID: &groupID}}, treeErr: errors.New("db down"), wantAll: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invalidator := &recordingInvalidator{}
+			var reported []string
+			err := SweepExpiredGrants(&expiringRepository{expired: tt.grants}, groupTree{err: tt.treeErr}, invalidator, time.Now(),
+				func(grant TemporaryGrant) { reported = append(reported, grant.ID) })
+			if err != nil {
+				t.Fatalf("sweep failed: %v", err)
+			}
+			if !reflect.DeepEqual(invalidator.accesses, tt.wantAccesses) || !reflect.DeepEqual(invalidator.groups, tt.wantGroups) || invalidator.all != tt.wantAll {
+				t.Errorf("invalidated accesses %v, groups %v, all %v; want %v, %v, %v",
+					invalidator.accesses, invalidator.groups, invalidator.all, tt.wantAccesses, tt.wantGroups, tt.wantAll)
+			}
+			if len(reported) != len(tt.grants) {
+				t.Errorf("reported %d expired grants, want %d", len(reported), len(tt.grants))
+			}
+		})
+	}
+}
@@ -1,23 +1,43 @@
 package access
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"apiserver/internal/cache"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
 	"apiserver/internal/utils"
 
 	"github.com/go-playground/validator"
 	"github.com/gofiber/fiber/v2"
 )
 
+// defaultGroupID is the generic client group assigned when none is requested
+const defaultGroupID uint = 4
+
 type Handler struct {
-	repo      Repository
-	validator *validator.Validate
+	repo             Repository
+	groupRepo        group.Repository
+	permissionRepo   permission.Repository
+	invalidator      cache.Invalidator
+	validator        *validator.Validate
+	keyRotationGrace time.Duration
+	maxGrantDuration time.Duration // Longest lifetime of a temporary grant
 }
 
-func NewHandler(repo Repository) *Handler {
+func NewHandler(repo Repository, groupRepo group.Repository, permissionRepo permission.Repository, invalidator cache.Invalidator, keyRotationGrace, maxGrantDuration time.Duration) *Handler {
 	return &Handler{
-		repo:      repo,
-		validator: validator.New(),
+		repo:             repo,
+		groupRepo:        groupRepo,
+		permissionRepo:   permissionRepo,
+		invalidator:      invalidator,
+		validator:        validator.New(),
+		keyRotationGrace: keyRotationGrace,
+		maxGrantDuration: maxGrantDuration,
 	}
 }
 
@@ -37,7 +57,7 @@ func NewHandler(repo Repository) *Handler {
 func (h *Handler) GetProfile(c *fiber.Ctx) error {
 	// Get user data from middleware
 	user := c.Locals("user").(*User)
-	
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
 		"data":   user,
@@ -110,6 +130,9 @@ func (h *Handler) UpdateExpiredDate(c *fiber.Ctx) error {
 		})
 	}
 
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
 	// Return success response
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
@@ -164,6 +187,9 @@ func (h *Handler) RemoveExpiredDate(c *fiber.Ctx) error {
 		})
 	}
 
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
 	// Return success response
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
@@ -237,6 +263,9 @@ func (h *Handler) UpdateRateLimit(c *fiber.Ctx) error {
 		})
 	}
 
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
 	// Return success response
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
@@ -258,6 +287,7 @@ func (h *Handler) UpdateRateLimit(c *fiber.Ctx) error {
 // @Param data body CreateAccessRequest true "Access creation data"
 // @Success 201 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /v1/access [post]
 // SWAGGER_ACCESS_END
@@ -284,21 +314,46 @@ func (h *Handler) CreateAccess(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate API key
-	apiKey := utils.GenerateAPIKey()
+	// Check that the group can be assigned by the current admin
+	groupID := defaultGroupID
+	if req.GroupID != nil {
+		groupID = *req.GroupID
+	}
+	if _, err := h.assignableGroup(c, groupID); err != nil {
+		return err
+	}
 
 	// Set default expiration date (6 months from now)
 	expiredDate := time.Now().AddDate(0, 6, 0)
+	if req.ExpiredDate != nil {
+		if req.ExpiredDate.Before(time.Now()) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Expiration date must be in the future",
+			})
+		}
+		expiredDate = *req.ExpiredDate
+	}
+
+	// Set default rate limit
+	rateLimit := 120
+	if req.RateLimit != nil {
+		rateLimit = *req.RateLimit
+	}
+
+	// Generate API key
+	apiKey := utils.GenerateAPIKey()
 
-	// Create new user
+	// Create new user (only the digest and prefix of the key are stored)
 	access := &User{
-		Name:        req.FullName,
-		Email:       req.Email,
-		APIKey:      apiKey,
-		GroupID:     utils.UintPtr(4), // Default group_id: 4 (generic client)
-		ExpiredDate: &expiredDate,
-		RateLimit:   120, // Default rate limit: 120
-		StatusID:    utils.Int16Ptr(0), // Active status
+		Name:         req.FullName,
+		Email:        req.Email,
+		APIKeyHash:   utils.HashAPIKey(apiKey),
+		APIKeyPrefix: utils.APIKeyPrefix(apiKey),
+		GroupID:      &groupID,
+		ExpiredDate:  &expiredDate,
+		RateLimit:    rateLimit,
+		StatusID:     utils.Int16Ptr(0), // Active status
 	}
 
 	// Save to database
@@ -309,12 +364,12 @@ func (h *Handler) CreateAccess(c *fiber.Ctx) error {
 		})
 	}
 
-	// Prepare response
+	// Prepare response (the only place the full API key is ever returned)
 	response := CreateAccessResponse{
 		ID:          access.ID,
 		APIKey:      apiKey,
 		ExpiredDate: &expiredDate,
-		RateLimit:   120,
+		RateLimit:   rateLimit,
 	}
 
 	// Return success response
@@ -322,4 +377,1864 @@ func (h *Handler) CreateAccess(c *fiber.Ctx) error {
 		"status": "success",
 		"data":   response,
 	})
-}
\ No newline at end of file
+}
+
+// RotateKey godoc
+// SWAGGER_ACCESS_START
+// @Summary Rotate API key
+// @Description Issue a new API key for an access. The old key stays valid for the configured grace period. Admins can only rotate the key of an access whose permissions they hold.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} RotateKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/rotate-key [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) RotateKey(c *fiber.Ctx) error {
+	// Parse user ID from path
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID",
+		})
+	}
+
+	// Check if user exists
+	user, err := h.repo.GetUserByID(id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+	if err := h.checkKeyIssuable(c, user.ID); err != nil {
+		return err
+	}
+
+	return h.rotateKey(c, user)
+}
+
+// RotateOwnKey godoc
+// SWAGGER_ACCESS_START
+// @Summary Rotate own API key
+// @Description Issue a new API key for the current user. The old key stays valid for the configured grace period.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} RotateKeyResponse
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/profile/rotate-key [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) RotateOwnKey(c *fiber.Ctx) error {
+	// Get user data from middleware
+	user := c.Locals("user").(*User)
+
+	return h.rotateKey(c, user)
+}
+
+// rotateKey issues a new API key and keeps the current one valid for the grace period
+func (h *Handler) rotateKey(c *fiber.Ctx, user *User) error {
+	apiKey := utils.GenerateAPIKey()
+
+	var previousValidUntil *time.Time
+	if h.keyRotationGrace > 0 {
+		validUntil := time.Now().Add(h.keyRotationGrace)
+		previousValidUntil = &validUntil
+	}
+
+	if err := h.repo.RotateAPIKey(user.ID, apiKey, previousValidUntil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to rotate API key",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	// Return the new key; it is never retrievable again
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": RotateKeyResponse{
+			ID:                      user.ID,
+			APIKey:                  apiKey,
+			APIKeyPrefix:            utils.APIKeyPrefix(apiKey),
+			PreviousAPIKeyExpiresAt: previousValidUntil,
+		},
+	})
+}
+
+// ListKeys godoc
+// SWAGGER_ACCESS_START
+// @Summary List named API keys
+// @Description List the named API keys of an access, including revoked keys
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {array} AccessKey
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/keys [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) ListKeys(c *fiber.Ctx) error {
+	// Check if user exists
+	user, err := h.repo.GetUserByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	keys, err := h.repo.GetKeys(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch keys",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   keys,
+	})
+}
+
+// CreateKey godoc
+// SWAGGER_ACCESS_START
+// @Summary Create named API key
+// @Description Create an additional named API key for an access. The key is only returned once. Admins can only create keys for an access whose permissions they hold.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body CreateAccessKeyRequest true "Key data"
+// @Success 201 {object} CreateAccessKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/keys [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) CreateKey(c *fiber.Ctx) error {
+	// Parse request body
+	var req CreateAccessKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+	if err := validateKeySettings(req.Scopes, req.ExpiredDate); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	// Check if user exists
+	user, err := h.repo.GetUserByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+	if err := h.checkKeyIssuable(c, user.ID); err != nil {
+		return err
+	}
+
+	// Generate API key; only its digest and prefix are stored
+	apiKey := utils.GenerateAPIKey()
+	key := &AccessKey{
+		AccessID:    user.ID,
+		Name:        strings.TrimSpace(req.Name),
+		KeyHash:     utils.HashAPIKey(apiKey),
+		KeyPrefix:   utils.APIKeyPrefix(apiKey),
+		Scopes:      req.Scopes,
+		ExpiredDate: req.ExpiredDate,
+		RateLimit:   req.RateLimit,
+		StatusID:    utils.Int16Ptr(0), // Active status
+	}
+
+	if err := h.repo.CreateKey(key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data": CreateAccessKeyResponse{
+			AccessKey: *key,
+			APIKey:    apiKey,
+		},
+	})
+}
+
+// GetKey godoc
+// SWAGGER_ACCESS_START
+// @Summary Get named API key
+// @Description Get a named API key of an access
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param keyId path string true "Key ID"
+// @Success 200 {object} AccessKey
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/access/{id}/keys/{keyId} [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetKey(c *fiber.Ctx) error {
+	key, err := h.repo.GetKey(c.Params("id"), c.Params("keyId"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Key not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   key,
+	})
+}
+
+// UpdateKey godoc
+// SWAGGER_ACCESS_START
+// @Summary Update named API key
+// @Description Update the name, scopes, expiration date and rate limit of a named API key
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param keyId path string true "Key ID"
+// @Param data body UpdateAccessKeyRequest true "Key data"
+// @Success 200 {object} AccessKey
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/keys/{keyId} [put]
+// SWAGGER_ACCESS_END
+func (h *Handler) UpdateKey(c *fiber.Ctx) error {
+	// Parse request body
+	var req UpdateAccessKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+	if err := validateKeySettings(req.Scopes, req.ExpiredDate); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	key, err := h.repo.GetKey(c.Params("id"), c.Params("keyId"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Key not found",
+		})
+	}
+
+	if key.RevokedAt != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Revoked keys cannot be updated",
+		})
+	}
+
+	key.Name = strings.TrimSpace(req.Name)
+	key.Scopes = req.Scopes
+	key.ExpiredDate = req.ExpiredDate
+	key.RateLimit = req.RateLimit
+
+	if err := h.repo.UpdateKey(key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update key",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(key.AccessID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   key,
+	})
+}
+
+// RevokeKey godoc
+// SWAGGER_ACCESS_START
+// @Summary Revoke named API key
+// @Description Permanently revoke a named API key
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param keyId path string true "Key ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/keys/{keyId} [delete]
+// SWAGGER_ACCESS_END
+func (h *Handler) RevokeKey(c *fiber.Ctx) error {
+	key, err := h.repo.GetKey(c.Params("id"), c.Params("keyId"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Key not found",
+		})
+	}
+
+	if err := h.repo.RevokeKey(key.AccessID, key.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke key",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(key.AccessID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Key revoked successfully",
+	})
+}
+
+// validateKeySettings checks the scopes and expiration date of a named key
+func validateKeySettings(scopes []string, expiredDate *time.Time) error {
+	for _, scope := range scopes {
+		resource, action, ok := permission.SplitKey(scope)
+		if !ok || strings.TrimSpace(resource) == "" || strings.TrimSpace(action) == "" {
+			return fmt.Errorf("Invalid scope '%s', expected resource:action", scope)
+		}
+	}
+
+	if expiredDate != nil && expiredDate.Before(time.Now()) {
+		return fmt.Errorf("Expiration date must be in the future")
+	}
+
+	return nil
+}
+
+// ListAccesses godoc
+// SWAGGER_ACCESS_START
+// @Summary List accesses
+// @Description List accesses with search, filtering and pagination
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Search by name or email"
+// @Param group_id query int false "Filter by group ID"
+// @Param status_id query int false "Filter by status ID"
+// @Param expired query bool false "Filter by expired (true) or not expired (false)"
+// @Param expiring_within_days query int false "Only accesses expiring within this many days"
+// @Param limit query int false "Limit results (default: 50, max: 1000)"
+// @Param offset query int false "Offset for pagination"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) ListAccesses(c *fiber.Ctx) error {
+	filter := AccessFilter{
+		Search: strings.TrimSpace(c.Query("q")),
+	}
+
+	if groupID := c.Query("group_id"); groupID != "" {
+		id, err := strconv.ParseUint(groupID, 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid group_id parameter",
+			})
+		}
+		filter.GroupID = utils.UintPtr(uint(id))
+	}
+
+	if statusID := c.Query("status_id"); statusID != "" {
+		status, err := strconv.ParseInt(statusID, 10, 16)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid status_id parameter",
+			})
+		}
+		filter.StatusID = utils.Int16Ptr(int16(status))
+	}
+
+	if expired := c.Query("expired"); expired != "" {
+		value, err := strconv.ParseBool(expired)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid expired parameter",
+			})
+		}
+		filter.Expired = &value
+	}
+
+	if days := c.Query("expiring_within_days"); days != "" {
+		value, err := strconv.Atoi(days)
+		if err != nil || value <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid expiring_within_days parameter",
+			})
+		}
+		filter.ExpiringWithinDays = value
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = l
+		}
+	}
+
+	if offset := c.Query("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil {
+			filter.Offset = o
+		}
+	}
+
+	// Echo the page actually returned
+	filter.Paginate()
+
+	accesses, total, err := h.repo.ListAccesses(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch accesses",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"accesses": accesses,
+			"total":    total,
+			"limit":    filter.Limit,
+			"offset":   filter.Offset,
+		},
+	})
+}
+
+// GetAccess godoc
+// SWAGGER_ACCESS_START
+// @Summary Get access by ID
+// @Description Get a specific access by its ID, regardless of its status
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} User
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/access/{id} [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetAccess(c *fiber.Ctx) error {
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   user,
+	})
+}
+
+// SuspendAccess godoc
+// SWAGGER_ACCESS_START
+// @Summary Suspend access
+// @Description Temporarily suspend an active access. A reason is required.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body ChangeStatusRequest true "Reason for the suspension"
+// @Success 200 {object} StatusChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/suspend [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) SuspendAccess(c *fiber.Ctx) error {
+	return h.changeStatus(c, types.StatusSuspended, true, types.StatusActive)
+}
+
+// ReactivateAccess godoc
+// SWAGGER_ACCESS_START
+// @Summary Reactivate access
+// @Description Reactivate a suspended or pending access
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body ChangeStatusRequest false "Reason for the reactivation"
+// @Success 200 {object} StatusChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/reactivate [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) ReactivateAccess(c *fiber.Ctx) error {
+	return h.changeStatus(c, types.StatusActive, false, types.StatusSuspended, types.StatusPending)
+}
+
+// RevokeAccess godoc
+// SWAGGER_ACCESS_START
+// @Summary Revoke access
+// @Description Permanently revoke an access. A revoked access cannot be reactivated. A reason is required.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body ChangeStatusRequest true "Reason for the revocation"
+// @Success 200 {object} StatusChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/revoke [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) RevokeAccess(c *fiber.Ctx) error {
+	return h.changeStatus(c, types.StatusInactive, true, types.StatusActive, types.StatusSuspended, types.StatusPending)
+}
+
+// GetStatusHistory godoc
+// SWAGGER_ACCESS_START
+// @Summary Get access status history
+// @Description List the status changes of an access with actor, reason and timestamp
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {array} StatusChange
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/status-history [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetStatusHistory(c *fiber.Ctx) error {
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	changes, err := h.repo.GetStatusHistory(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch status history",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   changes,
+	})
+}
+
+// changeStatus moves an access to a new status if its current status is one of allowedFrom
+func (h *Handler) changeStatus(c *fiber.Ctx, toStatus int16, reasonRequired bool, allowedFrom ...int16) error {
+	// Parse request body (optional when no reason is required)
+	var req ChangeStatusRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if reasonRequired && req.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Reason is required",
+		})
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	// Prevent admins from locking themselves out
+	actorID := currentAccessID(c)
+	if actorID == user.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "You cannot change the status of your own access",
+		})
+	}
+
+	// Check that the transition is allowed from the current status
+	fromStatus := types.StatusInactive
+	if user.StatusID != nil {
+		fromStatus = *user.StatusID
+	}
+	allowed := false
+	for _, status := range allowedFrom {
+		if status == fromStatus {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status": "error",
+			"message": fmt.Sprintf("Cannot change status from %s to %s",
+				types.GetStatusDescription(fromStatus), types.GetStatusDescription(toStatus)),
+		})
+	}
+
+	change := &StatusChange{
+		AccessID:     user.ID,
+		FromStatusID: fromStatus,
+		ToStatusID:   toStatus,
+		Reason:       req.Reason,
+	}
+	if actorID != "" {
+		change.ActorID = &actorID
+	}
+
+	if err := h.repo.ChangeStatus(change); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to change access status",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   change,
+	})
+}
+
+// currentAccessID returns the ID of the authenticated access making the request
+func currentAccessID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("access_id").(string); ok {
+		return id
+	}
+	return ""
+}
+
+// UpdateGroup godoc
+// SWAGGER_ACCESS_START
+// @Summary Change access group
+// @Description Assign an access to another group. The group must be active and must not grant more than the current admin's own permissions.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body UpdateGroupRequest true "Group assignment data"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/group [put]
+// SWAGGER_ACCESS_END
+func (h *Handler) UpdateGroup(c *fiber.Ctx) error {
+	// Parse request body
+	var req UpdateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	// Check that the group can be assigned by the current admin
+	if _, err := h.assignableGroup(c, req.GroupID); err != nil {
+		return err
+	}
+
+	if err := h.repo.UpdateGroup(user.ID, req.GroupID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update group",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	// Reload to return the new group
+	updated, err := h.repo.GetAccessByID(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch access",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Group updated successfully",
+		"data":    updated,
+	})
+}
+
+// AddGroup godoc
+// SWAGGER_ACCESS_START
+// @Summary Add access to a group
+// @Description Add an access to an additional group. The access keeps its primary group and gets the union of the permissions of all its groups.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body AddGroupRequest true "Group membership data"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/groups [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) AddGroup(c *fiber.Ctx) error {
+	// Parse request body
+	var req AddGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	if memberOf(user, req.GroupID) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Access already belongs to this group",
+		})
+	}
+
+	// Check that the group can be assigned by the current admin
+	target, err := h.assignableGroup(c, req.GroupID)
+	if err != nil {
+		return err
+	}
+
+	if err := h.repo.AddGroup(user.ID, target); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to add group",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return h.respondWithAccess(c, user.ID, "Group added successfully")
+}
+
+// RemoveGroup godoc
+// SWAGGER_ACCESS_START
+// @Summary Remove access from a group
+// @Description Remove an access from one of its additional groups. The primary group can only be changed with PUT /v1/access/{id}/group.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param groupId path int true "Group ID"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/groups/{groupId} [delete]
+// SWAGGER_ACCESS_END
+func (h *Handler) RemoveGroup(c *fiber.Ctx) error {
+	groupID, err := strconv.ParseUint(c.Params("groupId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	if user.GroupID != nil && *user.GroupID == uint(groupID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Cannot remove the primary group, assign another group instead",
+		})
+	}
+	if !memberOf(user, uint(groupID)) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Access does not belong to this group",
+		})
+	}
+
+	if err := h.repo.RemoveGroup(user.ID, uint(groupID)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to remove group",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return h.respondWithAccess(c, user.ID, "Group removed successfully")
+}
+
+// GrantPermission godoc
+// SWAGGER_ACCESS_START
+// @Summary Grant a permission to an access
+// @Description Grant a permission directly to an access, in addition to the permissions of its groups. The current admin must hold the permission.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body GrantPermissionRequest true "Permission grant data"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/permissions [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) GrantPermission(c *fiber.Ctx) error {
+	// Parse request body
+	var req GrantPermissionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	target, err := h.permissionRepo.GetPermissionByID(req.PermissionID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Permission not found or inactive",
+		})
+	}
+
+	for _, p := range user.DirectPermissions {
+		if p.ID == target.ID {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Permission is already granted to this access",
+			})
+		}
+	}
+
+	// Admins can only grant permissions they hold themselves
+	actor, ok := c.Locals("user").(types.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not authenticated",
+		})
+	}
+	if missing := missingPermissions(actor, []permission.Permission{*target}); len(missing) > 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Cannot grant a permission you do not hold: " + strings.Join(missing, ", "),
+		})
+	}
+
+	if err := h.repo.GrantPermission(user.ID, target); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to grant permission",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return h.respondWithAccess(c, user.ID, "Permission granted successfully")
+}
+
+// RevokePermission godoc
+// SWAGGER_ACCESS_START
+// @Summary Revoke a direct permission
+// @Description Remove a permission granted directly to an access. Permissions inherited from its groups are not affected.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param permissionId path int true "Permission ID"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/permissions/{permissionId} [delete]
+// SWAGGER_ACCESS_END
+func (h *Handler) RevokePermission(c *fiber.Ctx) error {
+	permissionID, err := strconv.ParseUint(c.Params("permissionId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid permission ID",
+		})
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	granted := false
+	for _, p := range user.DirectPermissions {
+		if p.ID == uint(permissionID) {
+			granted = true
+			break
+		}
+	}
+	if !granted {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Permission is not granted directly to this access",
+		})
+	}
+
+	if err := h.repo.RevokePermission(user.ID, uint(permissionID)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke permission",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return h.respondWithAccess(c, user.ID, "Permission revoked successfully")
+}
+
+// DenyPermission godoc
+// SWAGGER_ACCESS_START
+// @Summary Add a deny rule to an access
+// @Description Deny a permission to an access. The deny rule overrides any allow from its groups, inheritance, wildcards or direct grants.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body DenyPermissionRequest true "Permission to deny"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/denied-permissions [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) DenyPermission(c *fiber.Ctx) error {
+	// Parse request body
+	var req DenyPermissionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	target, err := h.permissionRepo.GetPermissionByID(req.PermissionID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Permission not found or inactive",
+		})
+	}
+
+	for _, p := range user.DeniedPermissions {
+		if p.ID == target.ID {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Permission is already denied to this access",
+			})
+		}
+	}
+
+	if err := h.repo.DenyPermission(user.ID, target); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to add deny rule",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return h.respondWithAccess(c, user.ID, "Deny rule added successfully")
+}
+
+// RemoveDeniedPermission godoc
+// SWAGGER_ACCESS_START
+// @Summary Remove a deny rule from an access
+// @Description Remove a deny rule from an access. Lifting a deny rule grants the permission again, so the current admin must hold it.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param permissionId path int true "Permission ID"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/denied-permissions/{permissionId} [delete]
+// SWAGGER_ACCESS_END
+func (h *Handler) RemoveDeniedPermission(c *fiber.Ctx) error {
+	permissionID, err := strconv.ParseUint(c.Params("permissionId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid permission ID",
+		})
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	var denied *permission.Permission
+	for i := range user.DeniedPermissions {
+		if user.DeniedPermissions[i].ID == uint(permissionID) {
+			denied = &user.DeniedPermissions[i]
+			break
+		}
+	}
+	if denied == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Permission is not denied to this access",
+		})
+	}
+
+	// Admins can only lift deny rules on permissions they hold themselves
+	actor, ok := c.Locals("user").(types.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not authenticated",
+		})
+	}
+	if missing := missingPermissions(actor, []permission.Permission{*denied}); len(missing) > 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Cannot lift a deny rule on a permission you do not hold: " + strings.Join(missing, ", "),
+		})
+	}
+
+	if err := h.repo.RemoveDeniedPermission(user.ID, denied.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to remove deny rule",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return h.respondWithAccess(c, user.ID, "Deny rule removed successfully")
+}
+
+// GetEffectivePermissions godoc
+// SWAGGER_ACCESS_START
+// @Summary Get effective permissions
+// @Description List every effective permission and deny rule of an access with the sources granting them (group, inherited from an ancestor group, or direct). Wildcard grants and actions implied by "manage" are flagged.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} EffectivePermissionsResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/access/{id}/effective-permissions [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetEffectivePermissions(c *fiber.Ctx) error {
+	user, err := h.repo.GetAccessWithPermissions(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   user.EffectivePermissions(),
+	})
+}
+
+// CheckPermission godoc
+// SWAGGER_ACCESS_START
+// @Summary Check a permission
+// @Description Decide whether an access may perform an action on a resource, applying deny rules, wildcards, "manage" and group inheritance like the permission middleware, and explain the decision
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body CheckPermissionRequest true "Resource and action to check"
+// @Success 200 {object} CheckPermissionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/access/{id}/check [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) CheckPermission(c *fiber.Ctx) error {
+	// Parse request body
+	var req CheckPermissionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+
+	user, err := h.repo.GetAccessWithPermissions(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   user.CheckPermission(strings.TrimSpace(req.Resource), strings.TrimSpace(req.Action), time.Now()),
+	})
+}
+
+// GetOwnPermissions godoc
+// SWAGGER_ACCESS_START
+// @Summary Get own effective permissions
+// @Description List the effective permissions and deny rules of the current access with their sources, and the scopes of the key used
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} EffectivePermissionsResponse
+// @Failure 401 {object} map[string]string
+// @Router /v1/profile/permissions [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetOwnPermissions(c *fiber.Ctx) error {
+	// Get user data from middleware
+	user := c.Locals("user").(*User)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   user.EffectivePermissions(),
+	})
+}
+
+// respondWithAccess reloads an access after a change and returns it
+func (h *Handler) respondWithAccess(c *fiber.Ctx, id, message string) error {
+	updated, err := h.repo.GetAccessByID(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch access",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    updated,
+	})
+}
+
+// memberOf reports whether an access belongs to a group, as primary or additional group
+func memberOf(user *User, groupID uint) bool {
+	if user.GroupID != nil && *user.GroupID == groupID {
+		return true
+	}
+	for _, g := range user.Groups {
+		if g.ID == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// assignableGroup loads an active group and checks that it does not grant
+// permissions the current admin does not hold. Failures are returned as a *fiber.Error.
+func (h *Handler) assignableGroup(c *fiber.Ctx, groupID uint) (*group.Group, error) {
+	target, err := h.groupRepo.GetGroupWithPermissions(groupID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Group not found or inactive")
+	}
+
+	actor, ok := c.Locals("user").(types.User)
+	if !ok {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "User not authenticated")
+	}
+
+	if missing := missingPermissions(actor, target.EffectivePermissions()); len(missing) > 0 {
+		return nil, fiber.NewError(fiber.StatusForbidden,
+			"Cannot assign a group with more privileges than your own: "+strings.Join(missing, ", "))
+	}
+	return target, nil
+}
+
+// checkKeyIssuable checks that the current admin holds every permission of an access before
+// handing out a key for it, since the key lets them act as that access. Failures are returned
+// as a *fiber.Error.
+func (h *Handler) checkKeyIssuable(c *fiber.Ctx, accessID string) error {
+	target, err := h.repo.GetAccessWithPermissions(accessID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "User not found")
+	}
+
+	actor, ok := c.Locals("user").(types.User)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "User not authenticated")
+	}
+
+	if missing := missingPermissions(actor, target.GetPermissions()); len(missing) > 0 {
+		return fiber.NewError(fiber.StatusForbidden,
+			"Cannot issue a key for an access with more privileges than your own: "+strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// missingPermissions lists the "resource:action" pairs of the target permissions that the actor
+// cannot hand out, see permission.Missing
+func missingPermissions(actor types.User, target []permission.Permission) []string {
+	return permission.Missing(actor.GetPermissions(), actor.GetDeniedPermissions(), target)
+}
+
+// GetIPAllowlist godoc
+// SWAGGER_ACCESS_START
+// @Summary Get IP allowlist
+// @Description Get the allowed IP ranges of an access and of each of its named keys
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} IPAllowlistResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/ip-allowlist [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetIPAllowlist(c *fiber.Ctx) error {
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	keys, err := h.repo.GetKeys(user.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch keys",
+		})
+	}
+
+	response := IPAllowlistResponse{
+		AllowedCIDRs: user.AllowedCIDRs,
+		Keys:         make([]KeyIPAllowlist, 0, len(keys)),
+	}
+	for _, key := range keys {
+		response.Keys = append(response.Keys, KeyIPAllowlist{
+			KeyID:        key.ID,
+			Name:         key.Name,
+			AllowedCIDRs: key.AllowedCIDRs,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   response,
+	})
+}
+
+// UpdateIPAllowlist godoc
+// SWAGGER_ACCESS_START
+// @Summary Update IP allowlist
+// @Description Replace the allowed IP ranges of an access, or of one of its named keys when key_id is set. Single addresses are stored as /32 or /128 ranges. An empty list removes the restriction.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param data body UpdateIPAllowlistRequest true "Allowlist data"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/ip-allowlist [put]
+// SWAGGER_ACCESS_END
+func (h *Handler) UpdateIPAllowlist(c *fiber.Ctx) error {
+	// Parse request body
+	var req UpdateIPAllowlistRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+
+	// Normalize every entry and drop duplicates
+	cidrs := make([]string, 0, len(req.AllowedCIDRs))
+	seen := make(map[string]bool)
+	for _, value := range req.AllowedCIDRs {
+		cidr, err := utils.NormalizeCIDR(value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+		if !seen[cidr] {
+			seen[cidr] = true
+			cidrs = append(cidrs, cidr)
+		}
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	if req.KeyID != "" {
+		if _, err := h.repo.GetKey(user.ID, req.KeyID); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Key not found",
+			})
+		}
+		err = h.repo.UpdateKeyAllowedCIDRs(user.ID, req.KeyID, cidrs)
+	} else {
+		err = h.repo.UpdateAllowedCIDRs(user.ID, cidrs)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update IP allowlist",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "IP allowlist updated successfully",
+		"data": fiber.Map{
+			"key_id":        req.KeyID,
+			"allowed_cidrs": cidrs,
+		},
+	})
+}
+
+// CreateSigningSecret godoc
+// SWAGGER_ACCESS_START
+// @Summary Create HMAC signing secret
+// @Description Create or replace the secret used to sign requests with HMAC-SHA256. The secret is only returned once; any previous secret stops working immediately.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 201 {object} SigningSecretResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /v1/access/{id}/signing-secret [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) CreateSigningSecret(c *fiber.Ctx) error {
+	if !utils.SecretEncryptionEnabled() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Request signing is not enabled on this server",
+		})
+	}
+
+	// Check if user exists (in any status)
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	// Generate the secret and store it encrypted
+	secret := utils.GenerateSigningSecret()
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create signing secret",
+		})
+	}
+
+	if err := h.repo.UpdateSigningSecret(user.ID, &encrypted); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create signing secret",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status": "success",
+		"data": SigningSecretResponse{
+			KeyID:         user.ID,
+			SigningSecret: secret,
+			CreatedAt:     time.Now(),
+		},
+	})
+}
+
+// DeleteSigningSecret godoc
+// SWAGGER_ACCESS_START
+// @Summary Delete HMAC signing secret
+// @Description Remove the signing secret of an access, disabling HMAC signed requests for it
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/access/{id}/signing-secret [delete]
+// SWAGGER_ACCESS_END
+func (h *Handler) DeleteSigningSecret(c *fiber.Ctx) error {
+	user, err := h.repo.GetAccessByID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not found",
+		})
+	}
+
+	if err := h.repo.UpdateSigningSecret(user.ID, nil); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to delete signing secret",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidator.InvalidateAccess(user.ID)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Signing secret deleted successfully",
+	})
+}
+
+// CreateTemporaryGrant godoc
+// SWAGGER_ACCESS_START
+// @Summary Grant a permission temporarily
+// @Description Grant a permission to an access or a group until an expiry, with a justification. The grant stops counting as soon as it expires. The current admin must hold the permission.
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param data body CreateTemporaryGrantRequest true "Temporary grant data"
+// @Success 201 {object} TemporaryGrant
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/temporary-grants [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) CreateTemporaryGrant(c *fiber.Ctx) error {
+	// Parse request body
+	var req CreateTemporaryGrantRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+	if (req.AccessID == nil) == (req.GroupID == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Exactly one of access_id and group_id is required",
+		})
+	}
+	expiresAt, err := grantExpiry(req, time.Now(), h.maxGrantDuration)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	}
+
+	target, err := h.permissionRepo.GetPermissionByID(req.PermissionID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Permission not found or inactive",
+		})
+	}
+
+	// Check if the access (in any status) or the active group exists
+	if req.AccessID != nil {
+		if _, err := h.repo.GetAccessByID(*req.AccessID); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "User not found",
+			})
+		}
+	} else if _, err := h.groupRepo.GetGroupByID(*req.GroupID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group not found",
+		})
+	}
+
+	// Admins can only grant permissions they hold themselves
+	actor, ok := c.Locals("user").(types.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not authenticated",
+		})
+	}
+	if missing := missingPermissions(actor, []permission.Permission{*target}); len(missing) > 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Cannot grant a permission you do not hold: " + strings.Join(missing, ", "),
+		})
+	}
+
+	actorID := actor.GetID()
+	grant := &TemporaryGrant{
+		AccessID:      req.AccessID,
+		GroupID:       req.GroupID,
+		PermissionID:  target.ID,
+		Justification: strings.TrimSpace(req.Justification),
+		ExpiresAt:     expiresAt,
+		GrantedBy:     &actorID,
+	}
+	if err := h.repo.CreateTemporaryGrant(grant); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create temporary grant",
+		})
+	}
+	grant.Permission = *target
+
+	// Drop cached principals built from the old data
+	h.invalidateGrantSubject(grant)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Permission granted until " + expiresAt.Format(time.RFC3339),
+		"data":    grant,
+	})
+}
+
+// ListTemporaryGrants godoc
+// SWAGGER_ACCESS_START
+// @Summary List active temporary grants
+// @Description List the temporary grants that have not expired yet, soonest to expire first
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param access_id query string false "Filter by access ID"
+// @Param group_id query int false "Filter by group ID"
+// @Success 200 {array} TemporaryGrant
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/temporary-grants [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) ListTemporaryGrants(c *fiber.Ctx) error {
+	var filter TemporaryGrantFilter
+	if accessID := c.Query("access_id"); accessID != "" {
+		filter.AccessID = &accessID
+	}
+	if groupID := c.Query("group_id"); groupID != "" {
+		id, err := strconv.ParseUint(groupID, 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid group_id parameter",
+			})
+		}
+		filter.GroupID = utils.UintPtr(uint(id))
+	}
+
+	grants, err := h.repo.ListTemporaryGrants(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch temporary grants",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   grants,
+	})
+}
+
+// RevokeTemporaryGrant godoc
+// SWAGGER_ACCESS_START
+// @Summary Revoke a temporary grant
+// @Description Remove a temporary grant before it expires
+// @Tags Access
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param grantId path string true "Temporary grant ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/temporary-grants/{grantId} [delete]
+// SWAGGER_ACCESS_END
+func (h *Handler) RevokeTemporaryGrant(c *fiber.Ctx) error {
+	grant, err := h.repo.GetTemporaryGrant(c.Params("grantId"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Temporary grant not found",
+		})
+	}
+
+	if err := h.repo.DeleteTemporaryGrant(grant.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to revoke temporary grant",
+		})
+	}
+
+	// Drop cached principals built from the old data
+	h.invalidateGrantSubject(grant)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Temporary grant revoked successfully",
+	})
+}
+
+// invalidateGrantSubject drops the cached principals a temporary grant applies to
+func (h *Handler) invalidateGrantSubject(grant *TemporaryGrant) {
+	if grant.AccessID != nil {
+		h.invalidator.InvalidateAccess(*grant.AccessID)
+		return
+	}
+	invalidateGroupTree(h.groupRepo, h.invalidator, *grant.GroupID)
+}
+
+// grantExpiry resolves the expiry of a temporary grant from a duration or a date,
+// which must be in the future and no further than max from now
+func grantExpiry(req CreateTemporaryGrantRequest, now time.Time, max time.Duration) (time.Time, error) {
+	var expiresAt time.Time
+	switch {
+	case req.Duration != "" && req.ExpiresAt != nil:
+		return time.Time{}, fmt.Errorf("Set either duration or expires_at, not both")
+	case req.Duration != "":
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid duration '%s', expected a value such as 30m or 4h", req.Duration)
+		}
+		expiresAt = now.Add(duration)
+	case req.ExpiresAt != nil:
+		expiresAt = *req.ExpiresAt
+	default:
+		return time.Time{}, fmt.Errorf("Either duration or expires_at is required")
+	}
+
+	if !expiresAt.After(now) {
+		return time.Time{}, fmt.Errorf("Expiration must be in the future")
+	}
+	if expiresAt.After(now.Add(max)) {
+		return time.Time{}, fmt.Errorf("Temporary grants cannot last longer than %s", max)
+	}
+	return expiresAt, nil
+}
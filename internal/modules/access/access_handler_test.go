@@ -0,0 +1,509 @@
+// USAGE
+//   go test ./internal/modules/access -v -run 'TestMissingPermissions|TestListAccesses|TestRotateKey|TestCreateKey|TestRevokeKey|TestGrantPermission|TestCheckPermission|TestChangeStatus'
+
+package access
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"apiserver/internal/cache"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
+	"apiserver/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func TestMissingPermissions(t *testing.T) {
+	editor := &User{
+		ID: "actor",
+		Group: &group.Group{ID: 1, Name: "Editor", Permissions: []permission.Permission{
+			perm("examples:*"), perm("groups:read"),
+		}},
+		DeniedPermissions: []permission.Permission{perm("examples:delete")},
+	}
+
+	tests := []struct {
+		name     string
+		target   []string
+		expected []string
+	}{
+		{name: "Held permission", target: []string{"examples:read"}},
+		{name: "Permission not held", target: []string{"audit:read"}, expected: []string{"audit:read"}},
+		{name: "Denied permission", target: []string{"examples:delete"}, expected: []string{"examples:delete"}},
+		{name: "Wildcard covering a deny", target: []string{"examples:*"}, expected: []string{"examples:*"}},
+		{name: "Manage covering a deny", target: []string{"examples:manage"}, expected: []string{"examples:manage"}},
+		{name: "Group mixing allowed and denied", target: []string{"groups:read", "examples:*"}, expected: []string{"examples:*"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := make([]permission.Permission, len(tt.target))
+			for i, key := range tt.target {
+				target[i] = perm(key)
+			}
+			if got := missingPermissions(editor, target); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("missingPermissions() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// listingRepository records the filter ListAccesses is called with
+type listingRepository struct {
+	Repository
+	filter AccessFilter
+}
+
+func (r *listingRepository) ListAccesses(filter AccessFilter) ([]User, int64, error) {
+	r.filter = filter
+	return []User{}, 0, nil
+}
+
+func TestListAccesses(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantLimit  int
+		wantOffset int
+	}{
+		{name: "Default page", query: "", wantLimit: 50},
+		{name: "Requested page", query: "?limit=20&offset=40", wantLimit: 20, wantOffset: 40},
+		{name: "Limit above the maximum", query: "?limit=5000", wantLimit: 1000},
+		{name: "Negative limit", query: "?limit=-5", wantLimit: 1},
+		{name: "Negative offset", query: "?offset=-10", wantLimit: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &listingRepository{}
+			handler := NewHandler(repo, nil, nil, cache.NopInvalidator{}, time.Hour, time.Hour)
+			app := fiber.New()
+			app.Get("/v1/access", handler.ListAccesses)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/v1/access"+tt.query, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			var body struct {
+				Data struct {
+					Limit  int `json:"limit"`
+					Offset int `json:"offset"`
+				} `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+
+			if repo.filter.Limit != tt.wantLimit || repo.filter.Offset != tt.wantOffset {
+				t.Errorf("repository page = %d+%d, want %d+%d", repo.filter.Offset, repo.filter.Limit, tt.wantOffset, tt.wantLimit)
+			}
+			if body.Data.Limit != tt.wantLimit || body.Data.Offset != tt.wantOffset {
+				t.Errorf("response page = %d+%d, want %d+%d", body.Data.Offset, body.Data.Limit, tt.wantOffset, tt.wantLimit)
+			}
+		})
+	}
+}
+
+// handlerRepository serves one access, "partner", with a named key "ci", and records changes
+type handlerRepository struct {
+	Repository
+	user       *User
+	rotatedKey string
+	graceEnd   *time.Time
+	createdKey *AccessKey
+	revokedKey string
+	granted    []uint
+	changed    *StatusChange
+}
+
+func newHandlerRepository() *handlerRepository {
+	return &handlerRepository{user: &User{
+		ID:                "partner",
+		StatusID:          utils.Int16Ptr(0),
+		Group:             &group.Group{ID: 4, Name: "Client", Permissions: []permission.Permission{perm("examples:*")}},
+		DeniedPermissions: []permission.Permission{perm("examples:delete")},
+	}}
+}
+
+func (r *handlerRepository) find(id string) (*User, error) {
+	if id != r.user.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.user, nil
+}
+
+func (r *handlerRepository) GetUserByID(id string) (*User, error)              { return r.find(id) }
+func (r *handlerRepository) GetAccessByID(id string) (*User, error)            { return r.find(id) }
+func (r *handlerRepository) GetAccessWithPermissions(id string) (*User, error) { return r.find(id) }
+
+func (r *handlerRepository) RotateAPIKey(id, apiKey string, previousValidUntil *time.Time) error {
+	r.rotatedKey, r.graceEnd = apiKey, previousValidUntil
+	return nil
+}
+
+func (r *handlerRepository) GetKey(accessID, keyID string) (*AccessKey, error) {
+	if accessID != r.user.ID || keyID != "ci" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &AccessKey{ID: keyID, AccessID: accessID}, nil
+}
+
+func (r *handlerRepository) CreateKey(key *AccessKey) error {
+	r.createdKey = key
+	return nil
+}
+
+func (r *handlerRepository) RevokeKey(accessID, keyID string) error {
+	r.revokedKey = keyID
+	return nil
+}
+
+func (r *handlerRepository) ChangeStatus(change *StatusChange) error {
+	r.changed = change
+	return nil
+}
+
+func (r *handlerRepository) GrantPermission(id string, p *permission.Permission) error {
+	r.granted = append(r.granted, p.ID)
+	return nil
+}
+
+// handlerPermissions serves examples:read (1) and *:* (2)
+type handlerPermissions struct{ permission.Repository }
+
+func (handlerPermissions) GetPermissionByID(id uint) (*permission.Permission, error) {
+	switch id {
+	case 1:
+		return &permission.Permission{ID: 1, Resource: "examples", Action: "read"}, nil
+	case 2:
+		return &permission.Permission{ID: 2, Resource: "*", Action: "*"}, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// recordingInvalidator is defined in access_grant_sweep_test.go
+func newTestHandler(repo *handlerRepository, invalidator *recordingInvalidator, grace time.Duration) *Handler {
+	return NewHandler(repo, nil, handlerPermissions{}, invalidator, grace, time.Hour)
+}
+
+// testApp serves a handler as the given actor
+func testApp(method, path string, actor *User, handler fiber.Handler) *fiber.App {
+	app := fiber.New()
+	app.Add(method, path, func(c *fiber.Ctx) error {
+		c.Locals("user", actor)
+		return c.Next()
+	}, handler)
+	return app
+}
+
+// Actors of the key and permission tests
+var (
+	superAdmin  = &User{ID: "admin", DirectPermissions: []permission.Permission{perm("*:*")}}
+	accessAdmin = &User{ID: "access-admin", DirectPermissions: []permission.Permission{perm("access:manage"), perm("examples:read")}}
+)
+
+func TestRotateKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		actor      *User
+		id         string
+		grace      time.Duration
+		wantStatus int
+	}{
+		{name: "Rotation with a grace period", actor: superAdmin, id: "partner", grace: time.Hour, wantStatus: fiber.StatusOK},
+		{name: "Rotation without a grace period", actor: superAdmin, id: "partner", wantStatus: fiber.StatusOK},
+		{name: "Access with more privileges than the actor", actor: accessAdmin, id: "partner", grace: time.Hour, wantStatus: fiber.StatusForbidden},
+		{name: "Unknown access", actor: superAdmin, id: "nobody", grace: time.Hour, wantStatus: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, invalidator := newHandlerRepository(), &recordingInvalidator{}
+			app := testApp(fiber.MethodPost, "/v1/access/:id/rotate-key", tt.actor, newTestHandler(repo, invalidator, tt.grace).RotateKey)
+
+			started := time.Now()
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/v1/access/"+tt.id+"/rotate-key", nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				if repo.rotatedKey != "" {
+					t.Error("key rotated although the request was refused")
+				}
+				return
+			}
+
+			var body struct {
+				Data RotateKeyResponse `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if body.Data.APIKey == "" || body.Data.APIKey != repo.rotatedKey {
+				t.Errorf("returned key %q, stored %q", body.Data.APIKey, repo.rotatedKey)
+			}
+			if !strings.HasPrefix(body.Data.APIKey, body.Data.APIKeyPrefix) {
+				t.Errorf("prefix %q does not start the key", body.Data.APIKeyPrefix)
+			}
+			if tt.grace == 0 && repo.graceEnd != nil {
+				t.Errorf("old key valid until %v, want it revoked at once", repo.graceEnd)
+			}
+			if tt.grace > 0 && (repo.graceEnd == nil || repo.graceEnd.Before(started.Add(tt.grace))) {
+				t.Errorf("old key valid until %v, want %v after the rotation", repo.graceEnd, tt.grace)
+			}
+			if !reflect.DeepEqual(invalidator.accesses, []string{"partner"}) {
+				t.Errorf("invalidated %v, want the rotated access", invalidator.accesses)
+			}
+		})
+	}
+}
+
+func TestRevokeKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantKey    string
+	}{
+		{name: "Named key", url: "/v1/access/partner/keys/ci", wantStatus: fiber.StatusOK, wantKey: "ci"},
+		{name: "Unknown key", url: "/v1/access/partner/keys/deploy", wantStatus: fiber.StatusNotFound},
+		{name: "Key of another access", url: "/v1/access/nobody/keys/ci", wantStatus: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, invalidator := newHandlerRepository(), &recordingInvalidator{}
+			app := testApp(fiber.MethodDelete, "/v1/access/:id/keys/:keyId", nil, newTestHandler(repo, invalidator, 0).RevokeKey)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, tt.url, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if repo.revokedKey != tt.wantKey {
+				t.Errorf("revoked key %q, want %q", repo.revokedKey, tt.wantKey)
+			}
+			if tt.wantKey != "" && len(invalidator.accesses) != 1 {
+				t.Errorf("invalidated %v, want the key's access", invalidator.accesses)
+			}
+		})
+	}
+}
+
+func TestCreateKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		actor       *User
+		url         string
+		wantStatus  int
+		wantCreated bool
+	}{
+		{name: "Actor holding the access's permissions", actor: superAdmin, url: "/v1/access/partner/keys", wantStatus: fiber.StatusCreated, wantCreated: true},
+		{name: "Access with more privileges than the actor", actor: accessAdmin, url: "/v1/access/partner/keys", wantStatus: fiber.StatusForbidden},
+		{name: "Unknown access", actor: superAdmin, url: "/v1/access/nobody/keys", wantStatus: fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newHandlerRepository()
+			app := testApp(fiber.MethodPost, "/v1/access/:id/keys", tt.actor, newTestHandler(repo, &recordingInvalidator{}, 0).CreateKey)
+
+			req := httptest.NewRequest(fiber.MethodPost, tt.url, strings.NewReader(`{"name":"ci"}`))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if created := repo.createdKey != nil; created != tt.wantCreated {
+				t.Errorf("key created = %v, want %v", created, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestGrantPermission(t *testing.T) {
+	editor := accessAdmin
+	deniedAdmin := &User{ID: "denied", DirectPermissions: []permission.Permission{perm("*:*")}, DeniedPermissions: []permission.Permission{perm("audit:read")}}
+
+	tests := []struct {
+		name        string
+		actor       *User
+		body        string
+		wantStatus  int
+		wantGranted bool
+	}{
+		{name: "Permission the actor holds", actor: editor, body: `{"permission_id":1}`, wantStatus: fiber.StatusOK, wantGranted: true},
+		{name: "Wildcard the actor lacks", actor: editor, body: `{"permission_id":2}`, wantStatus: fiber.StatusForbidden},
+		{name: "Wildcard overlapping a deny of the actor", actor: deniedAdmin, body: `{"permission_id":2}`, wantStatus: fiber.StatusForbidden},
+		{name: "Wildcard held by the actor", actor: superAdmin, body: `{"permission_id":2}`, wantStatus: fiber.StatusOK, wantGranted: true},
+		{name: "Unknown permission", actor: superAdmin, body: `{"permission_id":9}`, wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newHandlerRepository()
+			app := testApp(fiber.MethodPost, "/v1/access/:id/permissions", tt.actor, newTestHandler(repo, &recordingInvalidator{}, 0).GrantPermission)
+
+			req := httptest.NewRequest(fiber.MethodPost, "/v1/access/partner/permissions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if granted := len(repo.granted) > 0; granted != tt.wantGranted {
+				t.Errorf("granted = %v, want %v", granted, tt.wantGranted)
+			}
+		})
+	}
+}
+
+func TestCheckPermission(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantDecision string
+	}{
+		{name: "Granted by the group wildcard", body: `{"resource":"examples","action":"read"}`, wantDecision: DecisionAllow},
+		{name: "Denied by a deny rule", body: `{"resource":"examples","action":"delete"}`, wantDecision: DecisionDeny},
+		{name: "Not granted", body: `{"resource":"audit","action":"read"}`, wantDecision: DecisionDeny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newHandlerRepository()
+			app := testApp(fiber.MethodPost, "/v1/access/:id/check", nil, newTestHandler(repo, &recordingInvalidator{}, 0).CheckPermission)
+
+			req := httptest.NewRequest(fiber.MethodPost, "/v1/access/partner/check", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			var body struct {
+				Data CheckPermissionResponse `json:"data"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if body.Data.Decision != tt.wantDecision || body.Data.Reason == "" {
+				t.Errorf("decision = %q (%q), want %q with a reason", body.Data.Decision, body.Data.Reason, tt.wantDecision)
+			}
+		})
+	}
+}
+
+func TestChangeStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        int16
+		action      string
+		actorID     string
+		body        string
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "Suspend an active access", from: types.StatusActive, action: "suspend", body: `{"reason":"leaked key"}`, wantStatus: fiber.StatusOK},
+		{name: "Suspend without a reason", from: types.StatusActive, action: "suspend", wantStatus: fiber.StatusBadRequest, wantMessage: "Reason is required"},
+		{name: "Revoke with a blank reason", from: types.StatusSuspended, action: "revoke", body: `{"reason":"  "}`, wantStatus: fiber.StatusBadRequest, wantMessage: "Reason is required"},
+		{name: "Revoke a suspended access", from: types.StatusSuspended, action: "revoke", body: `{"reason":"contract ended"}`, wantStatus: fiber.StatusOK},
+		{name: "Reactivate without a reason", from: types.StatusSuspended, action: "reactivate", wantStatus: fiber.StatusOK},
+		{name: "Reactivate a pending access", from: types.StatusPending, action: "reactivate", wantStatus: fiber.StatusOK},
+		{name: "Reactivate a revoked access", from: types.StatusInactive, action: "reactivate", wantStatus: fiber.StatusConflict,
+			wantMessage: "Cannot change status from Inactive/Deleted to Active"},
+		{name: "Reactivate an active access", from: types.StatusActive, action: "reactivate", wantStatus: fiber.StatusConflict},
+		{name: "Suspend a revoked access", from: types.StatusInactive, action: "suspend", body: `{"reason":"again"}`, wantStatus: fiber.StatusConflict},
+		{name: "Suspend own access", from: types.StatusActive, action: "suspend", actorID: "partner", body: `{"reason":"testing"}`,
+			wantStatus: fiber.StatusBadRequest, wantMessage: "You cannot change the status of your own access"},
+		{name: "Revoke own access", from: types.StatusActive, action: "revoke", actorID: "partner", body: `{"reason":"testing"}`, wantStatus: fiber.StatusBadRequest},
+	}
+
+	toStatus := map[string]int16{"suspend": types.StatusSuspended, "revoke": types.StatusInactive, "reactivate": types.StatusActive}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, invalidator := newHandlerRepository(), &recordingInvalidator{}
+			repo.user.StatusID = utils.Int16Ptr(tt.from)
+			handler := newTestHandler(repo, invalidator, 0)
+			actorID := tt.actorID
+			if actorID == "" {
+				actorID = "admin"
+			}
+
+			app := fiber.New()
+			authenticate := func(c *fiber.Ctx) error {
+				c.Locals("access_id", actorID)
+				return c.Next()
+			}
+			app.Post("/v1/access/:id/suspend", authenticate, handler.SuspendAccess)
+			app.Post("/v1/access/:id/reactivate", authenticate, handler.ReactivateAccess)
+			app.Post("/v1/access/:id/revoke", authenticate, handler.RevokeAccess)
+
+			req := httptest.NewRequest(fiber.MethodPost, "/v1/access/partner/"+tt.action, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			if tt.wantStatus != fiber.StatusOK {
+				if repo.changed != nil {
+					t.Error("status changed although the request was refused")
+				}
+				if tt.wantMessage == "" {
+					return
+				}
+				var body struct {
+					Message string `json:"message"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+					t.Fatalf("invalid response: %v", err)
+				}
+				if body.Message != tt.wantMessage {
+					t.Errorf("message = %q, want %q", body.Message, tt.wantMessage)
+				}
+				return
+			}
+
+			change := repo.changed
+			if change == nil {
+				t.Fatal("no status change recorded")
+			}
+			if change.FromStatusID != tt.from || change.ToStatusID != toStatus[tt.action] {
+				t.Errorf("transition = %d -> %d, want %d -> %d", change.FromStatusID, change.ToStatusID, tt.from, toStatus[tt.action])
+			}
+			if change.ActorID == nil || *change.ActorID != actorID {
+				t.Errorf("actor = %v, want %s", change.ActorID, actorID)
+			}
+			if !reflect.DeepEqual(invalidator.accesses, []string{"partner"}) {
+				t.Errorf("invalidated %v, want the changed access", invalidator.accesses)
+			}
+		})
+	}
+}
+
+func perm(key string) permission.Permission {
+	resource, action, _ := permission.SplitKey(key)
+	return permission.Permission{Resource: resource, Action: action}
+}
@@ -0,0 +1,54 @@
+package access
+
+import (
+	"log"
+
+	"apiserver/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// legacyAPIKeyColumn is the column that stored API keys in plaintext
+const legacyAPIKeyColumn = "api_key"
+
+// MigrateLegacyAPIKeys re-hashes API keys that are still stored in plaintext.
+// Each row's digest and prefix are derived from the legacy api_key column,
+// which is dropped once every row has been converted.
+func MigrateLegacyAPIKeys(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&User{}, legacyAPIKeyColumn) {
+		return nil
+	}
+
+	var rows []struct {
+		ID     string
+		APIKey string
+	}
+	if err := db.Table(User{}.TableName()).
+		Select("id, api_key").
+		Where("api_key_hash IS NULL OR api_key_hash = ''").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			if row.APIKey == "" {
+				continue
+			}
+			updates := map[string]interface{}{
+				"api_key_hash":   utils.HashAPIKey(row.APIKey),
+				"api_key_prefix": utils.APIKeyPrefix(row.APIKey),
+			}
+			if err := tx.Table(User{}.TableName()).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Migrator().DropColumn(&User{}, legacyAPIKeyColumn); err != nil {
+			return err
+		}
+
+		log.Printf("Migrated %d plaintext API keys to hashed storage", len(rows))
+		return nil
+	})
+}
@@ -4,26 +4,57 @@ import (
 	"time"
 
 	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
 	"apiserver/internal/utils"
 
 	"gorm.io/gorm"
 )
 
 type User struct {
-	ID          string         `json:"id" gorm:"type:uuid;primaryKey"`
-	Name        string         `json:"name" gorm:"not null"`
-	Email       string         `json:"email" gorm:"uniqueIndex;not null"`
-	APIKey      string         `json:"-" gorm:"uniqueIndex;not null"`
-	GroupID     *uint          `json:"group_id" gorm:"index"`
-	Group       *group.Group   `json:"group,omitempty" gorm:"foreignKey:GroupID"`
-	ExpiredDate *time.Time     `json:"expired_date" gorm:"index"`
-	RateLimit   int            `json:"rate_limit" gorm:"not null;default:120"` // Requests per minute
-	CreatedAt   time.Time      `json:"-"`
-	UpdatedAt   time.Time      `json:"-"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
-	StatusID    *int16         `json:"status_id" gorm:"type:smallint;not null;default:1;index"`
+	ID                      string         `json:"id" gorm:"type:uuid;primaryKey"`
+	Name                    string         `json:"name" gorm:"not null"`
+	Email                   string         `json:"email" gorm:"uniqueIndex;not null"`
+	APIKeyHash              string         `json:"-" gorm:"column:api_key_hash;size:64;uniqueIndex"` // SHA-256/HMAC digest, never the raw key
+	APIKeyPrefix            string         `json:"api_key_prefix" gorm:"column:api_key_prefix;size:16"`
+	PreviousAPIKeyHash      *string        `json:"-" gorm:"column:previous_api_key_hash;size:64;index"` // Key replaced by the last rotation
+	PreviousAPIKeyExpiresAt *time.Time     `json:"previous_api_key_expires_at,omitempty"`               // End of the rotation grace period
+	GroupID                 *uint          `json:"group_id" gorm:"index"`
+	Group                   *group.Group   `json:"group,omitempty" gorm:"foreignKey:GroupID"` // Primary group
+	ExpiredDate             *time.Time     `json:"expired_date" gorm:"index"`
+	RateLimit               int            `json:"rate_limit" gorm:"not null;default:120"`         // Requests per minute
+	AllowedCIDRs            []string       `json:"allowed_cidrs" gorm:"serializer:json;type:text"` // Empty allows every address
+	SigningSecret           *string        `json:"-" gorm:"type:text"`                             // AES-GCM encrypted HMAC signing secret
+	SigningSecretCreatedAt  *time.Time     `json:"signing_secret_created_at,omitempty"`
+	CreatedAt               time.Time      `json:"-"`
+	UpdatedAt               time.Time      `json:"-"`
+	DeletedAt               gorm.DeletedAt `json:"-" gorm:"index"`
+	StatusID                *int16         `json:"status_id" gorm:"type:smallint;not null;default:1;index"`
+
+	// Groups are the additional groups of this access besides the primary group
+	Groups []group.Group `json:"groups,omitempty" gorm:"many2many:access_groups;joinForeignKey:AccessID;joinReferences:GroupID"`
+	// DirectPermissions are granted to this access regardless of its groups
+	DirectPermissions []permission.Permission `json:"direct_permissions,omitempty" gorm:"many2many:access_permissions;joinForeignKey:AccessID;joinReferences:PermissionID"`
+	// DeniedPermissions are never granted to this access, whatever allows them
+	DeniedPermissions []permission.Permission `json:"denied_permissions,omitempty" gorm:"many2many:access_denied_permissions;joinForeignKey:AccessID;joinReferences:PermissionID"`
+	// TemporaryGrants are unexpired time-bound grants to this access or its groups (loaded with the permissions, not a relation)
+	TemporaryGrants []TemporaryGrant `json:"temporary_grants,omitempty" gorm:"-"`
+
+	// Credential records which key authenticated the current request (not persisted)
+	Credential string `json:"-" gorm:"-"`
+	// ActiveKey is the named key that authenticated the current request, if any (not persisted)
+	ActiveKey *AccessKey `json:"-" gorm:"-"`
 }
 
+// Credential values identifying which key authenticated a request
+const (
+	CredentialPrimary   = "primary"  // the current API key
+	CredentialPrevious  = "previous" // the rotated-out key during its grace period
+	CredentialKeyPrefix = "key:"     // a named key, followed by the key name
+	CredentialHMAC      = "hmac"     // an HMAC signed request
+	CredentialJWT       = "jwt"      // an OAuth access token
+)
+
 func (User) TableName() string {
 	return "access"
 }
@@ -45,15 +76,153 @@ func (u *User) GetGroup() *group.Group {
 	return u.Group
 }
 
+// GetGroups returns the primary group followed by the additional groups, without duplicates
+func (u *User) GetGroups() []group.Group {
+	var groups []group.Group
+	seen := make(map[uint]bool)
+	if u.Group != nil {
+		groups = append(groups, *u.Group)
+		seen[u.Group.ID] = true
+	}
+	for _, g := range u.Groups {
+		// Groups taken from token claims carry a name only
+		if g.ID != 0 && seen[g.ID] {
+			continue
+		}
+		seen[g.ID] = true
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// GetPermissions returns the effective permissions of this access: the union of the
+// permissions of all its groups, including inherited ones, its direct grants and its
+// unexpired temporary grants
+func (u *User) GetPermissions() []permission.Permission {
+	var permissions []permission.Permission
+	seen := make(map[string]bool)
+	add := func(list []permission.Permission) {
+		for _, p := range list {
+			key := p.Resource + ":" + p.Action
+			if !seen[key] {
+				seen[key] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+
+	for _, g := range u.GetGroups() {
+		add(g.EffectivePermissions())
+	}
+	add(u.DirectPermissions)
+	add(u.activeTemporaryPermissions(time.Now()))
+	return permissions
+}
+
+// activeTemporaryPermissions returns the permissions of temporary grants that have not expired at now.
+// Grants are checked on every call, so an expired grant stops counting even while the access is cached.
+func (u *User) activeTemporaryPermissions(now time.Time) []permission.Permission {
+	var permissions []permission.Permission
+	for _, grant := range u.TemporaryGrants {
+		if grant.ActiveAt(now) {
+			permissions = append(permissions, grant.Permission)
+		}
+	}
+	return permissions
+}
+
+// TemporaryGrantsExpireAt returns when the first unexpired temporary grant of this access expires, if any
+func (u *User) TemporaryGrantsExpireAt(now time.Time) *time.Time {
+	var first *time.Time
+	for i := range u.TemporaryGrants {
+		grant := &u.TemporaryGrants[i]
+		if grant.ActiveAt(now) && (first == nil || grant.ExpiresAt.Before(*first)) {
+			first = &grant.ExpiresAt
+		}
+	}
+	return first
+}
+
+// GetDeniedPermissions returns the deny rules of this access and of all its groups, including inherited ones
+func (u *User) GetDeniedPermissions() []permission.Permission {
+	var denies []permission.Permission
+	seen := make(map[string]bool)
+	add := func(list []permission.Permission) {
+		for _, p := range list {
+			key := p.Resource + ":" + p.Action
+			if !seen[key] {
+				seen[key] = true
+				denies = append(denies, p)
+			}
+		}
+	}
+
+	add(u.DeniedPermissions)
+	for _, g := range u.GetGroups() {
+		add(g.EffectiveDenies())
+	}
+	return denies
+}
+
+// CheckAccess reports why this access cannot authenticate, or nil if it can
+func (u *User) CheckAccess(now time.Time) error {
+	if u.StatusID == nil {
+		return types.ErrInvalidAPIKey
+	}
+
+	switch *u.StatusID {
+	case types.StatusActive:
+		// Either expired_date is NULL (never expires) or expired_date is in the future
+		if u.ExpiredDate != nil && !u.ExpiredDate.After(now) {
+			return types.ErrAccessExpired
+		}
+		return nil
+	case types.StatusSuspended:
+		return types.ErrAccessSuspended
+	case types.StatusPending:
+		return types.ErrAccessPending
+	case types.StatusInactive:
+		return types.ErrAccessRevoked
+	default:
+		return types.ErrInvalidAPIKey
+	}
+}
+
 // UpdateExpiredDateRequest is the request body for updating API key expiration date
 type UpdateExpiredDateRequest struct {
 	ExpiredDate *time.Time `json:"expired_date"`
 }
+
 // GetRateLimit returns the rate limit for this user
+// A named key with its own rate limit overrides the access rate limit
 func (u *User) GetRateLimit() int {
+	if u.ActiveKey != nil && u.ActiveKey.RateLimit != nil {
+		return *u.ActiveKey.RateLimit
+	}
 	return u.RateLimit
 }
 
+// GetScopes returns the scopes of the named key used for this request.
+// An empty list means the group's permissions are not narrowed.
+func (u *User) GetScopes() []string {
+	if u.ActiveKey == nil {
+		return nil
+	}
+	return u.ActiveKey.Scopes
+}
+
+// IsIPAllowed reports whether a request from ip may use this access.
+// Both the access allowlist and the allowlist of the key used must match when set.
+func (u *User) IsIPAllowed(ip string) bool {
+	if len(u.AllowedCIDRs) > 0 && !utils.IPInCIDRs(ip, u.AllowedCIDRs) {
+		return false
+	}
+	if u.ActiveKey != nil && len(u.ActiveKey.AllowedCIDRs) > 0 && !utils.IPInCIDRs(ip, u.ActiveKey.AllowedCIDRs) {
+		return false
+	}
+	return true
+}
+
 // UpdateRateLimitRequest is the request body for updating API key rate limit
 type UpdateRateLimitRequest struct {
 	RateLimit int `json:"rate_limit" validate:"required,min=1"`
@@ -61,8 +230,157 @@ type UpdateRateLimitRequest struct {
 
 // CreateAccessRequest is the request body for creating new access
 type CreateAccessRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	FullName string `json:"full_name" validate:"required,min=2,max=100"`
+	Email       string     `json:"email" validate:"required,email"`
+	FullName    string     `json:"full_name" validate:"required,min=2,max=100"`
+	GroupID     *uint      `json:"group_id" validate:"omitempty,min=1"`   // Defaults to the generic client group
+	ExpiredDate *time.Time `json:"expired_date"`                          // Defaults to 6 months from now
+	RateLimit   *int       `json:"rate_limit" validate:"omitempty,min=1"` // Defaults to 120 requests per minute
+}
+
+// UpdateIPAllowlistRequest is the request body for replacing an IP allowlist.
+// An empty list removes the restriction.
+type UpdateIPAllowlistRequest struct {
+	KeyID        string   `json:"key_id"` // Optional named key; the access allowlist is updated when empty
+	AllowedCIDRs []string `json:"allowed_cidrs" validate:"max=100"`
+}
+
+// SigningSecretResponse is returned once when a signing secret is created
+type SigningSecretResponse struct {
+	KeyID         string    `json:"key_id"` // Sent in the X-Signature-Key-Id header
+	SigningSecret string    `json:"signing_secret"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// IPAllowlistResponse shows the allowlist of an access and of each of its named keys
+type IPAllowlistResponse struct {
+	AllowedCIDRs []string         `json:"allowed_cidrs"`
+	Keys         []KeyIPAllowlist `json:"keys"`
+}
+
+// KeyIPAllowlist is the allowlist of a single named key
+type KeyIPAllowlist struct {
+	KeyID        string   `json:"key_id"`
+	Name         string   `json:"name"`
+	AllowedCIDRs []string `json:"allowed_cidrs"`
+}
+
+// UpdateGroupRequest is the request body for changing the group of an access
+type UpdateGroupRequest struct {
+	GroupID uint `json:"group_id" validate:"required,min=1"`
+}
+
+// AddGroupRequest is the request body for adding an access to an additional group
+type AddGroupRequest struct {
+	GroupID uint `json:"group_id" validate:"required,min=1"`
+}
+
+// GrantPermissionRequest is the request body for granting a permission directly to an access
+type GrantPermissionRequest struct {
+	PermissionID uint `json:"permission_id" validate:"required,min=1"`
+}
+
+// DenyPermissionRequest is the request body for adding a deny rule to an access
+type DenyPermissionRequest struct {
+	PermissionID uint `json:"permission_id" validate:"required,min=1"`
+}
+
+// Permission source types
+const (
+	SourceGroup     = "group"     // assigned to one of the access's groups
+	SourceInherited = "inherited" // inherited by one of the access's groups from an ancestor
+	SourceDirect    = "direct"    // granted or denied to the access itself
+	SourceTemporary = "temporary" // granted until an expiry, to the access or one of its groups
+)
+
+// PermissionSource explains where an effective permission or deny rule comes from
+type PermissionSource struct {
+	Type          string          `json:"type"`                     // group, inherited, direct or temporary
+	Group         *group.GroupRef `json:"group,omitempty"`          // Group of the access
+	InheritedFrom *group.GroupRef `json:"inherited_from,omitempty"` // Ancestor holding the permission
+	ExpiresAt     *time.Time      `json:"expires_at,omitempty"`     // End of a temporary grant
+}
+
+// EffectivePermission is a permission or deny rule of an access with every source granting it
+type EffectivePermission struct {
+	ID       uint               `json:"id,omitempty"`
+	Name     string             `json:"name"`
+	Resource string             `json:"resource"`
+	Action   string             `json:"action"`
+	Wildcard bool               `json:"wildcard,omitempty"` // Resource or action is "*"
+	Implies  []string           `json:"implies,omitempty"`  // Actions implied by a hierarchical action such as "manage"
+	Sources  []PermissionSource `json:"sources"`
+}
+
+// EffectivePermissionsResponse lists what an access can and cannot do
+type EffectivePermissionsResponse struct {
+	AccessID          string                `json:"access_id"`
+	Permissions       []EffectivePermission `json:"permissions"`
+	DeniedPermissions []EffectivePermission `json:"denied_permissions"`
+	Scopes            []string              `json:"scopes,omitempty"` // Scopes of the key used for the request, if any
+}
+
+// CheckPermissionRequest is the request body for checking a permission of an access
+type CheckPermissionRequest struct {
+	Resource string `json:"resource" validate:"required"`
+	Action   string `json:"action" validate:"required"`
+}
+
+// Permission check decisions
+const (
+	DecisionAllow = "allow"
+	DecisionDeny  = "deny"
+)
+
+// CheckPermissionResponse is the decision for a resource/action pair with the reasoning behind it
+type CheckPermissionResponse struct {
+	AccessID  string                `json:"access_id"`
+	Resource  string                `json:"resource"`
+	Action    string                `json:"action"`
+	Decision  string                `json:"decision"` // allow or deny
+	Allowed   bool                  `json:"allowed"`
+	Reason    string                `json:"reason"`
+	MatchedBy []EffectivePermission `json:"matched_by,omitempty"` // Deny rules when denied, otherwise the grants covering the pair
+}
+
+// AccessFilter holds the search and filter options for listing accesses
+type AccessFilter struct {
+	Search             string `json:"search"` // Matches name or email
+	GroupID            *uint  `json:"group_id"`
+	StatusID           *int16 `json:"status_id"`
+	Expired            *bool  `json:"expired"`
+	ExpiringWithinDays int    `json:"expiring_within_days"`
+	Limit              int    `json:"limit"`
+	Offset             int    `json:"offset"`
+}
+
+// Page sizes of ListAccesses
+const (
+	defaultAccessListLimit = 50
+	maxAccessListLimit     = 1000
+)
+
+// Paginate applies the default page size when limit is unset, and clamps limit to
+// 1..1000 and offset to 0 or more
+func (f *AccessFilter) Paginate() {
+	switch {
+	case f.Limit == 0:
+		f.Limit = defaultAccessListLimit
+	case f.Limit < 1:
+		f.Limit = 1
+	case f.Limit > maxAccessListLimit:
+		f.Limit = maxAccessListLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+}
+
+// RotateKeyResponse is the response body for rotating an API key
+type RotateKeyResponse struct {
+	ID                      string     `json:"id"`
+	APIKey                  string     `json:"api_key"`
+	APIKeyPrefix            string     `json:"api_key_prefix"`
+	PreviousAPIKeyExpiresAt *time.Time `json:"previous_api_key_expires_at"`
 }
 
 // CreateAccessResponse is the response body for creating new access
@@ -73,10 +391,148 @@ type CreateAccessResponse struct {
 	RateLimit   int        `json:"rate_limit"`
 }
 
+// AccessKey is an additional named API key owned by an access
+type AccessKey struct {
+	ID           string     `json:"id" gorm:"type:uuid;primaryKey"`
+	AccessID     string     `json:"access_id" gorm:"type:uuid;not null;index"`
+	Name         string     `json:"name" gorm:"not null"`
+	KeyHash      string     `json:"-" gorm:"size:64;uniqueIndex;not null"` // SHA-256/HMAC digest, never the raw key
+	KeyPrefix    string     `json:"key_prefix" gorm:"size:16"`
+	Scopes       []string   `json:"scopes" gorm:"serializer:json;type:text"` // "resource:action" pairs narrowing the group's permissions
+	ExpiredDate  *time.Time `json:"expired_date" gorm:"index"`
+	RateLimit    *int       `json:"rate_limit"`                                     // Requests per minute, nil uses the access rate limit
+	AllowedCIDRs []string   `json:"allowed_cidrs" gorm:"serializer:json;type:text"` // Applied on top of the access allowlist
+	LastUsedAt   *time.Time `json:"last_used_at"`                                   // Recorded at most once a minute
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"-"`
+	StatusID     *int16     `json:"status_id" gorm:"type:smallint;not null;default:1;index"`
+}
+
+func (AccessKey) TableName() string {
+	return "access_keys"
+}
+
+// BeforeCreate hook to generate UUIDv7 before creating a new key
+func (k *AccessKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = utils.GenerateUUIDv7()
+	}
+	return nil
+}
+
+// CheckAccess reports why this key cannot authenticate, or nil if it can
+func (k *AccessKey) CheckAccess(now time.Time) error {
+	if k.RevokedAt != nil || k.StatusID == nil || *k.StatusID != types.StatusActive {
+		return types.ErrAccessRevoked
+	}
+	if k.ExpiredDate != nil && !k.ExpiredDate.After(now) {
+		return types.ErrAccessExpired
+	}
+	return nil
+}
+
+// StatusChange records a status transition of an access
+type StatusChange struct {
+	ID           string    `json:"id" gorm:"type:uuid;primaryKey"`
+	AccessID     string    `json:"access_id" gorm:"type:uuid;not null;index"`
+	FromStatusID int16     `json:"from_status_id" gorm:"type:smallint;not null"`
+	ToStatusID   int16     `json:"to_status_id" gorm:"type:smallint;not null"`
+	Reason       string    `json:"reason" gorm:"type:text"`
+	ActorID      *string   `json:"actor_id" gorm:"type:uuid;index"` // Access that made the change
+	CreatedAt    time.Time `json:"created_at" gorm:"index"`
+}
+
+func (StatusChange) TableName() string {
+	return "access_status_changes"
+}
+
+// BeforeCreate hook to generate UUIDv7 before creating a new status change
+func (s *StatusChange) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = utils.GenerateUUIDv7()
+	}
+	return nil
+}
+
+// ChangeStatusRequest is the request body for suspending, reactivating or revoking an access
+type ChangeStatusRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// CreateAccessKeyRequest is the request body for creating a named API key
+type CreateAccessKeyRequest struct {
+	Name        string     `json:"name" validate:"required,min=1,max=50"`
+	Scopes      []string   `json:"scopes"`
+	ExpiredDate *time.Time `json:"expired_date"`
+	RateLimit   *int       `json:"rate_limit" validate:"omitempty,min=1"`
+}
+
+// UpdateAccessKeyRequest is the request body for updating a named API key
+type UpdateAccessKeyRequest struct {
+	Name        string     `json:"name" validate:"required,min=1,max=50"`
+	Scopes      []string   `json:"scopes"`
+	ExpiredDate *time.Time `json:"expired_date"`
+	RateLimit   *int       `json:"rate_limit" validate:"omitempty,min=1"`
+}
+
+// CreateAccessKeyResponse is the response body for creating a named API key
+type CreateAccessKeyResponse struct {
+	AccessKey
+	APIKey string `json:"api_key"`
+}
+
+// TemporaryGrant grants a permission to an access or a group until it expires
+type TemporaryGrant struct {
+	ID            string                `json:"id" gorm:"type:uuid;primaryKey"`
+	AccessID      *string               `json:"access_id,omitempty" gorm:"type:uuid;index"` // Set for a grant to an access
+	GroupID       *uint                 `json:"group_id,omitempty" gorm:"index"`            // Set for a grant to a group
+	PermissionID  uint                  `json:"permission_id" gorm:"not null;index"`
+	Permission    permission.Permission `json:"permission" gorm:"foreignKey:PermissionID"`
+	Justification string                `json:"justification" gorm:"type:text;not null"`
+	ExpiresAt     time.Time             `json:"expires_at" gorm:"not null;index"`
+	GrantedBy     *string               `json:"granted_by" gorm:"type:uuid"` // Access that created the grant
+	CreatedAt     time.Time             `json:"created_at"`
+}
+
+func (TemporaryGrant) TableName() string {
+	return "temporary_grants"
+}
+
+// BeforeCreate hook to generate UUIDv7 before creating a new temporary grant
+func (g *TemporaryGrant) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == "" {
+		g.ID = utils.GenerateUUIDv7()
+	}
+	return nil
+}
+
+// ActiveAt reports whether the grant has not expired at now
+func (g *TemporaryGrant) ActiveAt(now time.Time) bool {
+	return g.ExpiresAt.After(now)
+}
+
+// CreateTemporaryGrantRequest is the request body for granting a permission until an expiry.
+// Exactly one of AccessID and GroupID, and one of Duration and ExpiresAt, must be set.
+type CreateTemporaryGrantRequest struct {
+	AccessID      *string    `json:"access_id" validate:"omitempty,uuid"`
+	GroupID       *uint      `json:"group_id" validate:"omitempty,min=1"`
+	PermissionID  uint       `json:"permission_id" validate:"required,min=1"`
+	Justification string     `json:"justification" validate:"required,min=5,max=500"`
+	Duration      string     `json:"duration"` // Go duration such as "30m" or "4h"
+	ExpiresAt     *time.Time `json:"expires_at"`
+}
+
+// TemporaryGrantFilter holds the filter options for listing temporary grants
+type TemporaryGrantFilter struct {
+	AccessID *string `json:"access_id"`
+	GroupID  *uint   `json:"group_id"`
+}
+
 // BeforeCreate hook to generate UUIDv7 before creating a new user
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == "" {
 		u.ID = utils.GenerateUUIDv7()
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,187 @@
+package access
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+)
+
+// effectiveSet collects permissions by "resource:action" with every source granting them
+type effectiveSet struct {
+	order   []string
+	entries map[string]*EffectivePermission
+}
+
+func newEffectiveSet() *effectiveSet {
+	return &effectiveSet{entries: make(map[string]*EffectivePermission)}
+}
+
+func (s *effectiveSet) add(p permission.Permission, source PermissionSource) {
+	key := p.Resource + ":" + p.Action
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &EffectivePermission{
+			ID:       p.ID,
+			Name:     p.Name,
+			Resource: p.Resource,
+			Action:   p.Action,
+			Wildcard: p.Resource == permission.Wildcard || p.Action == permission.Wildcard,
+			Implies:  permission.ImpliedActions(p.Action),
+			Sources:  []PermissionSource{},
+		}
+		s.entries[key] = entry
+		s.order = append(s.order, key)
+	}
+	entry.Sources = append(entry.Sources, source)
+}
+
+func (s *effectiveSet) list() []EffectivePermission {
+	list := make([]EffectivePermission, 0, len(s.order))
+	for _, key := range s.order {
+		list = append(list, *s.entries[key])
+	}
+	return list
+}
+
+// matching returns the entries covering resource:action
+func (s *effectiveSet) matching(resource, action string) []EffectivePermission {
+	var matched []EffectivePermission
+	for _, key := range s.order {
+		entry := s.entries[key]
+		if permission.Matches(entry.Resource, entry.Action, resource, action) {
+			matched = append(matched, *entry)
+		}
+	}
+	return matched
+}
+
+// explainPermissions builds the effective permissions and deny rules of an access with their sources.
+// Temporary grants count only while they have not expired at now.
+func explainPermissions(u *User, now time.Time) (allows, denies *effectiveSet) {
+	allows, denies = newEffectiveSet(), newEffectiveSet()
+
+	refs := make(map[uint]*group.GroupRef)
+	for _, g := range u.GetGroups() {
+		ref := &group.GroupRef{ID: g.ID, Name: g.Name}
+		refs[g.ID] = ref
+		for _, p := range g.Permissions {
+			allows.add(p, PermissionSource{Type: SourceGroup, Group: ref})
+		}
+		for _, p := range g.InheritedPermissions {
+			from := p.InheritedFrom
+			allows.add(p.Permission, PermissionSource{Type: SourceInherited, Group: ref, InheritedFrom: &from})
+		}
+		for _, p := range g.DeniedPermissions {
+			denies.add(p, PermissionSource{Type: SourceGroup, Group: ref})
+		}
+		for _, p := range g.InheritedDenies {
+			from := p.InheritedFrom
+			denies.add(p.Permission, PermissionSource{Type: SourceInherited, Group: ref, InheritedFrom: &from})
+		}
+	}
+	for _, p := range u.DirectPermissions {
+		allows.add(p, PermissionSource{Type: SourceDirect})
+	}
+	for _, p := range u.DeniedPermissions {
+		denies.add(p, PermissionSource{Type: SourceDirect})
+	}
+	for i := range u.TemporaryGrants {
+		grant := &u.TemporaryGrants[i]
+		if !grant.ActiveAt(now) {
+			continue
+		}
+		source := PermissionSource{Type: SourceTemporary, ExpiresAt: &grant.ExpiresAt}
+		if grant.GroupID != nil {
+			source.Group = refs[*grant.GroupID]
+		}
+		allows.add(grant.Permission, source)
+	}
+	return allows, denies
+}
+
+// EffectivePermissions lists every permission and deny rule of an access with the sources granting them
+func (u *User) EffectivePermissions() EffectivePermissionsResponse {
+	allows, denies := explainPermissions(u, time.Now())
+	return EffectivePermissionsResponse{
+		AccessID:          u.ID,
+		Permissions:       allows.list(),
+		DeniedPermissions: denies.list(),
+		Scopes:            u.GetScopes(),
+	}
+}
+
+// CheckPermission decides whether the access may perform action on resource, following
+// the same rules as the permission middleware, and explains the decision
+func (u *User) CheckPermission(resource, action string, now time.Time) CheckPermissionResponse {
+	result := CheckPermissionResponse{
+		AccessID: u.ID,
+		Resource: resource,
+		Action:   action,
+		Decision: DecisionDeny,
+	}
+	pair := resource + ":" + action
+
+	allows, denies := explainPermissions(u, now)
+	if matched := denies.matching(resource, action); len(matched) > 0 {
+		result.MatchedBy = matched
+		result.Reason = fmt.Sprintf("Deny rule %s:%s (%s) matches %s and overrides any allow",
+			matched[0].Resource, matched[0].Action, describeSources(matched[0].Sources), pair)
+		return result
+	}
+
+	matched := allows.matching(resource, action)
+	if len(matched) == 0 {
+		if len(u.GetGroups()) == 0 && len(u.DirectPermissions) == 0 && len(u.activeTemporaryPermissions(now)) == 0 {
+			result.Reason = "The access has no group and no direct permission"
+		} else {
+			result.Reason = "No group, inherited, direct or temporary permission grants " + pair
+		}
+		return result
+	}
+	result.MatchedBy = matched
+
+	if err := u.CheckAccess(now); err != nil {
+		result.Reason = fmt.Sprintf("%s is granted but the access cannot authenticate: %v", pair, err)
+		return result
+	}
+
+	best := matched[0]
+	result.Decision = DecisionAllow
+	result.Allowed = true
+	result.Reason = fmt.Sprintf("Granted by %s:%s (%s)%s",
+		best.Resource, best.Action, describeSources(best.Sources), describeMatch(best, action))
+	return result
+}
+
+// describeMatch explains how a granted permission covers a required action
+func describeMatch(granted EffectivePermission, action string) string {
+	switch {
+	case granted.Wildcard:
+		return " through a wildcard"
+	case granted.Action != action:
+		return fmt.Sprintf(" because %s implies %s", granted.Action, action)
+	default:
+		return ""
+	}
+}
+
+// describeSources summarises the sources of a permission for a human reader
+func describeSources(sources []PermissionSource) string {
+	parts := make([]string, 0, len(sources))
+	for _, source := range sources {
+		switch source.Type {
+		case SourceGroup:
+			parts = append(parts, "group "+source.Group.Name)
+		case SourceInherited:
+			parts = append(parts, fmt.Sprintf("group %s inherited from %s", source.Group.Name, source.InheritedFrom.Name))
+		case SourceTemporary:
+			parts = append(parts, "temporary grant until "+source.ExpiresAt.Format(time.RFC3339))
+		default:
+			parts = append(parts, "set on the access")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -1,7 +1,12 @@
 package access
 
 import (
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
 	"apiserver/internal/types"
+	"apiserver/internal/utils"
+	"errors"
+	"log"
 	"time"
 
 	"gorm.io/gorm"
@@ -11,9 +16,38 @@ type Repository interface {
 	FindByAPIKey(apiKey string) (*User, error)
 	UpdateExpiredDate(id string, expiredDate *time.Time) error
 	UpdateRateLimit(id string, rateLimit int) error
+	UpdateGroup(id string, groupID uint) error
+	AddGroup(id string, g *group.Group) error
+	RemoveGroup(id string, groupID uint) error
+	GrantPermission(id string, p *permission.Permission) error
+	RevokePermission(id string, permissionID uint) error
+	DenyPermission(id string, p *permission.Permission) error
+	RemoveDeniedPermission(id string, permissionID uint) error
+	UpdateAllowedCIDRs(id string, cidrs []string) error
+	UpdateKeyAllowedCIDRs(accessID, keyID string, cidrs []string) error
 	GetUserByID(id string) (*User, error)
+	GetAccessByID(id string) (*User, error)
+	GetAccessWithPermissions(id string) (*User, error)
+	FindActiveByID(id string) (*User, error)
+	ListAccessesWithPermissions() ([]User, error)
+	ListAccesses(filter AccessFilter) ([]User, int64, error)
 	CreateUser(user *User) error
 	FindByEmail(email string) (*User, error)
+	RotateAPIKey(id string, apiKey string, previousValidUntil *time.Time) error
+	CreateKey(key *AccessKey) error
+	GetKeys(accessID string) ([]AccessKey, error)
+	GetKey(accessID, keyID string) (*AccessKey, error)
+	UpdateKey(key *AccessKey) error
+	RevokeKey(accessID, keyID string) error
+	ChangeStatus(change *StatusChange) error
+	GetStatusHistory(accessID string) ([]StatusChange, error)
+	FindBySigningKey(id string) (*User, error)
+	UpdateSigningSecret(id string, encrypted *string) error
+	CreateTemporaryGrant(grant *TemporaryGrant) error
+	ListTemporaryGrants(filter TemporaryGrantFilter) ([]TemporaryGrant, error)
+	GetTemporaryGrant(id string) (*TemporaryGrant, error)
+	DeleteTemporaryGrant(id string) error
+	DeleteExpiredTemporaryGrants(now time.Time) ([]TemporaryGrant, error)
 }
 
 // AuthRepositoryImpl implements types.AuthRepository
@@ -28,11 +62,31 @@ func NewAuthRepository(repo Repository) types.AuthRepository {
 func (a *AuthRepositoryImpl) FindByAPIKey(apiKey string) (types.User, error) {
 	user, err := a.repo.FindByAPIKey(apiKey)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, types.ErrInvalidAPIKey
+		}
 		return nil, err
 	}
 	return user, nil
 }
 
+func (a *AuthRepositoryImpl) FindSigningSecret(accessID string) (types.User, string, error) {
+	user, err := a.repo.FindBySigningKey(accessID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", types.ErrInvalidAPIKey
+		}
+		return nil, "", err
+	}
+
+	secret, err := utils.DecryptSecret(*user.SigningSecret)
+	if err != nil {
+		return nil, "", err
+	}
+	user.Credential = CredentialHMAC
+	return user, secret, nil
+}
+
 type repository struct {
 	db *gorm.DB
 }
@@ -41,27 +95,215 @@ func NewRepository(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
+// keyUsageInterval is how stale the last use of a named key may get before it is written again
+const keyUsageInterval = time.Minute
+
 func (r *repository) FindByAPIKey(apiKey string) (*User, error) {
-	var user User
+	// Keys are stored as digests, so look up by the digest of the presented key
+	// The previous key of a rotation is accepted until its grace period ends
+	keyHash := utils.HashAPIKey(apiKey)
+	now := time.Now()
+	user, err := r.findAuthUser(r.db.Where(
+		"(api_key_hash = ? OR (previous_api_key_hash = ? AND previous_api_key_expires_at > ?))", keyHash, keyHash, now))
+	if err == nil {
+		user.Credential = CredentialPrimary
+		if user.APIKeyHash != keyHash {
+			user.Credential = CredentialPrevious
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
 
-	// Query with status check and expired date check
-	// Either expired_date is NULL (never expires) or expired_date is in the future
-	err := r.db.Preload("Group.Permissions", "status_id = ?", 0).
-		Preload("Group", "status_id = ?", 0).
-		Where("api_key = ? AND status_id = ? AND (expired_date IS NULL OR expired_date > ?)", 
-			apiKey, 0, time.Now()).
-		First(&user).Error
+	// Fall back to the named keys owned by an access
+	var key AccessKey
+	if err := r.db.Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	if err := key.CheckAccess(now); err != nil {
+		return nil, err
+	}
+
+	user, err = r.findAuthUser(r.db.Where("id = ?", key.AccessID))
+	if err != nil {
+		return nil, err
+	}
+	user.Credential = CredentialKeyPrefix + key.Name
+	user.ActiveKey = &key
+
+	// Record key usage, at most once per keyUsageInterval so that busy keys do not write on every request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= keyUsageInterval {
+		if err := r.db.Model(&AccessKey{}).Where("id = ?", key.ID).Update("last_used_at", now).Error; err != nil {
+			log.Printf("Warning: failed to record use of key %s of access %s: %v", key.ID, key.AccessID, err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+
+	return user, nil
+}
 
+// findAuthUser loads an access matching the query together with its groups and permissions.
+// Accesses that are not active or have expired are reported with a specific error.
+func (r *repository) findAuthUser(query *gorm.DB) (*User, error) {
+	user, err := r.findWithPermissions(query)
 	if err != nil {
 		return nil, err
 	}
+	if err := user.CheckAccess(time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindActiveByID loads an access that can authenticate, like FindByAPIKey, for work done on its behalf
+func (r *repository) FindActiveByID(id string) (*User, error) {
+	return r.findAuthUser(r.db.Where("id = ?", id))
+}
+
+// GetAccessWithPermissions loads an access in any status with everything that makes up its effective permissions
+func (r *repository) GetAccessWithPermissions(id string) (*User, error) {
+	return r.findWithPermissions(r.db.Where("id = ?", id))
+}
+
+// findWithPermissions loads an access matching the query together with its groups,
+// inherited permissions, direct grants, temporary grants and deny rules
+func (r *repository) findWithPermissions(query *gorm.DB) (*User, error) {
+	var user User
+	if err := preloadPermissions(query).First(&user).Error; err != nil {
+		return nil, err
+	}
+	if err := r.loadInheritedPermissions(&user); err != nil {
+		return nil, err
+	}
+	if err := r.loadTemporaryGrants(&user); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
+// ListAccessesWithPermissions loads every active access with everything that makes up its effective permissions
+func (r *repository) ListAccessesWithPermissions() ([]User, error) {
+	var users []User
+	if err := preloadPermissions(r.db.Where("status_id = ?", 0)).Order("created_at").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	refs := make([]*User, len(users))
+	for i := range users {
+		refs[i] = &users[i]
+	}
+	if err := r.loadInheritedPermissions(refs...); err != nil {
+		return nil, err
+	}
+	if err := r.loadTemporaryGrants(refs...); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// preloadPermissions preloads the active groups, grants and deny rules of accesses
+func preloadPermissions(query *gorm.DB) *gorm.DB {
+	return query.Preload("Group.Permissions", "status_id = ?", 0).
+		Preload("Group", "status_id = ?", 0).
+		Preload("Groups.Permissions", "status_id = ?", 0).
+		Preload("Groups", "status_id = ?", 0).
+		Preload("DirectPermissions", "status_id = ?", 0).
+		Preload("DeniedPermissions", "status_id = ?", 0).
+		Preload("Group.DeniedPermissions", "status_id = ?", 0).
+		Preload("Groups.DeniedPermissions", "status_id = ?", 0)
+}
+
+// loadInheritedPermissions fills the permissions the groups of accesses inherit from their ancestors
+func (r *repository) loadInheritedPermissions(users ...*User) error {
+	var groups []*group.Group
+	for _, user := range users {
+		groups = append(groups, user.Group)
+		for i := range user.Groups {
+			groups = append(groups, &user.Groups[i])
+		}
+	}
+	return group.LoadInheritedPermissions(r.db, groups...)
+}
+
+// loadTemporaryGrants fills the unexpired temporary grants of accesses and of their groups.
+// Grants to a group are inherited by the groups below it, like its permissions.
+func (r *repository) loadTemporaryGrants(users ...*User) error {
+	var accessIDs []string
+	var memberIDs []uint
+	for _, user := range users {
+		accessIDs = append(accessIDs, user.ID)
+		for _, g := range user.GetGroups() {
+			memberIDs = append(memberIDs, g.ID)
+		}
+	}
+	if len(accessIDs) == 0 {
+		return nil
+	}
+
+	ancestors, err := group.LoadAncestorIDs(r.db, memberIDs...)
+	if err != nil {
+		return err
+	}
+	groupIDs := memberIDs
+	for _, ids := range ancestors {
+		groupIDs = append(groupIDs, ids...)
+	}
+
+	var grants []TemporaryGrant
+	query := r.db.Preload("Permission", "status_id = ?", 0).Where("expires_at > ?", time.Now())
+	if len(groupIDs) > 0 {
+		query = query.Where("access_id IN ? OR group_id IN ?", accessIDs, groupIDs)
+	} else {
+		query = query.Where("access_id IN ?", accessIDs)
+	}
+	if err := query.Order("expires_at").Find(&grants).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		inGroup := make(map[uint]bool)
+		for _, g := range user.GetGroups() {
+			inGroup[g.ID] = true
+			for _, ancestorID := range ancestors[g.ID] {
+				inGroup[ancestorID] = true
+			}
+		}
+		for _, grant := range grants {
+			if grant.Permission.ID == 0 {
+				continue // Permission is inactive
+			}
+			if (grant.AccessID != nil && *grant.AccessID == user.ID) || (grant.GroupID != nil && inGroup[*grant.GroupID]) {
+				user.TemporaryGrants = append(user.TemporaryGrants, grant)
+			}
+		}
+	}
+	return nil
+}
+
 func (r *repository) UpdateExpiredDate(id string, expiredDate *time.Time) error {
 	return r.db.Model(&User{}).Where("id = ?", id).Update("expired_date", expiredDate).Error
 }
 
+// FindBySigningKey loads an access with a signing secret for HMAC authentication
+func (r *repository) FindBySigningKey(id string) (*User, error) {
+	return r.findAuthUser(r.db.Where("id = ? AND signing_secret IS NOT NULL", id))
+}
+
+// UpdateSigningSecret stores a new encrypted signing secret, or removes it when nil
+func (r *repository) UpdateSigningSecret(id string, encrypted *string) error {
+	var createdAt *time.Time
+	if encrypted != nil {
+		now := time.Now()
+		createdAt = &now
+	}
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"signing_secret":            encrypted,
+		"signing_secret_created_at": createdAt,
+	}).Error
+}
+
 func (r *repository) GetUserByID(id string) (*User, error) {
 	var user User
 	err := r.db.Preload("Group").Where("id = ? AND status_id = ?", id, 0).First(&user).Error
@@ -75,6 +317,40 @@ func (r *repository) UpdateRateLimit(id string, rateLimit int) error {
 	return r.db.Model(&User{}).Where("id = ?", id).Update("rate_limit", rateLimit).Error
 }
 
+func (r *repository) UpdateGroup(id string, groupID uint) error {
+	return r.db.Model(&User{}).Where("id = ?", id).Update("group_id", groupID).Error
+}
+
+// AddGroup adds an access to an additional group
+func (r *repository) AddGroup(id string, g *group.Group) error {
+	return r.db.Model(&User{ID: id}).Association("Groups").Append(g)
+}
+
+// RemoveGroup removes an access from an additional group
+func (r *repository) RemoveGroup(id string, groupID uint) error {
+	return r.db.Model(&User{ID: id}).Association("Groups").Delete(&group.Group{ID: groupID})
+}
+
+// GrantPermission grants a permission directly to an access
+func (r *repository) GrantPermission(id string, p *permission.Permission) error {
+	return r.db.Model(&User{ID: id}).Association("DirectPermissions").Append(p)
+}
+
+// RevokePermission removes a direct permission grant from an access
+func (r *repository) RevokePermission(id string, permissionID uint) error {
+	return r.db.Model(&User{ID: id}).Association("DirectPermissions").Delete(&permission.Permission{ID: permissionID})
+}
+
+func (r *repository) UpdateAllowedCIDRs(id string, cidrs []string) error {
+	return r.db.Model(&User{}).Where("id = ?", id).
+		Select("AllowedCIDRs").Updates(&User{AllowedCIDRs: cidrs}).Error
+}
+
+func (r *repository) UpdateKeyAllowedCIDRs(accessID, keyID string, cidrs []string) error {
+	return r.db.Model(&AccessKey{}).Where("id = ? AND access_id = ?", keyID, accessID).
+		Select("AllowedCIDRs").Updates(&AccessKey{AllowedCIDRs: cidrs}).Error
+}
+
 func (r *repository) CreateUser(user *User) error {
 	return r.db.Create(user).Error
 }
@@ -87,3 +363,184 @@ func (r *repository) FindByEmail(email string) (*User, error) {
 	}
 	return &user, nil
 }
+
+// RotateAPIKey replaces the API key of an access. The current key is kept as the
+// previous key until previousValidUntil; a nil value revokes it immediately.
+func (r *repository) RotateAPIKey(id string, apiKey string, previousValidUntil *time.Time) error {
+	updates := map[string]interface{}{
+		"previous_api_key_hash":       nil,
+		"previous_api_key_expires_at": nil,
+		"api_key_hash":                utils.HashAPIKey(apiKey),
+		"api_key_prefix":              utils.APIKeyPrefix(apiKey),
+	}
+	if previousValidUntil != nil {
+		updates["previous_api_key_hash"] = gorm.Expr("api_key_hash")
+		updates["previous_api_key_expires_at"] = previousValidUntil
+	}
+	return r.db.Model(&User{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *repository) CreateKey(key *AccessKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *repository) GetKeys(accessID string) ([]AccessKey, error) {
+	var keys []AccessKey
+	err := r.db.Where("access_id = ?", accessID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *repository) GetKey(accessID, keyID string) (*AccessKey, error) {
+	var key AccessKey
+	err := r.db.Where("id = ? AND access_id = ?", keyID, accessID).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *repository) UpdateKey(key *AccessKey) error {
+	return r.db.Save(key).Error
+}
+
+// RevokeKey permanently disables a named key; revoked keys are kept for auditing
+func (r *repository) RevokeKey(accessID, keyID string) error {
+	return r.db.Model(&AccessKey{}).
+		Where("id = ? AND access_id = ? AND revoked_at IS NULL", keyID, accessID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "status_id": 1}).Error
+}
+
+// DenyPermission adds a deny rule to an access
+func (r *repository) DenyPermission(id string, p *permission.Permission) error {
+	return r.db.Model(&User{ID: id}).Association("DeniedPermissions").Append(p)
+}
+
+// RemoveDeniedPermission removes a deny rule from an access
+func (r *repository) RemoveDeniedPermission(id string, permissionID uint) error {
+	return r.db.Model(&User{ID: id}).Association("DeniedPermissions").Delete(&permission.Permission{ID: permissionID})
+}
+
+// GetAccessByID returns an access regardless of its status
+func (r *repository) GetAccessByID(id string) (*User, error) {
+	var user User
+	err := r.db.Preload("Group").Preload("Groups").Preload("DirectPermissions").Preload("DeniedPermissions").Where("id = ?", id).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *repository) ListAccesses(filter AccessFilter) ([]User, int64, error) {
+	var users []User
+	var total int64
+
+	query := r.db.Model(&User{})
+
+	// Apply filters
+	if filter.Search != "" {
+		pattern := "%" + utils.EscapeLike(filter.Search) + "%"
+		query = query.Where("(name ILIKE ? OR email ILIKE ?)", pattern, pattern)
+	}
+	if filter.GroupID != nil {
+		query = query.Where("(group_id = ? OR id IN (SELECT access_id FROM access_groups WHERE group_id = ?))",
+			*filter.GroupID, *filter.GroupID)
+	}
+	if filter.StatusID != nil {
+		query = query.Where("status_id = ?", *filter.StatusID)
+	}
+
+	now := time.Now()
+	if filter.Expired != nil {
+		if *filter.Expired {
+			query = query.Where("expired_date IS NOT NULL AND expired_date <= ?", now)
+		} else {
+			query = query.Where("(expired_date IS NULL OR expired_date > ?)", now)
+		}
+	}
+	if filter.ExpiringWithinDays > 0 {
+		query = query.Where("expired_date > ? AND expired_date <= ?", now, now.AddDate(0, 0, filter.ExpiringWithinDays))
+	}
+
+	// Count total records
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply pagination
+	filter.Paginate()
+
+	err := query.Preload("Group").
+		Preload("Groups").
+		Order("created_at DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&users).Error
+
+	return users, total, err
+}
+
+// ChangeStatus updates the status of an access and records the change in its history
+func (r *repository) ChangeStatus(change *StatusChange) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", change.AccessID).Update("status_id", change.ToStatusID).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+}
+
+func (r *repository) GetStatusHistory(accessID string) ([]StatusChange, error) {
+	var changes []StatusChange
+	err := r.db.Where("access_id = ?", accessID).Order("created_at DESC").Find(&changes).Error
+	return changes, err
+}
+
+func (r *repository) CreateTemporaryGrant(grant *TemporaryGrant) error {
+	return r.db.Create(grant).Error
+}
+
+// ListTemporaryGrants lists the unexpired temporary grants, soonest to expire first
+func (r *repository) ListTemporaryGrants(filter TemporaryGrantFilter) ([]TemporaryGrant, error) {
+	query := r.db.Preload("Permission").Where("expires_at > ?", time.Now())
+	if filter.AccessID != nil {
+		query = query.Where("access_id = ?", *filter.AccessID)
+	}
+	if filter.GroupID != nil {
+		query = query.Where("group_id = ?", *filter.GroupID)
+	}
+
+	var grants []TemporaryGrant
+	err := query.Order("expires_at").Find(&grants).Error
+	return grants, err
+}
+
+func (r *repository) GetTemporaryGrant(id string) (*TemporaryGrant, error) {
+	var grant TemporaryGrant
+	if err := r.db.Preload("Permission").Where("id = ?", id).First(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *repository) DeleteTemporaryGrant(id string) error {
+	return r.db.Where("id = ?", id).Delete(&TemporaryGrant{}).Error
+}
+
+// DeleteExpiredTemporaryGrants deletes the grants that expired at or before now and returns them
+func (r *repository) DeleteExpiredTemporaryGrants(now time.Time) ([]TemporaryGrant, error) {
+	var expired []TemporaryGrant
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Permission").Where("expires_at <= ?", now).Find(&expired).Error; err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		ids := make([]string, len(expired))
+		for i, grant := range expired {
+			ids[i] = grant.ID
+		}
+		return tx.Where("id IN ?", ids).Delete(&TemporaryGrant{}).Error
+	})
+	return expired, err
+}
@@ -6,7 +6,7 @@ import (
 
 func RegisterAccessRoutes(app *fiber.App, handler *Handler, authMiddleware fiber.Handler, rateLimitMiddleware fiber.Handler, permissionMiddleware func(string, string) fiber.Handler) {
 	v1 := app.Group("/v1")
-	
+
 	// Public route for creating access
 	v1.Post("/access",
 		authMiddleware,
@@ -14,6 +14,19 @@ func RegisterAccessRoutes(app *fiber.App, handler *Handler, authMiddleware fiber
 		permissionMiddleware("access", "manage"),
 		handler.CreateAccess)
 
+	// Access listing and lookup routes
+	v1.Get("/access",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.ListAccesses)
+
+	v1.Get("/access/:id",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.GetAccess)
+
 	// Protected routes with auth, rate limit, and permission checking
 	v1.Get("/profile",
 		authMiddleware,
@@ -21,6 +34,19 @@ func RegisterAccessRoutes(app *fiber.App, handler *Handler, authMiddleware fiber
 		permissionMiddleware("profile", "read"),
 		handler.GetProfile)
 
+	// Self-service key rotation is available to every access that can read its profile
+	v1.Post("/profile/rotate-key",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("profile", "read"),
+		handler.RotateOwnKey)
+
+	v1.Get("/profile/permissions",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("profile", "read"),
+		handler.GetOwnPermissions)
+
 	// API key expiration management routes
 	v1.Put("/access/:id/expired-date",
 		authMiddleware,
@@ -40,4 +66,169 @@ func RegisterAccessRoutes(app *fiber.App, handler *Handler, authMiddleware fiber
 		rateLimitMiddleware,
 		permissionMiddleware("access", "manage"),
 		handler.UpdateRateLimit)
-}
\ No newline at end of file
+
+	// API key rotation route
+	v1.Post("/access/:id/rotate-key",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RotateKey)
+
+	v1.Put("/access/:id/group",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.UpdateGroup)
+
+	// Additional group memberships and direct permission grants
+	v1.Post("/access/:id/groups",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.AddGroup)
+
+	v1.Delete("/access/:id/groups/:groupId",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RemoveGroup)
+
+	v1.Post("/access/:id/permissions",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.GrantPermission)
+
+	v1.Delete("/access/:id/permissions/:permissionId",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RevokePermission)
+
+	// Effective permission inspection
+	v1.Get("/access/:id/effective-permissions",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.GetEffectivePermissions)
+
+	v1.Post("/access/:id/check",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.CheckPermission)
+
+	// Deny rules override any allow
+	v1.Post("/access/:id/denied-permissions",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.DenyPermission)
+
+	v1.Delete("/access/:id/denied-permissions/:permissionId",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RemoveDeniedPermission)
+
+	// IP allowlist routes
+	v1.Get("/access/:id/ip-allowlist",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.GetIPAllowlist)
+
+	v1.Put("/access/:id/ip-allowlist",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.UpdateIPAllowlist)
+
+	// HMAC request signing routes
+	v1.Post("/access/:id/signing-secret",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.CreateSigningSecret)
+
+	v1.Delete("/access/:id/signing-secret",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.DeleteSigningSecret)
+
+	// Access status management routes
+	v1.Post("/access/:id/suspend",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.SuspendAccess)
+
+	v1.Post("/access/:id/reactivate",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.ReactivateAccess)
+
+	v1.Post("/access/:id/revoke",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RevokeAccess)
+
+	v1.Get("/access/:id/status-history",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.GetStatusHistory)
+
+	// Named API key management routes
+	v1.Get("/access/:id/keys",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.ListKeys)
+
+	v1.Post("/access/:id/keys",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.CreateKey)
+
+	v1.Get("/access/:id/keys/:keyId",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.GetKey)
+
+	v1.Put("/access/:id/keys/:keyId",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.UpdateKey)
+
+	v1.Delete("/access/:id/keys/:keyId",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RevokeKey)
+
+	// Time-bound permission grants to accesses and groups
+	v1.Post("/temporary-grants",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.CreateTemporaryGrant)
+
+	v1.Get("/temporary-grants",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.ListTemporaryGrants)
+
+	v1.Delete("/temporary-grants/:grantId",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RevokeTemporaryGrant)
+}
@@ -0,0 +1,41 @@
+package approval
+
+import (
+	"encoding/json"
+	"log"
+
+	"apiserver/internal/modules/audit"
+)
+
+// Lifecycle events of a pending change, recorded as the method of audit log entries
+const (
+	EventPropose = "PROPOSE"
+	EventApprove = "APPROVE"
+	EventReject  = "REJECT"
+	EventExecute = "EXECUTE"
+	EventExpire  = "EXPIRE"
+)
+
+// approvalAgent identifies audit entries written by the approval workflow
+const approvalAgent = "approval-workflow"
+
+// recordEvent writes a lifecycle event of a change to the audit log. Every event of a change
+// shares the path /v1/approvals/<id>, so its whole history can be listed with the path filter.
+func recordEvent(auditRepo audit.Repository, event string, change *PendingChange, actorID string, statusCode int) {
+	body, _ := json.Marshal(change)
+	status := int16(0) // Active
+	entry := &audit.AuditLog{
+		Method:      event,
+		Path:        "/v1/approvals/" + change.ID,
+		StatusCode:  statusCode,
+		RequestBody: string(body),
+		UserAgent:   approvalAgent,
+		StatusID:    &status,
+	}
+	if actorID != "" {
+		entry.AccessID = &actorID
+	}
+	if err := auditRepo.CreateAuditLog(entry); err != nil {
+		log.Printf("Failed to audit %s of pending change %s: %v", event, change.ID, err)
+	}
+}
@@ -0,0 +1,423 @@
+package approval
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// effect is how a request changes the effective permissions of the accesses and groups it reaches
+type effect struct {
+	current []permission.Permission // Permissions they hold before the change
+	added   []permission.Permission // Permissions the change gives them
+	lifted  []permission.Permission // Deny rules the change removes from them
+	unknown bool                    // The change cannot be evaluated before it runs
+}
+
+// gains reports whether the change lets someone do resource:action who could not before.
+// A change that cannot be evaluated is assumed to grant everything.
+func (e *effect) gains(resource, action string) bool {
+	if e.unknown {
+		return true
+	}
+	if permission.Allows(e.added, resource, action) && !permission.Allows(e.current, resource, action) {
+		return true
+	}
+	return permission.OverlapsAny(e.lifted, permission.Permission{Resource: resource, Action: action})
+}
+
+// analyzer computes the effect of a request on one route
+type analyzer func(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error)
+
+// analyzers are keyed by method and route pattern. Routes without an analyzer do not change
+// effective permissions.
+var analyzers = map[string]analyzer{
+	"POST /v1/groups":                                        analyzeCreateGroup,
+	"PUT /v1/groups/:id/permissions":                         analyzeGroupPermissions,
+	"PUT /v1/groups/:id/denied-permissions":                  analyzeGroupDenies,
+	"PUT /v1/groups/:id/parents":                             analyzeGroupParents,
+	"POST /v1/groups/:id/rollback/:version":                  analyzeGroupRollback,
+	"POST /v1/groups/:id/restore":                            analyzeUnknown,
+	"PATCH /v1/groups/:id":                                   analyzeRenameGroup,
+	"DELETE /v1/groups/:id":                                  analyzeDeleteGroup,
+	"POST /v1/access":                                        analyzeCreateAccess,
+	"PUT /v1/access/:id/group":                               analyzeAccessPrimaryGroup,
+	"POST /v1/access/:id/groups":                             analyzeAccessAddGroup,
+	"DELETE /v1/access/:id/groups/:groupId":                  analyzeAccessRemoveGroup,
+	"POST /v1/access/:id/permissions":                        analyzeAccessGrant,
+	"POST /v1/access/:id/keys":                               analyzeKeyIssue,
+	"POST /v1/access/:id/rotate-key":                         analyzeKeyIssue,
+	"POST /v1/access/:id/reactivate":                         analyzeReactivateAccess,
+	"DELETE /v1/access/:id/denied-permissions/:permissionId": analyzeAccessRemoveDeny,
+	"POST /v1/temporary-grants":                              analyzeTemporaryGrant,
+	"DELETE /v1/permissions/:id":                             analyzeDeletePermission,
+}
+
+// effectOf returns the effect of the request, or nil when it does not change effective permissions
+func (g *Gate) effectOf(c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	analyze, ok := analyzers[c.Method()+" "+c.Route().Path]
+	if !ok {
+		return nil, nil
+	}
+	return analyze(g, c, targets)
+}
+
+// A new group starts without members; its children do not exist yet either, so only
+// the group itself gains permissions
+func analyzeCreateGroup(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	added, err := g.permissionsByIDs(targets.PermissionIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range targets.ParentIDs {
+		parent, err := g.loadGroup(id)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			added = append(added, parent.EffectivePermissions()...)
+		}
+	}
+	return &effect{added: added}, nil
+}
+
+func analyzeGroupPermissions(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	current, err := g.groupParam(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	added, err := g.permissionsByIDs(targets.PermissionIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &effect{current: effectivePermissions(current), added: added}, nil
+}
+
+// Deny rules of the group that the new list leaves out are lifted
+func analyzeGroupDenies(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	current, err := g.groupParam(c, "id")
+	if err != nil || current == nil {
+		return &effect{}, err
+	}
+	keep := make(map[uint]bool, len(targets.PermissionIDs))
+	for _, id := range targets.PermissionIDs {
+		keep[id] = true
+	}
+	e := &effect{current: current.EffectivePermissions()}
+	for _, p := range current.DeniedPermissions {
+		if !keep[p.ID] {
+			e.lifted = append(e.lifted, p)
+		}
+	}
+	return e, nil
+}
+
+// New parents add their effective permissions; inherited deny rules that no new parent
+// carries are lifted
+func analyzeGroupParents(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	current, err := g.groupParam(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	e := &effect{current: effectivePermissions(current)}
+	var denies []permission.Permission
+	for _, id := range targets.ParentIDs {
+		parent, err := g.loadGroup(id)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			e.added = append(e.added, parent.EffectivePermissions()...)
+			denies = append(denies, parent.EffectiveDenies()...)
+		}
+	}
+	if current != nil {
+		for _, p := range current.InheritedDenies {
+			if !permission.OverlapsAny(denies, p.Permission) {
+				e.lifted = append(e.lifted, p.Permission)
+			}
+		}
+	}
+	return e, nil
+}
+
+func analyzeGroupRollback(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	current, err := g.groupParam(c, "id")
+	if err != nil || current == nil {
+		return &effect{}, err
+	}
+	number, err := strconv.Atoi(c.Params("version"))
+	if err != nil {
+		return &effect{}, nil
+	}
+	version, err := g.groups.GetPermissionVersion(current.ID, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &effect{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	added, err := g.permissionsByIDs(version.PermissionIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &effect{current: current.EffectivePermissions(), added: added}, nil
+}
+
+// A restored group gives its permissions back to the groups inheriting from it. Deleted groups
+// cannot be loaded, so the change is not evaluated.
+func analyzeUnknown(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	return &effect{unknown: true}, nil
+}
+
+// Approval rules single out groups by name, so a group renamed to or from such a name
+// cannot be evaluated
+func analyzeRenameGroup(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	name := strings.TrimSpace(targets.Name)
+	if name == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, nil
+	}
+	current, err := g.groups.GetGroupByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(name, current.Name) || (!g.namesGroup(name) && !g.namesGroup(current.Name)) {
+		return nil, nil
+	}
+	return &effect{unknown: true}, nil
+}
+
+// Members of a deleted group move to the reassign_to group
+func analyzeDeleteGroup(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	if targets.ReassignTo == 0 {
+		return nil, nil
+	}
+	current, err := g.groupParam(c, "id")
+	if err != nil {
+		return nil, err
+	}
+	target, err := g.loadGroup(targets.ReassignTo)
+	if err != nil {
+		return nil, err
+	}
+	return &effect{current: effectivePermissions(current), added: effectivePermissions(target)}, nil
+}
+
+func analyzeCreateAccess(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	if targets.GroupID == 0 {
+		return nil, nil
+	}
+	target, err := g.loadGroup(targets.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	return &effect{added: effectivePermissions(target)}, nil
+}
+
+// The new primary group replaces the old one, whose deny rules are lifted
+func analyzeAccessPrimaryGroup(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	e, err := analyzeAccessAddGroup(g, c, targets)
+	if err != nil {
+		return nil, err
+	}
+	user, err := g.loadAccess(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	if user != nil && user.Group != nil && user.Group.ID != targets.GroupID {
+		e.lifted = user.Group.EffectiveDenies()
+	}
+	return e, nil
+}
+
+func analyzeAccessAddGroup(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	current, err := g.accessPermissions(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	target, err := g.loadGroup(targets.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	return &effect{current: current, added: effectivePermissions(target)}, nil
+}
+
+// Leaving a group lifts its deny rules
+func analyzeAccessRemoveGroup(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	left, err := g.groupParam(c, "groupId")
+	if err != nil || left == nil {
+		return &effect{}, err
+	}
+	return &effect{lifted: left.EffectiveDenies()}, nil
+}
+
+// A key for an access lets the admin receiving it act with the permissions of that access
+func analyzeKeyIssue(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	added, err := g.accessPermissions(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	e := &effect{added: added}
+	if actor, ok := c.Locals("user").(types.User); ok {
+		e.current = actor.GetPermissions()
+	}
+	return e, nil
+}
+
+// A suspended or pending access holds no permissions until it is reactivated
+func analyzeReactivateAccess(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	added, err := g.accessPermissions(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	return &effect{added: added}, nil
+}
+
+func analyzeAccessGrant(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	current, err := g.accessPermissions(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	added, err := g.permissionsByIDs([]uint{targets.PermissionID})
+	if err != nil {
+		return nil, err
+	}
+	return &effect{current: current, added: added}, nil
+}
+
+func analyzeAccessRemoveDeny(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	id, err := strconv.ParseUint(c.Params("permissionId"), 10, 32)
+	if err != nil {
+		return &effect{}, nil
+	}
+	lifted, err := g.permissionsByIDs([]uint{uint(id)})
+	if err != nil {
+		return nil, err
+	}
+	return &effect{lifted: lifted}, nil
+}
+
+// A temporary grant goes to an access or to every member of a group
+func analyzeTemporaryGrant(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	added, err := g.permissionsByIDs([]uint{targets.PermissionID})
+	if err != nil {
+		return nil, err
+	}
+	e := &effect{added: added}
+	if targets.AccessID != "" {
+		e.current, err = g.accessPermissions(targets.AccessID)
+	} else if targets.GroupID != 0 {
+		var target *group.Group
+		target, err = g.loadGroup(targets.GroupID)
+		e.current = effectivePermissions(target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Deleting a permission with force=true removes its deny rules along with its grants
+func analyzeDeletePermission(g *Gate, c *fiber.Ctx, targets *requestTargets) (*effect, error) {
+	if !c.QueryBool("force") {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return nil, nil
+	}
+	lifted, err := g.permissionsByIDs([]uint{uint(id)})
+	if err != nil {
+		return nil, err
+	}
+	return &effect{lifted: lifted}, nil
+}
+
+// namesGroup reports whether an approval rule names the group
+func (g *Gate) namesGroup(name string) bool {
+	for _, rule := range g.rules {
+		if containsFold(rule.Groups, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadGroup returns an active group with its effective permissions, or nil when there is none;
+// the handler rejects requests naming a missing group
+func (g *Gate) loadGroup(id uint) (*group.Group, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	target, err := g.groups.GetGroupWithPermissions(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return target, err
+}
+
+// groupParam loads the group named by a route parameter
+func (g *Gate) groupParam(c *fiber.Ctx, name string) (*group.Group, error) {
+	id, err := strconv.ParseUint(c.Params(name), 10, 32)
+	if err != nil {
+		return nil, nil
+	}
+	return g.loadGroup(uint(id))
+}
+
+// loadAccess returns an access with its effective permissions, or nil when there is none
+func (g *Gate) loadAccess(id string) (*access.User, error) {
+	if id == "" {
+		return nil, nil
+	}
+	user, err := g.accesses.GetAccessWithPermissions(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return user, err
+}
+
+// accessPermissions returns the effective permissions of an access, or none when it does not exist
+func (g *Gate) accessPermissions(id string) ([]permission.Permission, error) {
+	user, err := g.loadAccess(id)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return user.GetPermissions(), nil
+}
+
+// permissionsByIDs loads the active permissions among ids
+func (g *Gate) permissionsByIDs(ids []uint) ([]permission.Permission, error) {
+	var wanted []uint
+	for _, id := range ids {
+		if id != 0 {
+			wanted = append(wanted, id)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+	return g.permissions.GetPermissionsByIDs(wanted)
+}
+
+func effectivePermissions(g *group.Group) []permission.Permission {
+	if g == nil {
+		return nil
+	}
+	return g.EffectivePermissions()
+}
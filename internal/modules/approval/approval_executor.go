@@ -0,0 +1,50 @@
+package approval
+
+import (
+	"fmt"
+
+	"apiserver/internal/middleware"
+	"apiserver/internal/modules/audit"
+	"apiserver/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// PrincipalLoader loads an access that can still authenticate
+type PrincipalLoader func(accessID string) (types.User, error)
+
+// Executor runs approved changes through the app as the access that proposed them,
+// so they go through the same middleware, validation and audit as a direct request
+type Executor struct {
+	app  *fiber.App
+	load PrincipalLoader
+}
+
+func NewExecutor(app *fiber.App, load PrincipalLoader) *Executor {
+	return &Executor{app: app, load: load}
+}
+
+// Execute replays the request of a change and returns the status code and body of the response.
+// The body is stored with the change, so keys and secrets it returns are redacted.
+func (e *Executor) Execute(change *PendingChange) (int, string, error) {
+	proposer, err := e.load(change.ProposedBy)
+	if err != nil {
+		return 0, "", fmt.Errorf("the access that proposed the change can no longer authenticate: %w", err)
+	}
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(change.Method)
+	ctx.Request.SetRequestURI(change.Path)
+	ctx.Request.Header.SetUserAgent(approvalAgent)
+	if change.ContentType != "" {
+		ctx.Request.Header.SetContentType(change.ContentType)
+	}
+	ctx.Request.SetBodyString(change.Body)
+	ctx.SetUserValue(middleware.LocalApprovedChange, change.ID)
+	ctx.SetUserValue(middleware.LocalApprovedPrincipal, proposer)
+
+	e.app.Handler()(ctx)
+	body := audit.RedactSecrets(string(ctx.Response.Body()), string(ctx.Response.Header.ContentType()))
+	return ctx.Response.StatusCode(), body, nil
+}
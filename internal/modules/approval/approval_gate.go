@@ -0,0 +1,272 @@
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"apiserver/internal/middleware"
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/audit"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Rule describes privileged requests that need the approval of a second admin
+type Rule struct {
+	Operation   string   `json:"operation"`   // Name recorded on the pending change, e.g. grant-access-manage
+	Methods     []string `json:"methods"`     // Optional HTTP methods, empty matches any method
+	Path        string   `json:"path"`        // Optional route pattern such as /v1/groups/:id/permissions, or a prefix when it ends with "*"
+	Permissions []string `json:"permissions"` // Optional "resource:action" keys; only requests letting an access or group gain one of them match
+	Groups      []string `json:"groups"`      // Optional group names; only requests targeting one of them match
+}
+
+// LoadRules reads approval rules from a JSON file. A missing file yields no rules,
+// in which case no request needs approval.
+func LoadRules(path string) ([]Rule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid approval rules in %s: %w", path, err)
+	}
+	for i, rule := range rules {
+		if rule.Operation == "" {
+			return nil, fmt.Errorf("approval rule %d in %s needs an operation", i, path)
+		}
+		if rule.Path == "" && len(rule.Permissions) == 0 && len(rule.Groups) == 0 {
+			return nil, fmt.Errorf("approval rule %s in %s needs a path, permissions or groups", rule.Operation, path)
+		}
+		for _, key := range rule.Permissions {
+			if _, _, ok := permission.SplitKey(key); !ok {
+				return nil, fmt.Errorf("approval rule %s in %s has an invalid permission '%s'", rule.Operation, path, key)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// requestTargets are the fields of a request body that approval rules look at
+type requestTargets struct {
+	PermissionID  uint   `json:"permission_id" form:"permission_id"`
+	PermissionIDs []uint `json:"permission_ids" form:"permission_ids"`
+	GroupID       uint   `json:"group_id" form:"group_id"`
+	ParentIDs     []uint `json:"parent_ids" form:"parent_ids"`
+	AccessID      string `json:"access_id" form:"access_id"`
+	ReassignTo    uint   `json:"reassign_to" form:"reassign_to" query:"reassign_to"` // Group receiving the members of a deleted group
+	Name          string `json:"name" form:"name"`                                   // New name of a renamed group
+}
+
+// Gate holds requests matching an approval rule as pending changes instead of executing them
+type Gate struct {
+	rules       []Rule
+	repo        Repository
+	permissions permission.Repository
+	groups      group.Repository
+	accesses    access.Repository
+	auditRepo   audit.Repository
+	ttl         time.Duration
+}
+
+func NewGate(rules []Rule, repo Repository, permissionRepo permission.Repository, groupRepo group.Repository, accessRepo access.Repository, auditRepo audit.Repository, ttl time.Duration) *Gate {
+	return &Gate{
+		rules:       rules,
+		repo:        repo,
+		permissions: permissionRepo,
+		groups:      groupRepo,
+		accesses:    accessRepo,
+		auditRepo:   auditRepo,
+		ttl:         ttl,
+	}
+}
+
+// Wrap returns a permissionMiddleware for Register*Routes functions. Requests matching a rule
+// are checked against the route permission and stored as a pending change; every other
+// request, and approved changes being executed, go through require unchanged.
+func (g *Gate) Wrap(require func(string, string) fiber.Handler) func(string, string) fiber.Handler {
+	if len(g.rules) == 0 {
+		return require
+	}
+	return func(resource, action string) fiber.Handler {
+		next := require(resource, action)
+		return func(c *fiber.Ctx) error {
+			if c.Locals(middleware.LocalApprovedChange) != nil {
+				return next(c)
+			}
+
+			rule, err := g.match(c)
+			if err != nil {
+				log.Printf("Failed to evaluate approval rules for %s %s: %v", c.Method(), c.Path(), err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Failed to check whether the request needs approval",
+				})
+			}
+			if rule == nil {
+				return next(c)
+			}
+
+			// Only accesses allowed to make the change can propose it
+			if ok, err := middleware.AuthorizePermission(c, resource, action); !ok {
+				return err
+			}
+			return g.propose(c, rule, resource, action)
+		}
+	}
+}
+
+// match returns the first rule matching the request, if any. Permission rules look at what the
+// request changes in the effective permissions of the accesses and groups it reaches, whichever
+// fields and route it uses to do so.
+func (g *Gate) match(c *fiber.Ctx) (*Rule, error) {
+	method, route := c.Method(), c.Route().Path
+
+	var targets *requestTargets
+	var change *effect
+	analyzed := false
+	for i := range g.rules {
+		rule := &g.rules[i]
+		if len(rule.Methods) > 0 && !containsFold(rule.Methods, method) {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(rule.Path, "*"); ok {
+			if !strings.HasPrefix(route, prefix) {
+				continue
+			}
+		} else if rule.Path != "" && route != rule.Path {
+			continue
+		}
+
+		if targets == nil {
+			targets = &requestTargets{}
+			// An unreadable query or body is rejected by the handler as well
+			_ = c.QueryParser(targets)
+			if len(c.Body()) > 0 {
+				_ = c.BodyParser(targets)
+			}
+		}
+		if len(rule.Permissions) > 0 {
+			if !analyzed {
+				var err error
+				if change, err = g.effectOf(c, targets); err != nil {
+					return nil, err
+				}
+				analyzed = true
+			}
+			if change == nil || !gainsAny(change, rule.Permissions) {
+				continue
+			}
+		}
+		if len(rule.Groups) > 0 {
+			matched, err := g.targetsGroup(c, targets, rule.Groups)
+			if err != nil {
+				return nil, err
+			}
+			if !matched {
+				continue
+			}
+		}
+		return rule, nil
+	}
+	return nil, nil
+}
+
+// gainsAny reports whether the change lets someone gain one of keys
+func gainsAny(change *effect, keys []string) bool {
+	for _, key := range keys {
+		resource, action, _ := permission.SplitKey(key)
+		if change.gains(resource, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetsGroup reports whether the request targets one of the named groups, through the :id
+// of a /v1/groups route or the group_id, parent_ids or reassign_to of the request
+func (g *Gate) targetsGroup(c *fiber.Ctx, targets *requestTargets, names []string) (bool, error) {
+	ids := append([]uint{}, targets.ParentIDs...)
+	if targets.GroupID != 0 {
+		ids = append(ids, targets.GroupID)
+	}
+	if targets.ReassignTo != 0 {
+		ids = append(ids, targets.ReassignTo)
+	}
+	if strings.HasPrefix(c.Route().Path, "/v1/groups/:id") {
+		if id, err := strconv.ParseUint(c.Params("id"), 10, 32); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+
+	for _, id := range ids {
+		target, err := g.groups.GetGroupByID(id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if containsFold(names, target.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// propose stores the request as a pending change and answers 202 Accepted
+func (g *Gate) propose(c *fiber.Ctx, rule *Rule, resource, action string) error {
+	proposer := c.Locals("user").(types.User)
+	change := &PendingChange{
+		Operation:   rule.Operation,
+		Method:      c.Method(),
+		Path:        c.OriginalURL(),
+		Route:       c.Route().Path,
+		Body:        string(c.Body()),
+		ContentType: string(c.Request().Header.ContentType()),
+		Resource:    resource,
+		Action:      action,
+		State:       StatePending,
+		ProposedBy:  proposer.GetID(),
+		ExpiresAt:   time.Now().Add(g.ttl),
+	}
+	if err := g.repo.CreateChange(change); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to create pending change",
+		})
+	}
+	recordEvent(g.auditRepo, EventPropose, change, change.ProposedBy, fiber.StatusAccepted)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": fmt.Sprintf("'%s' requires the approval of another admin; pending change %s was created", rule.Operation, change.ID),
+		"data":    change,
+	})
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
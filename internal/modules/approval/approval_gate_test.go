@@ -0,0 +1,256 @@
+// USAGE
+//   go test ./internal/modules/approval -v -run TestGate
+
+package approval
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"apiserver/internal/middleware"
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/audit"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Permissions known to the stub repositories
+var (
+	examplesRead = permission.Permission{ID: 1, Resource: "examples", Action: "read"}
+	accessManage = permission.Permission{ID: 2, Resource: "access", Action: "manage"}
+	everything   = permission.Permission{ID: 3, Resource: "*", Action: "*"}
+)
+
+type stubPermissions struct{ permission.Repository }
+
+func (stubPermissions) GetPermissionsByIDs(ids []uint) ([]permission.Permission, error) {
+	var found []permission.Permission
+	for _, id := range ids {
+		for _, p := range []permission.Permission{examplesRead, accessManage, everything} {
+			if p.ID == id {
+				found = append(found, p)
+			}
+		}
+	}
+	return found, nil
+}
+
+// stubGroups serves Admin (1), Editor (2), Operators (3) and Restricted (4), a child of
+// Editor that denies access:manage
+type stubGroups struct{ group.Repository }
+
+func (stubGroups) GetGroupWithPermissions(id uint) (*group.Group, error) {
+	switch id {
+	case 1:
+		return &group.Group{ID: 1, Name: "Admin", Permissions: []permission.Permission{everything}}, nil
+	case 2:
+		return &group.Group{ID: 2, Name: "Editor", Permissions: []permission.Permission{examplesRead}}, nil
+	case 3:
+		return &group.Group{ID: 3, Name: "Operators", Permissions: []permission.Permission{accessManage}}, nil
+	case 4:
+		return &group.Group{ID: 4, Name: "Restricted", DeniedPermissions: []permission.Permission{accessManage},
+			InheritedPermissions: []group.InheritedPermission{{Permission: examplesRead, InheritedFrom: group.GroupRef{ID: 2, Name: "Editor"}}}}, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r stubGroups) GetGroupByID(id uint) (*group.Group, error) {
+	return r.GetGroupWithPermissions(id)
+}
+
+func (stubGroups) GetPermissionVersion(groupID uint, version int) (*group.GroupPermissionVersion, error) {
+	if groupID == 2 && version == 1 {
+		return &group.GroupPermissionVersion{GroupID: 2, Version: 1, PermissionIDs: []uint{1, 2}}, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// stubAccesses serves an editor, an operator that already holds access:manage and a member of Admin
+type stubAccesses struct{ access.Repository }
+
+func (stubAccesses) GetAccessWithPermissions(id string) (*access.User, error) {
+	groups := stubGroups{}
+	switch id {
+	case "editor":
+		editor, _ := groups.GetGroupWithPermissions(2)
+		return &access.User{ID: id, Group: editor}, nil
+	case "operator":
+		operators, _ := groups.GetGroupWithPermissions(3)
+		return &access.User{ID: id, Group: operators}, nil
+	case "root":
+		admin, _ := groups.GetGroupWithPermissions(1)
+		return &access.User{ID: id, Group: admin}, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+type stubChanges struct {
+	Repository
+	created []*PendingChange
+}
+
+func (r *stubChanges) CreateChange(change *PendingChange) error {
+	change.ID = "change"
+	r.created = append(r.created, change)
+	return nil
+}
+
+type stubAudit struct{ audit.Repository }
+
+func (stubAudit) CreateAuditLog(*audit.AuditLog) error { return nil }
+
+func TestGate(t *testing.T) {
+	rules, err := LoadRules("../../../configs/approval-rules.example.json")
+	if err != nil {
+		t.Fatalf("example rules: %v", err)
+	}
+
+	// operator holds access:manage only, admin everything
+	operator, _ := stubAccesses{}.GetAccessWithPermissions("operator")
+	admin := &access.User{ID: "admin", DirectPermissions: []permission.Permission{everything}}
+
+	tests := []struct {
+		name          string
+		actor         *access.User // Defaults to admin
+		method        string
+		url           string
+		body          string
+		wantOperation string // Empty when the request is applied without approval
+	}{
+		{name: "Create group with access:manage", method: "POST", url: "/v1/groups", body: `{"name":"ops","permission_ids":[2]}`, wantOperation: "grant-privileged-permission"},
+		{name: "Create group inheriting access:manage", method: "POST", url: "/v1/groups", body: `{"name":"ops","parent_ids":[3]}`, wantOperation: "grant-privileged-permission"},
+		{name: "Create group inheriting from Admin", method: "POST", url: "/v1/groups", body: `{"name":"ops","parent_ids":[1]}`, wantOperation: "grant-privileged-permission"},
+		{name: "Create unprivileged group", method: "POST", url: "/v1/groups", body: `{"name":"readers","permission_ids":[1],"parent_ids":[2]}`},
+		{name: "Name Admin as parent", method: "PUT", url: "/v1/groups/2/parents", body: `{"parent_ids":[1]}`, wantOperation: "grant-privileged-permission"},
+		{name: "Remove the parents of a group", method: "PUT", url: "/v1/groups/4/parents", body: `{"parent_ids":[]}`},
+		{name: "Replace group permissions with access:manage", method: "PUT", url: "/v1/groups/2/permissions", body: `{"permission_ids":[1,2]}`, wantOperation: "grant-privileged-permission"},
+		{name: "Replace group permissions", method: "PUT", url: "/v1/groups/2/permissions", body: `{"permission_ids":[1]}`},
+		{name: "Keep access:manage of a group", method: "PUT", url: "/v1/groups/3/permissions", body: `{"permission_ids":[2]}`},
+		{name: "Roll back to a version with access:manage", method: "POST", url: "/v1/groups/2/rollback/1", wantOperation: "grant-privileged-permission"},
+		{name: "Lift a group deny", method: "PUT", url: "/v1/groups/4/denied-permissions", body: `{"permission_ids":[]}`, wantOperation: "grant-privileged-permission"},
+		{name: "Keep a group deny", method: "PUT", url: "/v1/groups/4/denied-permissions", body: `{"permission_ids":[2]}`},
+		{name: "Restore a group", method: "POST", url: "/v1/groups/5/restore", wantOperation: "grant-privileged-permission"},
+		{name: "Move members to a privileged group", method: "DELETE", url: "/v1/groups/2?reassign_to=3", wantOperation: "grant-privileged-permission"},
+		{name: "Delete a group", method: "DELETE", url: "/v1/groups/2"},
+		{name: "Rename a group", method: "PATCH", url: "/v1/groups/2", body: `{"name":"writers"}`},
+		{name: "Edit the Admin group", method: "PATCH", url: "/v1/groups/1", body: `{"description":"root"}`, wantOperation: "edit-admin-group"},
+		{name: "Rename the Admin group", method: "PATCH", url: "/v1/groups/1", body: `{"name":"root"}`, wantOperation: "grant-privileged-permission"},
+		{name: "Rename a group to Admin", method: "PATCH", url: "/v1/groups/2", body: `{"name":"admin"}`, wantOperation: "grant-privileged-permission"},
+		{name: "Create access in a privileged group", method: "POST", url: "/v1/access", body: `{"email":"a@example.com","full_name":"Ann","group_id":3}`, wantOperation: "grant-privileged-permission"},
+		{name: "Create access in the Admin group", method: "POST", url: "/v1/access", body: `{"email":"a@example.com","full_name":"Ann","group_id":1}`, wantOperation: "grant-privileged-permission"},
+		{name: "Create access", method: "POST", url: "/v1/access", body: `{"email":"a@example.com","full_name":"Ann","group_id":2}`},
+		{name: "Add access to a privileged group", method: "POST", url: "/v1/access/editor/groups", body: `{"group_id":3}`, wantOperation: "grant-privileged-permission"},
+		{name: "Move access to a privileged primary group", method: "PUT", url: "/v1/access/editor/group", body: `{"group_id":3}`, wantOperation: "grant-privileged-permission"},
+		{name: "Add operator to another privileged group", method: "POST", url: "/v1/access/operator/groups", body: `{"group_id":3}`},
+		{name: "Grant a wildcard directly", method: "POST", url: "/v1/access/editor/permissions", body: `{"permission_id":3}`, wantOperation: "grant-privileged-permission"},
+		{name: "Grant a permission directly", method: "POST", url: "/v1/access/editor/permissions", body: `{"permission_id":1}`},
+		{name: "Lift an access deny", method: "DELETE", url: "/v1/access/editor/denied-permissions/2", wantOperation: "grant-privileged-permission"},
+		{name: "Lift an unrelated access deny", method: "DELETE", url: "/v1/access/editor/denied-permissions/1"},
+		{name: "Leave a group with a deny", method: "DELETE", url: "/v1/access/editor/groups/4", wantOperation: "grant-privileged-permission"},
+		{name: "Temporary grant to a group", method: "POST", url: "/v1/temporary-grants", body: `{"group_id":2,"permission_id":2,"justification":"incident"}`, wantOperation: "grant-privileged-permission"},
+		{name: "Temporary grant to an access", method: "POST", url: "/v1/temporary-grants", body: `{"access_id":"editor","permission_id":3,"justification":"incident"}`, wantOperation: "grant-privileged-permission"},
+		{name: "Key for a more privileged access", actor: operator, method: "POST", url: "/v1/access/root/keys", body: `{"name":"ci"}`, wantOperation: "grant-privileged-permission"},
+		{name: "Key for a less privileged access", actor: operator, method: "POST", url: "/v1/access/editor/keys", body: `{"name":"ci"}`},
+		{name: "Rotate the key of a more privileged access", actor: operator, method: "POST", url: "/v1/access/root/rotate-key", wantOperation: "grant-privileged-permission"},
+		{name: "Rotate the key of a less privileged access", actor: operator, method: "POST", url: "/v1/access/editor/rotate-key"},
+		{name: "Key issued by an admin holding everything", method: "POST", url: "/v1/access/root/keys", body: `{"name":"ci"}`},
+		{name: "Reactivate a privileged access", method: "POST", url: "/v1/access/operator/reactivate", body: `{"reason":"back"}`, wantOperation: "grant-privileged-permission"},
+		{name: "Reactivate access", method: "POST", url: "/v1/access/editor/reactivate", body: `{"reason":"back"}`},
+		{name: "Force delete a denied permission", method: "DELETE", url: "/v1/permissions/2?force=true", wantOperation: "grant-privileged-permission"},
+		{name: "Delete a permission without force", method: "DELETE", url: "/v1/permissions/2"},
+		{name: "Force delete an unprivileged permission", method: "DELETE", url: "/v1/permissions/1?force=true"},
+		{name: "Temporary read grant", method: "POST", url: "/v1/temporary-grants", body: `{"access_id":"editor","permission_id":1,"justification":"incident"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := &stubChanges{}
+			gate := NewGate(rules, changes, stubPermissions{}, stubGroups{}, stubAccesses{}, stubAudit{}, time.Hour)
+			actor := tt.actor
+			if actor == nil {
+				actor = admin
+			}
+			app := gateApp(gate, actor)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+
+			if tt.wantOperation == "" {
+				if resp.StatusCode != fiber.StatusOK || len(changes.created) > 0 {
+					t.Fatalf("status = %d with %d pending changes, want the request applied", resp.StatusCode, len(changes.created))
+				}
+				return
+			}
+			if resp.StatusCode != fiber.StatusAccepted || len(changes.created) != 1 {
+				t.Fatalf("status = %d with %d pending changes, want a pending change", resp.StatusCode, len(changes.created))
+			}
+			if got := changes.created[0].Operation; got != tt.wantOperation {
+				t.Errorf("operation = %q, want %q", got, tt.wantOperation)
+			}
+		})
+	}
+}
+
+func TestGateRequiresRoutePermission(t *testing.T) {
+	rules, _ := LoadRules("../../../configs/approval-rules.example.json")
+	changes := &stubChanges{}
+	gate := NewGate(rules, changes, stubPermissions{}, stubGroups{}, stubAccesses{}, stubAudit{}, time.Hour)
+	app := gateApp(gate, &access.User{ID: "reader", DirectPermissions: []permission.Permission{examplesRead}})
+
+	req := httptest.NewRequest("POST", "/v1/access/editor/permissions", strings.NewReader(`{"permission_id":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden || len(changes.created) > 0 {
+		t.Errorf("status = %d with %d pending changes, want 403 without a pending change", resp.StatusCode, len(changes.created))
+	}
+}
+
+// gateApp registers the gated routes with a handler answering 200, authenticated as user
+func gateApp(gate *Gate, user *access.User) *fiber.App {
+	require := gate.Wrap(middleware.RequirePermission)
+	authenticate := func(c *fiber.Ctx) error {
+		c.Locals("user", user)
+		return c.Next()
+	}
+	applied := func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	app := fiber.New()
+	for _, route := range []struct{ method, path, resource string }{
+		{"POST", "/v1/groups", "groups"},
+		{"PATCH", "/v1/groups/:id", "groups"},
+		{"DELETE", "/v1/groups/:id", "groups"},
+		{"POST", "/v1/groups/:id/restore", "groups"},
+		{"PUT", "/v1/groups/:id/permissions", "groups"},
+		{"PUT", "/v1/groups/:id/denied-permissions", "groups"},
+		{"PUT", "/v1/groups/:id/parents", "groups"},
+		{"POST", "/v1/groups/:id/rollback/:version", "groups"},
+		{"POST", "/v1/access", "access"},
+		{"PUT", "/v1/access/:id/group", "access"},
+		{"POST", "/v1/access/:id/groups", "access"},
+		{"DELETE", "/v1/access/:id/groups/:groupId", "access"},
+		{"POST", "/v1/access/:id/permissions", "access"},
+		{"POST", "/v1/access/:id/keys", "access"},
+		{"POST", "/v1/access/:id/rotate-key", "access"},
+		{"POST", "/v1/access/:id/reactivate", "access"},
+		{"DELETE", "/v1/access/:id/denied-permissions/:permissionId", "access"},
+		{"POST", "/v1/temporary-grants", "access"},
+		{"DELETE", "/v1/permissions/:id", "permissions"},
+	} {
+		app.Add(route.method, route.path, authenticate, require(route.resource, "manage"), applied)
+	}
+	return app
+}
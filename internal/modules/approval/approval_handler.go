@@ -0,0 +1,273 @@
+package approval
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"apiserver/internal/middleware"
+	"apiserver/internal/modules/audit"
+	"apiserver/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type Handler struct {
+	repo      Repository
+	auditRepo audit.Repository
+	executor  *Executor
+}
+
+func NewHandler(repo Repository, auditRepo audit.Repository, executor *Executor) *Handler {
+	return &Handler{repo: repo, auditRepo: auditRepo, executor: executor}
+}
+
+// ListChanges godoc
+// SWAGGER_ACCESS_START
+// @Summary List pending changes
+// @Description List the changes proposed for approval, newest first
+// @Tags Approval
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param state query string false "Filter by state: pending, executed, failed, rejected or expired"
+// @Param operation query string false "Filter by operation"
+// @Param proposed_by query string false "Filter by the access that proposed the change"
+// @Param limit query int false "Limit results (default: 50, max: 1000)"
+// @Param offset query int false "Offset for pagination (default: 0)"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/approvals [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) ListChanges(c *fiber.Ctx) error {
+	h.expireChanges()
+
+	filter := ChangeFilter{
+		State:      c.Query("state"),
+		Operation:  c.Query("operation"),
+		ProposedBy: c.Query("proposed_by"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = l
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if o, err := strconv.Atoi(offset); err == nil {
+			filter.Offset = o
+		}
+	}
+
+	// Echo the page actually returned
+	filter.Paginate()
+
+	changes, total, err := h.repo.ListChanges(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch pending changes",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"changes": changes,
+			"total":   total,
+			"limit":   filter.Limit,
+			"offset":  filter.Offset,
+		},
+	})
+}
+
+// GetChange godoc
+// SWAGGER_ACCESS_START
+// @Summary Get pending change
+// @Description Get a change proposed for approval with its review and execution result
+// @Tags Approval
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Pending change ID"
+// @Success 200 {object} PendingChange
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /v1/approvals/{id} [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetChange(c *fiber.Ctx) error {
+	h.expireChanges()
+
+	change, err := h.repo.GetChange(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Pending change not found",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   change,
+	})
+}
+
+// ApproveChange godoc
+// SWAGGER_ACCESS_START
+// @Summary Approve a pending change
+// @Description Approve a change proposed by another admin and execute it as the access that proposed it. The reviewer must hold the permission the change requires.
+// @Tags Approval
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Pending change ID"
+// @Param data body ReviewRequest false "Review comment"
+// @Success 200 {object} PendingChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/approvals/{id}/approve [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) ApproveChange(c *fiber.Ctx) error {
+	change, reviewer, req, err := h.loadReview(c)
+	if err != nil {
+		return err
+	}
+
+	if reviewer.GetID() == change.ProposedBy {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "A change must be approved by an admin other than the one who proposed it",
+		})
+	}
+
+	// The reviewer must be allowed to make the change themselves
+	if ok, err := middleware.AuthorizePermission(c, change.Resource, change.Action); !ok {
+		return err
+	}
+
+	if err := h.review(change, StateApproved, reviewer.GetID(), req.Comment); err != nil {
+		return err
+	}
+	recordEvent(h.auditRepo, EventApprove, change, reviewer.GetID(), fiber.StatusOK)
+
+	statusCode, body, execErr := h.executor.Execute(change)
+	change.State, change.ResultStatus, change.ResultBody = StateExecuted, statusCode, body
+	if execErr != nil {
+		change.State, change.ResultStatus, change.ResultBody = StateFailed, fiber.StatusInternalServerError, execErr.Error()
+	} else if statusCode >= fiber.StatusBadRequest {
+		change.State = StateFailed
+	}
+	if err := h.repo.SaveResult(change.ID, change.State, change.ResultStatus, change.ResultBody); err != nil {
+		log.Printf("Failed to save the result of pending change %s: %v", change.ID, err)
+	}
+	recordEvent(h.auditRepo, EventExecute, change, change.ProposedBy, change.ResultStatus)
+
+	message := "Change approved and executed"
+	if change.State == StateFailed {
+		message = "Change approved but its execution failed"
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    change,
+	})
+}
+
+// RejectChange godoc
+// SWAGGER_ACCESS_START
+// @Summary Reject a pending change
+// @Description Reject a change proposed for approval so that it is never executed. The admin who proposed it can reject it to withdraw it.
+// @Tags Approval
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Pending change ID"
+// @Param data body ReviewRequest false "Review comment"
+// @Success 200 {object} PendingChange
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/approvals/{id}/reject [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) RejectChange(c *fiber.Ctx) error {
+	change, reviewer, req, err := h.loadReview(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.review(change, StateRejected, reviewer.GetID(), req.Comment); err != nil {
+		return err
+	}
+	recordEvent(h.auditRepo, EventReject, change, reviewer.GetID(), fiber.StatusOK)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Change rejected",
+		"data":    change,
+	})
+}
+
+// loadReview parses a review request and loads the change and the reviewer.
+// Failures are returned as a *fiber.Error.
+func (h *Handler) loadReview(c *fiber.Ctx) (*PendingChange, types.User, ReviewRequest, error) {
+	var req ReviewRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return nil, nil, req, fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+	}
+
+	reviewer, ok := c.Locals("user").(types.User)
+	if !ok {
+		return nil, nil, req, fiber.NewError(fiber.StatusUnauthorized, "User not authenticated")
+	}
+
+	change, err := h.repo.GetChange(c.Params("id"))
+	if err != nil {
+		return nil, nil, req, fiber.NewError(fiber.StatusNotFound, "Pending change not found")
+	}
+	return change, reviewer, req, nil
+}
+
+// review moves a pending change to state. Failures, such as a change that is
+// no longer pending, are returned as a *fiber.Error.
+func (h *Handler) review(change *PendingChange, state, reviewerID, comment string) error {
+	now := time.Now()
+	err := h.repo.Review(change.ID, state, reviewerID, comment, now)
+	if errors.Is(err, ErrNotPending) {
+		h.expireChanges()
+		current := change.State
+		if reloaded, err := h.repo.GetChange(change.ID); err == nil {
+			current = reloaded.State
+		}
+		return fiber.NewError(fiber.StatusConflict, "The change is "+current+" and can no longer be reviewed")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to review pending change")
+	}
+
+	change.State = state
+	change.ReviewedBy = &reviewerID
+	change.ReviewedAt = &now
+	change.ReviewComment = comment
+	return nil
+}
+
+// expireChanges marks the changes that were not reviewed in time as expired and audits them
+func (h *Handler) expireChanges() {
+	expired, err := h.repo.ExpireChanges(time.Now())
+	if err != nil {
+		log.Printf("Failed to expire pending changes: %v", err)
+		return
+	}
+	for i := range expired {
+		recordEvent(h.auditRepo, EventExpire, &expired[i], "", fiber.StatusOK)
+	}
+}
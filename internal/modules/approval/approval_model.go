@@ -0,0 +1,95 @@
+package approval
+
+import (
+	"errors"
+	"time"
+
+	"apiserver/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// States of a pending change
+const (
+	StatePending  = "pending"  // Waiting for a second admin
+	StateApproved = "approved" // Approved, being executed
+	StateExecuted = "executed" // Approved and executed successfully
+	StateFailed   = "failed"   // Approved but the execution failed
+	StateRejected = "rejected"
+	StateExpired  = "expired" // Not reviewed in time
+)
+
+// ErrNotPending is returned when a change has already been reviewed or has expired
+var ErrNotPending = errors.New("change is no longer pending")
+
+// PendingChange is a privileged request held until a second admin approves or rejects it.
+// On approval the request is executed as the access that proposed it.
+type PendingChange struct {
+	ID            string     `json:"id" gorm:"type:uuid;primaryKey"`
+	Operation     string     `json:"operation" gorm:"not null;index"` // Approval rule that matched
+	Method        string     `json:"method" gorm:"not null"`
+	Path          string     `json:"path" gorm:"not null"` // Request path with its query string
+	Route         string     `json:"route"`                // Route pattern, e.g. /v1/groups/:id/permissions
+	Body          string     `json:"body" gorm:"type:text"`
+	ContentType   string     `json:"content_type"`
+	Resource      string     `json:"resource"` // Permission required by the route
+	Action        string     `json:"action"`
+	State         string     `json:"state" gorm:"size:16;not null;index"`
+	ProposedBy    string     `json:"proposed_by" gorm:"type:uuid;not null;index"`
+	ReviewedBy    *string    `json:"reviewed_by" gorm:"type:uuid"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	ReviewComment string     `json:"review_comment" gorm:"type:text"`
+	ResultStatus  int        `json:"result_status,omitempty"` // Status code of the executed request
+	ResultBody    string     `json:"result_body,omitempty" gorm:"type:text"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null;index"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (PendingChange) TableName() string {
+	return "pending_changes"
+}
+
+// BeforeCreate hook to generate UUIDv7 before creating a new pending change
+func (p *PendingChange) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = utils.GenerateUUIDv7()
+	}
+	return nil
+}
+
+// ReviewRequest is the request body for approving or rejecting a change
+type ReviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ChangeFilter holds the filter options for listing pending changes
+type ChangeFilter struct {
+	State      string `json:"state"`
+	Operation  string `json:"operation"`
+	ProposedBy string `json:"proposed_by"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+}
+
+// Page sizes of ListChanges
+const (
+	defaultChangeListLimit = 50
+	maxChangeListLimit     = 1000
+)
+
+// Paginate applies the default page size when limit is unset, and clamps limit to
+// 1..1000 and offset to 0 or more
+func (f *ChangeFilter) Paginate() {
+	switch {
+	case f.Limit == 0:
+		f.Limit = defaultChangeListLimit
+	case f.Limit < 1:
+		f.Limit = 1
+	case f.Limit > maxChangeListLimit:
+		f.Limit = maxChangeListLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+}
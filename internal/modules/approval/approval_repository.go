@@ -0,0 +1,114 @@
+package approval
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	CreateChange(change *PendingChange) error
+	GetChange(id string) (*PendingChange, error)
+	ListChanges(filter ChangeFilter) ([]PendingChange, int64, error)
+	Review(id, state, reviewerID, comment string, now time.Time) error
+	SaveResult(id, state string, statusCode int, body string) error
+	ExpireChanges(now time.Time) ([]PendingChange, error)
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) CreateChange(change *PendingChange) error {
+	return r.db.Create(change).Error
+}
+
+func (r *repository) GetChange(id string) (*PendingChange, error) {
+	var change PendingChange
+	if err := r.db.Where("id = ?", id).First(&change).Error; err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// ListChanges lists pending changes, newest first, with the total matching the filter
+func (r *repository) ListChanges(filter ChangeFilter) ([]PendingChange, int64, error) {
+	query := r.db.Model(&PendingChange{})
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.Operation != "" {
+		query = query.Where("operation = ?", filter.Operation)
+	}
+	if filter.ProposedBy != "" {
+		query = query.Where("proposed_by = ?", filter.ProposedBy)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Apply pagination
+	filter.Paginate()
+
+	var changes []PendingChange
+	err := query.Order("created_at DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&changes).Error
+	return changes, total, err
+}
+
+// Review moves a change that is still pending and not expired to state. It fails with
+// ErrNotPending when another review got there first, so a change is only executed once.
+func (r *repository) Review(id, state, reviewerID, comment string, now time.Time) error {
+	result := r.db.Model(&PendingChange{}).
+		Where("id = ? AND state = ? AND expires_at > ?", id, StatePending, now).
+		Updates(map[string]interface{}{
+			"state":          state,
+			"reviewed_by":    reviewerID,
+			"reviewed_at":    now,
+			"review_comment": comment,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPending
+	}
+	return nil
+}
+
+// SaveResult records the outcome of executing an approved change
+func (r *repository) SaveResult(id, state string, statusCode int, body string) error {
+	return r.db.Model(&PendingChange{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"state":         state,
+		"result_status": statusCode,
+		"result_body":   body,
+	}).Error
+}
+
+// ExpireChanges marks the pending changes whose review window has passed as expired and returns them
+func (r *repository) ExpireChanges(now time.Time) ([]PendingChange, error) {
+	var expired []PendingChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state = ? AND expires_at <= ?", StatePending, now).Find(&expired).Error; err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+		ids := make([]string, len(expired))
+		for i := range expired {
+			ids[i] = expired[i].ID
+			expired[i].State = StateExpired
+		}
+		return tx.Model(&PendingChange{}).Where("id IN ? AND state = ?", ids, StatePending).Update("state", StateExpired).Error
+	})
+	return expired, err
+}
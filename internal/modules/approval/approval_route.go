@@ -0,0 +1,34 @@
+package approval
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterApprovalRoutes(app *fiber.App, handler *Handler, authMiddleware fiber.Handler, rateLimitMiddleware fiber.Handler, permissionMiddleware func(string, string) fiber.Handler) {
+	v1 := app.Group("/v1")
+
+	// Pending changes waiting for a second admin
+	v1.Get("/approvals",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("approvals", "read"),
+		handler.ListChanges)
+
+	v1.Get("/approvals/:id",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("approvals", "read"),
+		handler.GetChange)
+
+	v1.Post("/approvals/:id/approve",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("approvals", "approve"),
+		handler.ApproveChange)
+
+	v1.Post("/approvals/:id/reject",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("approvals", "approve"),
+		handler.RejectChange)
+}
@@ -0,0 +1,32 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+
+	"apiserver/internal/modules/access"
+)
+
+// grantSweeperAgent identifies audit entries written by the temporary grant sweep
+const grantSweeperAgent = "temporary-grant-sweeper"
+
+// RecordExpiredGrant returns a callback for access.StartGrantSweep that writes an audit entry for each
+// temporary grant removed on expiry, attributed to the access that held it when it was granted to one
+func RecordExpiredGrant(auditRepo Repository) func(access.TemporaryGrant) {
+	return func(grant access.TemporaryGrant) {
+		body, _ := json.Marshal(grant)
+		status := int16(0) // Active
+		entry := &AuditLog{
+			AccessID:    grant.AccessID,
+			Method:      "EXPIRE",
+			Path:        "/v1/temporary-grants/" + grant.ID,
+			StatusCode:  200,
+			RequestBody: string(body),
+			UserAgent:   grantSweeperAgent,
+			StatusID:    &status,
+		}
+		if err := auditRepo.CreateAuditLog(entry); err != nil {
+			log.Printf("Failed to audit expired temporary grant %s: %v", grant.ID, err)
+		}
+	}
+}
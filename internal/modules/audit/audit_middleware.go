@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"apiserver/internal/modules/access"
+	"apiserver/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -22,10 +23,13 @@ func NewAuditMiddleware(auditRepo Repository) fiber.Handler {
 
 		start := time.Now()
 
-		// Capture request body
+		// Capture request body, without the credentials it may carry
 		var requestBody string
 		if c.Body() != nil {
-			requestBody = string(c.Body())
+			requestBody = RedactSecrets(string(c.Body()), string(c.Request().Header.ContentType()))
+		}
+		if isSensitivePath(c.Path()) {
+			requestBody = "[redacted]"
 		}
 
 		// Capture request headers (excluding sensitive ones)
@@ -47,11 +51,12 @@ func NewAuditMiddleware(auditRepo Repository) fiber.Handler {
 
 		// Get user information if available
 		var accessID *string
-		var userEmail, apiKey string
+		var userEmail, apiKey, credential string
 		
 		if user, ok := c.Locals("user").(*access.User); ok {
 			accessID = &user.ID
 			userEmail = user.Email
+			credential = user.Credential
 		}
 		
 		// Try to get API key from Authorization header
@@ -64,17 +69,22 @@ func NewAuditMiddleware(auditRepo Repository) fiber.Handler {
 			}
 		}
 
-		// Get response body from Fiber context
-		responseBody := string(c.Response().Body())
+		// Get response body from Fiber context. API keys and signing secrets are only shown
+		// to the caller that created them, so they are redacted before the body is truncated.
+		responseBody := RedactSecrets(string(c.Response().Body()), string(c.Response().Header.ContentType()))
 		if len(responseBody) > 10000 { // Limit to 10KB
 			responseBody = responseBody[:10000] + "... [truncated]"
 		}
+		if isSensitivePath(c.Path()) {
+			responseBody = "[redacted]"
+		}
 
 		// Create audit log entry
 		auditLog := &AuditLog{
 			AccessID:       accessID,
 			UserEmail:      userEmail,
 			APIKey:         apiKey,
+			Credential:     credential,
 			Method:         c.Method(),
 			Path:           c.Path(),
 			StatusCode:     c.Response().StatusCode(),
@@ -82,7 +92,7 @@ func NewAuditMiddleware(auditRepo Repository) fiber.Handler {
 			RequestBody:    requestBody,
 			ResponseBody:   responseBody,
 			ResponseTime:   responseTime,
-			IPAddress:      c.IP(),
+			IPAddress:      utils.ClientIP(c),
 			UserAgent:      c.Get("User-Agent"),
 			StatusID:       func() *int16 { v := int16(0); return &v }(), // Active
 		}
@@ -100,6 +110,11 @@ func NewAuditMiddleware(auditRepo Repository) fiber.Handler {
 	}
 }
 
+// isSensitivePath checks if request and response bodies of a path carry credentials
+func isSensitivePath(path string) bool {
+	return strings.HasPrefix(path, "/oauth/")
+}
+
 // isSensitiveHeader checks if a header contains sensitive information
 func isSensitiveHeader(header string) bool {
 	sensitiveHeaders := []string{
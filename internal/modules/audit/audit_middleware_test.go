@@ -0,0 +1,112 @@
+// USAGE
+//   go test ./internal/modules/audit -v -run 'TestRedactSecrets|TestAuditMiddleware'
+
+package audit
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		expected    string
+	}{
+		{
+			name:     "Created access key",
+			body:     `{"status":"success","data":{"id":"a1","api_key":"sk_live_secret","rate_limit":120}}`,
+			expected: `{"data":{"api_key":"[redacted]","id":"a1","rate_limit":120},"status":"success"}`,
+		},
+		{
+			name:     "Signing secret",
+			body:     `{"data":{"key_id":"k1","signing_secret":"hmac-secret"}}`,
+			expected: `{"data":{"key_id":"k1","signing_secret":"[redacted]"}}`,
+		},
+		{
+			name:     "Keys in a list",
+			body:     `[{"API_KEY":"one"},{"name":"two"}]`,
+			expected: `[{"API_KEY":"[redacted]"},{"name":"two"}]`,
+		},
+		{
+			name:        "Introspected token in a form",
+			body:        "token=sk_live_secret&token_type_hint=api_key",
+			contentType: "application/x-www-form-urlencoded",
+			expected:    "token=%5Bredacted%5D&token_type_hint=api_key",
+		},
+		{
+			name:     "Prefix and other fields are kept",
+			body:     `{"api_key_prefix":"sk_live_ab","name":"ci"}`,
+			expected: `{"api_key_prefix":"sk_live_ab","name":"ci"}`,
+		},
+		{
+			name:     "Body that is not JSON",
+			body:     "plain text",
+			expected: "plain text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactSecrets(tt.body, tt.contentType); got != tt.expected {
+				t.Errorf("RedactSecrets() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+// recordingRepository keeps the audit logs written by the middleware
+type recordingRepository struct {
+	Repository
+	logs chan *AuditLog
+}
+
+func (r *recordingRepository) CreateAuditLog(log *AuditLog) error {
+	r.logs <- log
+	return nil
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	repo := &recordingRepository{logs: make(chan *AuditLog, 1)}
+	app := fiber.New()
+	app.Use(NewAuditMiddleware(repo))
+	for _, path := range []string{"/v1/access", "/v1/access/:id/rotate-key", "/v1/access/:id/keys", "/v1/access/:id/signing-secret"} {
+		app.Post(path, func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+				"status": "success",
+				"data":   fiber.Map{"api_key": "sk_live_plaintext", "signing_secret": "plaintext-secret"},
+			})
+		})
+	}
+
+	for _, path := range []string{"/v1/access", "/v1/access/a1/rotate-key", "/v1/access/a1/keys", "/v1/access/a1/signing-secret"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPost, path, strings.NewReader(`{"name":"ci"}`))
+			req.Header.Set("Content-Type", fiber.MIMEApplicationJSON)
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+
+			select {
+			case log := <-repo.logs:
+				if strings.Contains(log.ResponseBody, "plaintext") {
+					t.Errorf("response body stored with a credential: %s", log.ResponseBody)
+				}
+				if !strings.Contains(log.ResponseBody, redactedValue) {
+					t.Errorf("response body = %s, want redacted fields", log.ResponseBody)
+				}
+				if log.RequestBody != `{"name":"ci"}` {
+					t.Errorf("request body = %s, want it unchanged", log.RequestBody)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no audit log written")
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ type AuditLog struct {
 	AccessID       *string   `json:"access_id" gorm:"type:uuid;index"`
 	UserEmail      string    `json:"user_email" gorm:"index"`
 	APIKey         string    `json:"api_key" gorm:"index"`
+	Credential     string    `json:"credential" gorm:"size:64"` // Which key authenticated the request (e.g. primary, previous, key:ci)
 	Method         string    `json:"method" gorm:"not null"`
 	Path           string    `json:"path" gorm:"not null;index"`
 	StatusCode     int       `json:"status_code" gorm:"not null;index"`
@@ -29,6 +30,7 @@ type AuditLog struct {
 type AuditLogResponse struct {
 	ID           string    `json:"id"`
 	UserEmail    string    `json:"user_email"`
+	Credential   string    `json:"credential"`
 	Method       string    `json:"method"`
 	Path         string    `json:"path"`
 	StatusCode   int       `json:"status_code"`
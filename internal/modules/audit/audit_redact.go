@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces credentials in stored bodies
+const redactedValue = "[redacted]"
+
+// sensitiveFields are body fields holding a plaintext credential: API keys returned when an access
+// or key is created or rotated, signing secrets, and tokens sent for introspection
+var sensitiveFields = map[string]bool{
+	"api_key":        true,
+	"signing_secret": true,
+	"token":          true,
+	"access_token":   true,
+	"client_secret":  true,
+}
+
+// RedactSecrets replaces the values of sensitive fields in a JSON or form-encoded body,
+// at any depth. Other bodies are returned unchanged.
+func RedactSecrets(body, contentType string) string {
+	if body == "" {
+		return body
+	}
+
+	trimmed := bytes.TrimSpace([]byte(body))
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return body
+		}
+		if !redactValue(value) {
+			return body
+		}
+		redacted, err := json.Marshal(value)
+		if err != nil {
+			return redactedValue
+		}
+		return string(redacted)
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return redactedValue
+		}
+		changed := false
+		for key := range values {
+			if sensitiveFields[strings.ToLower(key)] {
+				values[key] = []string{redactedValue}
+				changed = true
+			}
+		}
+		if changed {
+			return values.Encode()
+		}
+	}
+	return body
+}
+
+// redactValue redacts sensitive fields of decoded JSON in place and reports whether it changed anything
+func redactValue(value interface{}) bool {
+	changed := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if _, isString := field.(string); isString && sensitiveFields[strings.ToLower(key)] {
+				v[key] = redactedValue
+				changed = true
+				continue
+			}
+			if redactValue(field) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if redactValue(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
@@ -72,7 +72,7 @@ func (r *repository) GetAuditLogs(filter AuditLogFilter) ([]AuditLogResponse, in
 		filter.Limit = 1000 // max limit
 	}
 
-	err := query.Select("id, user_email, method, path, status_code, response_time, ip_address, created_at").
+	err := query.Select("id, user_email, credential, method, path, status_code, response_time, ip_address, created_at").
 		Order("created_at DESC").
 		Limit(filter.Limit).
 		Offset(filter.Offset).
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// ForwardRule maps requests to a protected upstream service onto a resource/action permission
+type ForwardRule struct {
+	Host     string   `json:"host"`     // Optional X-Forwarded-Host to match, empty matches any host
+	Methods  []string `json:"methods"`  // Optional HTTP methods, empty matches any method
+	Path     string   `json:"path"`     // Exact path, or a prefix when it ends with "*"
+	Resource string   `json:"resource"` // Permission resource required
+	Action   string   `json:"action"`   // Permission action required, derived from the method when empty
+}
+
+// LoadForwardRules reads forward-auth rules from a JSON file. A missing file yields no rules,
+// in which case every forward-auth request is denied.
+func LoadForwardRules(path string) ([]ForwardRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []ForwardRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid forward-auth rules in %s: %w", path, err)
+	}
+	for i, rule := range rules {
+		if rule.Path == "" || rule.Resource == "" {
+			return nil, fmt.Errorf("forward-auth rule %d in %s needs a path and a resource", i, path)
+		}
+	}
+	return rules, nil
+}
+
+// matchForwardRule returns the permission required by the first rule matching a request
+func matchForwardRule(rules []ForwardRule, method, host, uri string) (string, string, bool) {
+	requestPath, ok := forwardPath(uri)
+	if !ok {
+		return "", "", false
+	}
+	method = strings.ToUpper(method)
+
+	for _, rule := range rules {
+		if rule.Host != "" && !strings.EqualFold(rule.Host, host) {
+			continue
+		}
+		if len(rule.Methods) > 0 && !containsFold(rule.Methods, method) {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(rule.Path, "*"); ok {
+			if !strings.HasPrefix(requestPath, prefix) {
+				continue
+			}
+		} else if requestPath != rule.Path {
+			continue
+		}
+
+		action := rule.Action
+		if action == "" {
+			action = actionForMethod(method)
+		}
+		return rule.Resource, action, true
+	}
+	return "", "", false
+}
+
+// forwardPath returns the path of a forwarded URI as the upstream resolves it: percent-decoded,
+// with dot segments and repeated slashes removed, so that "/public/%2e%2e/admin" is matched as
+// "/admin". Paths that still hold ".." or another level of encoding are rejected.
+func forwardPath(uri string) (string, bool) {
+	raw, _, _ := strings.Cut(uri, "?")
+	decoded, err := url.PathUnescape(raw)
+	if err != nil {
+		return "", false
+	}
+	if again, err := url.PathUnescape(decoded); err != nil || again != decoded {
+		return "", false
+	}
+
+	cleaned := path.Clean("/" + decoded)
+	if strings.Contains(cleaned, "..") {
+		return "", false
+	}
+	// Clean drops the trailing slash that "/prefix/*" rules expect
+	if strings.HasSuffix(decoded, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned, true
+}
+
+// actionForMethod derives the CRUD action of an HTTP method
+func actionForMethod(method string) string {
+	switch method {
+	case "POST":
+		return "create"
+	case "PUT", "PATCH":
+		return "update"
+	case "DELETE":
+		return "delete"
+	default:
+		return "read"
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// USAGE
+//   go test ./internal/modules/auth -v -run TestMatchForwardRule
+
+package auth
+
+import "testing"
+
+func TestMatchForwardRule(t *testing.T) {
+	rules := []ForwardRule{
+		{Path: "/public/*", Resource: "public", Action: "read"},
+		{Methods: []string{"GET"}, Path: "/reports", Resource: "reports"},
+		{Host: "admin.example.com", Path: "/admin/*", Resource: "admin", Action: "manage"},
+		{Path: "/*", Resource: "app"},
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		host         string
+		uri          string
+		wantResource string
+		wantAction   string
+		wantOK       bool
+	}{
+		{name: "Prefix rule", method: "GET", uri: "/public/docs?page=2", wantResource: "public", wantAction: "read", wantOK: true},
+		{name: "Prefix with trailing slash", method: "GET", uri: "/public/", wantResource: "public", wantAction: "read", wantOK: true},
+		{name: "Exact rule with derived action", method: "get", uri: "/reports", wantResource: "reports", wantAction: "read", wantOK: true},
+		{name: "Method outside the rule", method: "DELETE", uri: "/reports", wantResource: "app", wantAction: "delete", wantOK: true},
+		{name: "Host rule", method: "POST", host: "ADMIN.example.com", uri: "/admin/users", wantResource: "admin", wantAction: "manage", wantOK: true},
+		{name: "Host rule on another host", method: "POST", host: "www.example.com", uri: "/admin/users", wantResource: "app", wantAction: "create", wantOK: true},
+		{name: "Dot segments leave the public prefix", method: "GET", uri: "/public/../reports", wantResource: "reports", wantAction: "read", wantOK: true},
+		{name: "Encoded dot segments leave the public prefix", method: "DELETE", uri: "/public/%2e%2e/reports", wantResource: "app", wantAction: "delete", wantOK: true},
+		{name: "Encoded slash", method: "GET", uri: "/public%2F..%2Freports", wantResource: "reports", wantAction: "read", wantOK: true},
+		{name: "Repeated slashes", method: "GET", uri: "//reports", wantResource: "reports", wantAction: "read", wantOK: true},
+		{name: "Dot segments above the root", method: "GET", uri: "/../../reports", wantResource: "reports", wantAction: "read", wantOK: true},
+		{name: "Double encoding", method: "GET", uri: "/public/%252e%252e/reports", wantOK: false},
+		{name: "Dots inside a segment", method: "GET", uri: "/public/a..b", wantOK: false},
+		{name: "Invalid encoding", method: "GET", uri: "/public/%zz", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, action, ok := matchForwardRule(rules, tt.method, tt.host, tt.uri)
+			if ok != tt.wantOK || resource != tt.wantResource || action != tt.wantAction {
+				t.Errorf("matchForwardRule(%s %s) = %q, %q, %v, want %q, %q, %v",
+					tt.method, tt.uri, resource, action, ok, tt.wantResource, tt.wantAction, tt.wantOK)
+			}
+		})
+	}
+}
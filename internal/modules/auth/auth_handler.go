@@ -0,0 +1,504 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"apiserver/internal/cache"
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
+	"apiserver/internal/utils"
+
+	"github.com/go-playground/validator"
+	"github.com/gofiber/fiber/v2"
+)
+
+// grantTypeClientCredentials is the only OAuth grant supported
+const grantTypeClientCredentials = "client_credentials"
+
+// RateLimitBudgetProvider reports the unused rate limit budget of a credential
+type RateLimitBudgetProvider interface {
+	Remaining(key string, limit int) (int, time.Time)
+}
+
+type Handler struct {
+	authRepo       types.AuthRepository
+	tokens         *TokenIssuer
+	principalCache cache.StatsProvider // nil when caching is disabled
+	rateLimits     RateLimitBudgetProvider
+	forwardRules   []ForwardRule
+	validator      *validator.Validate
+}
+
+func NewHandler(authRepo types.AuthRepository, tokens *TokenIssuer, principalCache cache.StatsProvider, rateLimits RateLimitBudgetProvider, forwardRules []ForwardRule) *Handler {
+	return &Handler{
+		authRepo:       authRepo,
+		tokens:         tokens,
+		principalCache: principalCache,
+		rateLimits:     rateLimits,
+		forwardRules:   forwardRules,
+		validator:      validator.New(),
+	}
+}
+
+// Token godoc
+// SWAGGER_ACCESS_START
+// @Summary OAuth2 token endpoint
+// @Description Exchange an access ID (client_id) and API key (client_secret) for a short-lived JWT using the client_credentials grant. Credentials may also be sent with HTTP Basic authentication.
+// @Tags OAuth
+// @Accept x-www-form-urlencoded
+// @Accept json
+// @Produce json
+// @Param data body TokenRequest true "Token request"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /oauth/token [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) Token(c *fiber.Ctx) error {
+	// Token responses must never be cached
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	// Parse request body
+	var req TokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "Invalid request body")
+	}
+
+	// Client credentials may also be sent with HTTP Basic authentication
+	if clientID, clientSecret, ok := basicAuth(c.Get(fiber.HeaderAuthorization)); ok {
+		req.ClientID, req.ClientSecret = clientID, clientSecret
+	}
+
+	if req.GrantType != grantTypeClientCredentials {
+		return oauthError(c, fiber.StatusBadRequest, "unsupported_grant_type", "Only the client_credentials grant is supported")
+	}
+	if req.ClientID == "" || req.ClientSecret == "" {
+		return oauthError(c, fiber.StatusUnauthorized, "invalid_client", "Client credentials are required")
+	}
+
+	// Authenticate the client with the same rules as bearer API keys
+	authUser, err := h.authRepo.FindByAPIKey(req.ClientSecret)
+	if err != nil || authUser.GetID() != req.ClientID {
+		description := "Invalid client credentials"
+		if err != nil && !errors.Is(err, types.ErrInvalidAPIKey) {
+			description = "Client cannot authenticate: " + err.Error()
+		}
+		return oauthError(c, fiber.StatusUnauthorized, "invalid_client", description)
+	}
+	if !authUser.IsIPAllowed(utils.ClientIP(c)) {
+		return oauthError(c, fiber.StatusUnauthorized, "invalid_client", "IP address not allowed")
+	}
+
+	user, ok := authUser.(*access.User)
+	if !ok {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "Unsupported client")
+	}
+
+	// The token carries the permissions of the access, narrowed by key and requested scopes
+	permissions := grantedPermissions(user)
+	if req.Scope != "" {
+		if permissions, err = narrowScope(permissions, strings.Fields(req.Scope)); err != nil {
+			return oauthError(c, fiber.StatusBadRequest, "invalid_scope", err.Error())
+		}
+	}
+
+	token, expiresAt, err := h.tokens.Issue(user, permissions)
+	if err != nil {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "Failed to issue access token")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(time.Until(expiresAt).Seconds()),
+		Scope:       strings.Join(permissions, " "),
+	})
+}
+
+// JWKS godoc
+// SWAGGER_ACCESS_START
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens issued by /oauth/token
+// @Tags OAuth
+// @Produce json
+// @Success 200 {object} JWKS
+// @Router /.well-known/jwks.json [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(h.tokens.JWKS())
+}
+
+// ListSigningKeys godoc
+// SWAGGER_ACCESS_START
+// @Summary List token signing keys
+// @Description List the OAuth token signing keys with their rotation state
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} SigningKey
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/oauth/keys [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) ListSigningKeys(c *fiber.Ctx) error {
+	keys, err := h.tokens.SigningKeys()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch signing keys",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   keys,
+	})
+}
+
+// RotateSigningKey godoc
+// SWAGGER_ACCESS_START
+// @Summary Rotate token signing key
+// @Description Create a new signing key. Tokens signed with older keys stay valid until they expire.
+// @Tags OAuth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 201 {object} SigningKey
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/oauth/keys/rotate [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) RotateSigningKey(c *fiber.Ctx) error {
+	key, err := h.tokens.Rotate()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to rotate signing key",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Signing key rotated successfully",
+		"data":    key,
+	})
+}
+
+// Introspect godoc
+// SWAGGER_ACCESS_START
+// @Summary Introspect an API key or access token
+// @Description Check a credential presented to another service (RFC 7662 style). Applies the same status and expiry rules as the auth middleware; IP allowlists are not checked because the original client address is unknown.
+// @Tags Auth
+// @Accept x-www-form-urlencoded
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param data body IntrospectRequest true "Credential to introspect"
+// @Success 200 {object} IntrospectResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /v1/auth/introspect [post]
+// SWAGGER_ACCESS_END
+func (h *Handler) Introspect(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	// Parse request body
+	var req IntrospectRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	// Validate request
+	if err := h.validator.Struct(&req); err != nil {
+		return utils.HandleError(c, err)
+	}
+
+	// Resolve the credential exactly like the auth middleware does
+	tokenType := "api_key"
+	rateLimitKey := req.Token
+	var authUser types.User
+	var err error
+	if utils.LooksLikeJWT(req.Token) {
+		tokenType = "access_token"
+		authUser, err = h.tokens.VerifyToken(req.Token)
+		if err == nil {
+			rateLimitKey = "jwt:" + authUser.GetID()
+		}
+	} else {
+		authUser, err = h.authRepo.FindByAPIKey(req.Token)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusOK).JSON(IntrospectResponse{Active: false})
+	}
+
+	user, ok := authUser.(*access.User)
+	if !ok {
+		return c.Status(fiber.StatusOK).JSON(IntrospectResponse{Active: false})
+	}
+
+	permissions := grantedPermissions(user)
+	response := IntrospectResponse{
+		Active:      true,
+		TokenType:   tokenType,
+		AccessID:    user.ID,
+		Subject:     user.ID,
+		Name:        user.Name,
+		Email:       user.Email,
+		Credential:  user.Credential,
+		Permissions: permissions,
+		Denied:      permissionKeys(user.GetDeniedPermissions()),
+		Scope:       strings.Join(permissions, " "),
+		ExpiresAt:   credentialExpiry(user),
+	}
+	if user.Group != nil {
+		response.Group = &IntrospectGroup{ID: user.Group.ID, Name: user.Group.Name}
+	}
+	for _, g := range user.GetGroups() {
+		response.Groups = append(response.Groups, IntrospectGroup{ID: g.ID, Name: g.Name})
+	}
+	if response.ExpiresAt != nil {
+		response.Exp = response.ExpiresAt.Unix()
+	}
+
+	limit := user.GetRateLimit()
+	remaining, reset := h.rateLimits.Remaining(rateLimitKey, limit)
+	response.RateLimit = &RateLimitBudget{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     reset.Unix(),
+	}
+
+	return c.Status(fiber.StatusOK).JSON(response)
+}
+
+// ResolveForward godoc
+// SWAGGER_ACCESS_START
+// @Summary Forward authentication
+// @Description Authorize a request to an upstream service for nginx auth_request or Traefik ForwardAuth. The original request is read from X-Forwarded-Method/X-Forwarded-Uri/X-Forwarded-Host (or nginx X-Original-Method/X-Original-URI) and mapped to a permission by the configured rules. On success the identity is returned in X-Access-Id, X-Access-Email, X-Access-Group (primary group) and X-Access-Groups (every group, comma separated).
+// @Tags Auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /v1/auth/forward [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) ResolveForward(c *fiber.Ctx) error {
+	method := firstHeader(c, "X-Forwarded-Method", "X-Original-Method")
+	if method == "" {
+		method = fiber.MethodGet
+	}
+	uri := firstHeader(c, "X-Forwarded-Uri", "X-Original-URI", "X-Original-Url")
+	if uri == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Access denied: Original request URI is missing",
+		})
+	}
+
+	resource, action, ok := matchForwardRule(h.forwardRules, method, c.Get("X-Forwarded-Host"), uri)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Access denied: No forward-auth rule matches this request",
+		})
+	}
+
+	c.Locals("forward_resource", resource)
+	c.Locals("forward_action", action)
+	return c.Next()
+}
+
+// ForwardAllowed returns the identity headers once the forwarded request has been authorized
+func (h *Handler) ForwardAllowed(c *fiber.Ctx) error {
+	user, ok := c.Locals("user").(types.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "User not authenticated",
+		})
+	}
+
+	c.Set("X-Access-Id", user.GetID())
+	c.Set("X-Access-Email", user.GetEmail())
+	if userGroup := user.GetGroup(); userGroup != nil {
+		c.Set("X-Access-Group", userGroup.Name)
+	}
+	if groups := user.GetGroups(); len(groups) > 0 {
+		names := make([]string, len(groups))
+		for i, g := range groups {
+			names[i] = g.Name
+		}
+		c.Set("X-Access-Groups", strings.Join(names, ","))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Access granted",
+	})
+}
+
+// GetCacheStats godoc
+// SWAGGER_ACCESS_START
+// @Summary Get principal cache statistics
+// @Description Hit, miss and eviction counters of the authenticated principal cache
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /v1/auth/cache [get]
+// SWAGGER_ACCESS_END
+func (h *Handler) GetCacheStats(c *fiber.Ctx) error {
+	if h.principalCache == nil {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"status": "success",
+			"data":   fiber.Map{"enabled": false},
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data": fiber.Map{
+			"enabled": true,
+			"stats":   h.principalCache.Stats(),
+		},
+	})
+}
+
+// oauthError writes an error response in the format defined by RFC 6749
+func oauthError(c *fiber.Ctx, status int, code, description string) error {
+	if code == "invalid_client" {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// basicAuth decodes client credentials sent with HTTP Basic authentication
+func basicAuth(header string) (string, string, bool) {
+	if !strings.HasPrefix(header, "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", "", false
+	}
+	clientID, clientSecret, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", false
+	}
+
+	// RFC 6749 form-encodes both values before joining them
+	if unescaped, err := url.QueryUnescape(clientID); err == nil {
+		clientID = unescaped
+	}
+	if unescaped, err := url.QueryUnescape(clientSecret); err == nil {
+		clientSecret = unescaped
+	}
+	return clientID, clientSecret, true
+}
+
+// firstHeader returns the first non-empty value among the given request headers
+func firstHeader(c *fiber.Ctx, names ...string) string {
+	for _, name := range names {
+		if value := c.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// credentialExpiry returns when a credential stops working: the earliest of the access expiry,
+// the named key expiry and the end of a rotation grace period
+func credentialExpiry(user *access.User) *time.Time {
+	expiry := user.ExpiredDate
+	earliest := func(t *time.Time) {
+		if t != nil && (expiry == nil || t.Before(*expiry)) {
+			expiry = t
+		}
+	}
+	if user.ActiveKey != nil {
+		earliest(user.ActiveKey.ExpiredDate)
+	}
+	if user.Credential == access.CredentialPrevious {
+		earliest(user.PreviousAPIKeyExpiresAt)
+	}
+	return expiry
+}
+
+// grantedPermissions lists the "resource:action" pairs of an access's effective permissions, narrowed by the scopes of the key used.
+// When a scope and a grant overlap through a wildcard, the narrower of the two is kept.
+// Pairs removed entirely by a deny rule are left out; partial denies travel with the token.
+func grantedPermissions(user *access.User) []string {
+	scopes := user.GetScopes()
+	denies := user.GetDeniedPermissions()
+	seen := make(map[string]bool)
+	permissions := []string{}
+	add := func(pair string) {
+		resource, action, _ := permission.SplitKey(pair)
+		if permission.Allows(denies, resource, action) {
+			return
+		}
+		if !seen[pair] {
+			seen[pair] = true
+			permissions = append(permissions, pair)
+		}
+	}
+
+	for _, p := range user.GetPermissions() {
+		pair := p.Resource + ":" + p.Action
+		if len(scopes) == 0 {
+			add(pair)
+			continue
+		}
+		for _, scope := range scopes {
+			if permission.KeyMatches(scope, p.Resource, p.Action) {
+				add(pair)
+			} else if resource, action, ok := permission.SplitKey(scope); ok && p.Grants(resource, action) {
+				add(scope)
+			}
+		}
+	}
+	return permissions
+}
+
+// narrowScope keeps only the requested permissions, failing if any of them is not granted
+func narrowScope(granted, requested []string) ([]string, error) {
+	narrowed := []string{}
+	for _, p := range requested {
+		resource, action, ok := permission.SplitKey(p)
+		if !ok || !anyKeyMatches(granted, resource, action) {
+			return nil, errors.New("scope not granted: " + p)
+		}
+		narrowed = append(narrowed, p)
+	}
+	return narrowed, nil
+}
+
+// anyKeyMatches reports whether one of the "resource:action" keys covers the given pair
+func anyKeyMatches(keys []string, resource, action string) bool {
+	for _, key := range keys {
+		if permission.KeyMatches(key, resource, action) {
+			return true
+		}
+	}
+	return false
+}
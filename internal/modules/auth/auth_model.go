@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"time"
+
+	"apiserver/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// SigningKey is an Ed25519 key pair used to sign OAuth access tokens
+type SigningKey struct {
+	ID         string     `json:"kid" gorm:"type:uuid;primaryKey"`
+	Algorithm  string     `json:"alg" gorm:"size:16;not null"`
+	PublicKey  string     `json:"public_key" gorm:"type:text;not null"` // Base64url encoded
+	PrivateKey string     `json:"-" gorm:"type:text;not null"`          // Base64url seed, AES-GCM encrypted when Encrypted is set
+	Encrypted  bool       `json:"encrypted" gorm:"not null;default:false"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RetiredAt  *time.Time `json:"retired_at"` // No longer signs new tokens
+	ExpiresAt  *time.Time `json:"expires_at"` // Dropped from the JWKS once every token it signed has expired
+}
+
+func (SigningKey) TableName() string {
+	return "oauth_signing_keys"
+}
+
+// BeforeCreate hook to generate UUIDv7 before creating a new signing key
+func (k *SigningKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = utils.GenerateUUIDv7()
+	}
+	return nil
+}
+
+// TokenClaims are the claims carried by an OAuth access token
+type TokenClaims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"` // Access ID
+	IssuedAt        int64    `json:"iat"`
+	ExpiresAt       int64    `json:"exp"`
+	ID              string   `json:"jti"`
+	Name            string   `json:"name"`
+	Email           string   `json:"email"`
+	GroupID         *uint    `json:"group_id,omitempty"`
+	Group           string   `json:"group,omitempty"`
+	Groups          []string `json:"groups,omitempty"` // Names of the additional groups
+	Permissions     []string `json:"permissions"`      // Effective "resource:action" pairs
+	Denied          []string `json:"denied,omitempty"` // Deny rules overriding Permissions
+	RateLimit       int      `json:"rate_limit"`
+	AllowedCIDRs    []string `json:"allowed_cidrs,omitempty"`
+	KeyAllowedCIDRs []string `json:"key_allowed_cidrs,omitempty"` // Allowlist of the named key used to obtain the token
+}
+
+// TokenRequest is the body of an OAuth token request (form or JSON encoded)
+type TokenRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	ClientID     string `json:"client_id" form:"client_id"`         // Access ID
+	ClientSecret string `json:"client_secret" form:"client_secret"` // API key
+	Scope        string `json:"scope" form:"scope"`                 // Optional space separated "resource:action" pairs
+}
+
+// TokenResponse is a successful OAuth token response
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// JWK is an Ed25519 public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWKS is the JSON Web Key Set published for token verification
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// IntrospectRequest is the body of a token introspection request (form or JSON encoded)
+type IntrospectRequest struct {
+	Token         string `json:"token" form:"token" validate:"required"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"` // Optional: "api_key" or "access_token"
+}
+
+// IntrospectResponse describes a presented credential in the style of RFC 7662.
+// Only Active is set when the credential cannot be used.
+type IntrospectResponse struct {
+	Active      bool              `json:"active"`
+	TokenType   string            `json:"token_type,omitempty"` // "api_key" or "access_token"
+	AccessID    string            `json:"access_id,omitempty"`
+	Subject     string            `json:"sub,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Email       string            `json:"email,omitempty"`
+	Credential  string            `json:"credential,omitempty"`
+	Group       *IntrospectGroup  `json:"group,omitempty"`  // Primary group
+	Groups      []IntrospectGroup `json:"groups,omitempty"` // Every group, primary group first
+	Permissions []string          `json:"permissions,omitempty"`
+	Denied      []string          `json:"denied_permissions,omitempty"` // Deny rules overriding Permissions
+	Scope       string            `json:"scope,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Exp         int64             `json:"exp,omitempty"`
+	RateLimit   *RateLimitBudget  `json:"rate_limit,omitempty"`
+}
+
+// IntrospectGroup is the group of an introspected access
+type IntrospectGroup struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// RateLimitBudget is the rate limit state of a credential in the current window
+type RateLimitBudget struct {
+	Limit     int   `json:"limit"` // Requests per minute
+	Remaining int   `json:"remaining"`
+	Reset     int64 `json:"reset"` // Unix time when the budget grows again
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	CreateSigningKey(key *SigningKey) error
+	GetSigningKeys() ([]SigningKey, error)
+	GetVerificationKeys(now time.Time) ([]SigningKey, error)
+	RetireSigningKeys(exceptID string, retiredAt, expiresAt time.Time) error
+}
+
+type repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) CreateSigningKey(key *SigningKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *repository) GetSigningKeys() ([]SigningKey, error) {
+	var keys []SigningKey
+	err := r.db.Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// GetVerificationKeys returns the keys whose tokens may still be valid, newest first
+func (r *repository) GetVerificationKeys(now time.Time) ([]SigningKey, error) {
+	var keys []SigningKey
+	err := r.db.Where("expires_at IS NULL OR expires_at > ?", now).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// RetireSigningKeys stops every active key except the given one from signing new tokens
+func (r *repository) RetireSigningKeys(exceptID string, retiredAt, expiresAt time.Time) error {
+	return r.db.Model(&SigningKey{}).
+		Where("id <> ? AND retired_at IS NULL", exceptID).
+		Updates(map[string]interface{}{"retired_at": retiredAt, "expires_at": expiresAt}).Error
+}
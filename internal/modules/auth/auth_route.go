@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterAuthRoutes(app *fiber.App, handler *Handler, authMiddleware fiber.Handler, rateLimitMiddleware fiber.Handler, permissionMiddleware func(string, string) fiber.Handler) {
+	// Public OAuth2 routes; the token endpoint authenticates clients itself
+	app.Post("/oauth/token", handler.Token)
+	app.Get("/.well-known/jwks.json", handler.JWKS)
+
+	v1 := app.Group("/v1")
+
+	// Token introspection for sibling services
+	v1.Post("/auth/introspect",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("auth", "introspect"),
+		handler.Introspect)
+
+	// Forward authentication for reverse proxies. The permission is chosen per request
+	// from the forward-auth rules and checked with the regular permission middleware.
+	v1.Get("/auth/forward",
+		authMiddleware,
+		rateLimitMiddleware,
+		handler.ResolveForward,
+		func(c *fiber.Ctx) error {
+			resource, _ := c.Locals("forward_resource").(string)
+			action, _ := c.Locals("forward_action").(string)
+			return permissionMiddleware(resource, action)(c)
+		},
+		handler.ForwardAllowed)
+
+	// Principal cache monitoring
+	v1.Get("/auth/cache",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.GetCacheStats)
+
+	// Signing key management routes
+	v1.Get("/oauth/keys",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.ListSigningKeys)
+
+	v1.Post("/oauth/keys/rotate",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("access", "manage"),
+		handler.RotateSigningKey)
+}
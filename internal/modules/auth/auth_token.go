@@ -0,0 +1,374 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"apiserver/internal/modules/access"
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+	"apiserver/internal/types"
+	"apiserver/internal/utils"
+)
+
+// errUnknownSigningKey is returned when a token names a key that is not in the key set
+var errUnknownSigningKey = errors.New("unknown signing key")
+
+// minReloadInterval limits database reloads triggered by tokens with unknown key IDs
+const minReloadInterval = time.Minute
+
+// loadedKey is a signing key with its decoded key material
+type loadedKey struct {
+	SigningKey
+	public  ed25519.PublicKey
+	private ed25519.PrivateKey
+}
+
+// TokenIssuer signs and verifies OAuth access tokens and rotates the signing keys
+type TokenIssuer struct {
+	sync.RWMutex
+	repo     Repository
+	issuer   string
+	ttl      time.Duration // Lifetime of an access token
+	rotation time.Duration // Age after which a new signing key is created
+	keys     map[string]*loadedKey
+	current  *loadedKey
+	loadedAt time.Time
+}
+
+// NewTokenIssuer creates a token issuer; call Init before use
+func NewTokenIssuer(repo Repository, issuer string, ttl, rotation time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		repo:     repo,
+		issuer:   issuer,
+		ttl:      ttl,
+		rotation: rotation,
+		keys:     make(map[string]*loadedKey),
+	}
+}
+
+// Init loads the signing keys and creates the first one if needed
+func (t *TokenIssuer) Init() error {
+	if err := t.reload(); err != nil {
+		return err
+	}
+	return t.rotateIfDue()
+}
+
+// StartRotation periodically picks up keys rotated by other instances and rotates the current key when it is due
+func (t *TokenIssuer) StartRotation(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := t.reload(); err != nil {
+				log.Printf("Failed to reload signing keys: %v", err)
+				continue
+			}
+			if err := t.rotateIfDue(); err != nil {
+				log.Printf("Failed to rotate signing key: %v", err)
+			}
+		}
+	}()
+}
+
+// TTL returns the lifetime of issued access tokens
+func (t *TokenIssuer) TTL() time.Duration {
+	return t.ttl
+}
+
+// SigningKeys returns every stored signing key, newest first
+func (t *TokenIssuer) SigningKeys() ([]SigningKey, error) {
+	return t.repo.GetSigningKeys()
+}
+
+// Rotate creates a new signing key. Older keys stop signing immediately but stay
+// in the JWKS until the tokens they signed have expired.
+func (t *TokenIssuer) Rotate() (*SigningKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := base64.RawURLEncoding.EncodeToString(private.Seed())
+	key := &SigningKey{
+		Algorithm:  utils.JWTAlgorithm,
+		PublicKey:  base64.RawURLEncoding.EncodeToString(public),
+		PrivateKey: seed,
+	}
+	if utils.SecretEncryptionEnabled() {
+		if key.PrivateKey, err = utils.EncryptSecret(seed); err != nil {
+			return nil, err
+		}
+		key.Encrypted = true
+	} else {
+		log.Println("Warning: SIGNING_SECRET_KEY is not set, storing the token signing key unencrypted")
+	}
+
+	if err := t.repo.CreateSigningKey(key); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := t.repo.RetireSigningKeys(key.ID, now, now.Add(t.ttl)); err != nil {
+		return nil, err
+	}
+
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// rotateIfDue creates a new key when there is none or the current one is older than the rotation period
+func (t *TokenIssuer) rotateIfDue() error {
+	t.RLock()
+	current := t.current
+	t.RUnlock()
+
+	if current != nil && time.Since(current.CreatedAt) < t.rotation {
+		return nil
+	}
+	key, err := t.Rotate()
+	if err != nil {
+		return err
+	}
+	log.Printf("Created OAuth signing key %s", key.ID)
+	return nil
+}
+
+// reload reads the keys that can still verify tokens from the database
+func (t *TokenIssuer) reload() error {
+	keys, err := t.repo.GetVerificationKeys(time.Now())
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]*loadedKey, len(keys))
+	var current *loadedKey
+	for _, key := range keys {
+		lk, err := decodeKey(key)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", key.ID, err)
+			continue
+		}
+		loaded[key.ID] = lk
+		// Keys are ordered newest first
+		if current == nil && key.RetiredAt == nil {
+			current = lk
+		}
+	}
+
+	t.Lock()
+	t.keys = loaded
+	t.current = current
+	t.loadedAt = time.Now()
+	t.Unlock()
+	return nil
+}
+
+// decodeKey decodes (and decrypts) the key material of a stored signing key
+func decodeKey(key SigningKey) (*loadedKey, error) {
+	public, err := base64.RawURLEncoding.DecodeString(key.PublicKey)
+	if err != nil || len(public) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+
+	seed := key.PrivateKey
+	if key.Encrypted {
+		if seed, err = utils.DecryptSecret(seed); err != nil {
+			return nil, err
+		}
+	}
+	seedBytes, err := base64.RawURLEncoding.DecodeString(seed)
+	if err != nil || len(seedBytes) != ed25519.SeedSize {
+		return nil, errors.New("invalid private key")
+	}
+
+	return &loadedKey{
+		SigningKey: key,
+		public:     ed25519.PublicKey(public),
+		private:    ed25519.NewKeyFromSeed(seedBytes),
+	}, nil
+}
+
+// Issue signs an access token for an authenticated access carrying the given permissions
+func (t *TokenIssuer) Issue(user *access.User, permissions []string) (string, time.Time, error) {
+	t.RLock()
+	current := t.current
+	t.RUnlock()
+	if current == nil {
+		return "", time.Time{}, errors.New("no active signing key")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(t.ttl)
+	// A token never outlives the access or the key it was issued for
+	if credentialEnd := credentialExpiry(user); credentialEnd != nil && credentialEnd.Before(expiresAt) {
+		expiresAt = *credentialEnd
+	}
+	// nor a temporary grant whose permission it carries
+	if grantsEnd := user.TemporaryGrantsExpireAt(now); grantsEnd != nil && grantsEnd.Before(expiresAt) {
+		expiresAt = *grantsEnd
+	}
+
+	claims := TokenClaims{
+		Issuer:       t.issuer,
+		Subject:      user.ID,
+		IssuedAt:     now.Unix(),
+		ExpiresAt:    expiresAt.Unix(),
+		ID:           utils.GenerateUUIDv7(),
+		Name:         user.Name,
+		Email:        user.Email,
+		GroupID:      user.GroupID,
+		Permissions:  permissions,
+		Denied:       permissionKeys(user.GetDeniedPermissions()),
+		RateLimit:    user.GetRateLimit(),
+		AllowedCIDRs: user.AllowedCIDRs,
+	}
+	if user.Group != nil {
+		claims.Group = user.Group.Name
+	}
+	for _, g := range user.Groups {
+		claims.Groups = append(claims.Groups, g.Name)
+	}
+	if user.ActiveKey != nil {
+		claims.KeyAllowedCIDRs = user.ActiveKey.AllowedCIDRs
+	}
+
+	token, err := utils.SignJWT(claims, current.ID, current.private)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// VerifyToken implements types.TokenVerifier. The returned access is built from
+// the token claims alone, so changes to the access apply once the token expires.
+func (t *TokenIssuer) VerifyToken(token string) (types.User, error) {
+	var claims TokenClaims
+	if err := utils.ParseJWT(token, t.publicKey, &claims); err != nil {
+		return nil, types.ErrInvalidToken
+	}
+	if claims.Issuer != t.issuer || claims.Subject == "" {
+		return nil, types.ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, types.ErrTokenExpired
+	}
+
+	// The token carries the effective permissions, so they are attached as direct
+	// grants and the groups only identify the access
+	var tokenGroup *group.Group
+	if claims.GroupID != nil {
+		tokenGroup = &group.Group{ID: *claims.GroupID, Name: claims.Group, StatusID: utils.Int16Ptr(types.StatusActive)}
+	}
+	var tokenGroups []group.Group
+	for _, name := range claims.Groups {
+		tokenGroups = append(tokenGroups, group.Group{Name: name, StatusID: utils.Int16Ptr(types.StatusActive)})
+	}
+	tokenPermissions := parsePermissionKeys(claims.Permissions)
+
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	user := &access.User{
+		ID:                claims.Subject,
+		Name:              claims.Name,
+		Email:             claims.Email,
+		GroupID:           claims.GroupID,
+		Group:             tokenGroup,
+		Groups:            tokenGroups,
+		DirectPermissions: tokenPermissions,
+		DeniedPermissions: parsePermissionKeys(claims.Denied),
+		ExpiredDate:       &expiresAt,
+		RateLimit:         claims.RateLimit,
+		AllowedCIDRs:      claims.AllowedCIDRs,
+		StatusID:          utils.Int16Ptr(types.StatusActive),
+		Credential:        access.CredentialJWT,
+	}
+	if len(claims.KeyAllowedCIDRs) > 0 {
+		user.ActiveKey = &access.AccessKey{AllowedCIDRs: claims.KeyAllowedCIDRs}
+	}
+	return user, nil
+}
+
+// permissionKeys lists permissions as "resource:action" pairs
+func permissionKeys(permissions []permission.Permission) []string {
+	var keys []string
+	for _, p := range permissions {
+		keys = append(keys, p.Resource+":"+p.Action)
+	}
+	return keys
+}
+
+// parsePermissionKeys builds permissions from "resource:action" pairs, skipping malformed ones
+func parsePermissionKeys(keys []string) []permission.Permission {
+	var permissions []permission.Permission
+	for _, key := range keys {
+		resource, action, ok := permission.SplitKey(key)
+		if !ok {
+			continue
+		}
+		permissions = append(permissions, permission.Permission{
+			Name:     key,
+			Resource: resource,
+			Action:   action,
+			StatusID: utils.Int16Ptr(types.StatusActive),
+		})
+	}
+	return permissions
+}
+
+// publicKey looks up a verification key, reloading once for keys rotated by another instance
+func (t *TokenIssuer) publicKey(keyID string) (ed25519.PublicKey, error) {
+	t.RLock()
+	key, ok := t.keys[keyID]
+	loadedAt := t.loadedAt
+	t.RUnlock()
+	if ok {
+		return key.public, nil
+	}
+	if time.Since(loadedAt) < minReloadInterval {
+		return nil, errUnknownSigningKey
+	}
+
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	t.RLock()
+	key, ok = t.keys[keyID]
+	t.RUnlock()
+	if !ok {
+		return nil, errUnknownSigningKey
+	}
+	return key.public, nil
+}
+
+// JWKS returns the public keys that can verify currently valid tokens
+func (t *TokenIssuer) JWKS() JWKS {
+	t.RLock()
+	defer t.RUnlock()
+
+	keys := make([]*loadedKey, 0, len(t.keys))
+	for _, key := range t.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+
+	set := JWKS{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         key.PublicKey,
+			KeyID:     key.ID,
+			Algorithm: key.Algorithm,
+			Use:       "sig",
+		})
+	}
+	return set
+}
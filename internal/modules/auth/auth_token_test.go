@@ -0,0 +1,55 @@
+// USAGE
+//   go test ./internal/modules/auth -v -run TestIssueExpiry
+
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"apiserver/internal/modules/access"
+)
+
+func TestIssueExpiry(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	issuer := NewTokenIssuer(nil, "test", time.Hour, 0)
+	issuer.current = &loadedKey{SigningKey: SigningKey{ID: "key"}, public: public, private: private}
+
+	now := time.Now()
+	inMinutes := func(m int) *time.Time {
+		at := now.Add(time.Duration(m) * time.Minute)
+		return &at
+	}
+
+	tests := []struct {
+		name string
+		user *access.User
+		want time.Duration // Token lifetime
+	}{
+		{name: "Token lifetime", user: &access.User{ID: "a"}, want: time.Hour},
+		{name: "Access expiring first", user: &access.User{ID: "a", ExpiredDate: inMinutes(10)}, want: 10 * time.Minute},
+		{name: "Named key expiring first", user: &access.User{ID: "a", ExpiredDate: inMinutes(50),
+			ActiveKey: &access.AccessKey{ExpiredDate: inMinutes(20)}}, want: 20 * time.Minute},
+		{name: "Rotated key in its grace period", user: &access.User{ID: "a", Credential: access.CredentialPrevious,
+			PreviousAPIKeyExpiresAt: inMinutes(5)}, want: 5 * time.Minute},
+		{name: "Current key after a rotation", user: &access.User{ID: "a", Credential: access.CredentialPrimary,
+			PreviousAPIKeyExpiresAt: inMinutes(5)}, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, expiresAt, err := issuer.Issue(tt.user, nil)
+			if err != nil {
+				t.Fatalf("issue failed: %v", err)
+			}
+			if got := expiresAt.Sub(now); got < tt.want-time.Second || got > tt.want+time.Second {
+				t.Errorf("token lifetime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,23 +1,30 @@
 package group
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
+	"apiserver/internal/cache"
+	"apiserver/internal/modules/permission"
+
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type Handler struct {
-	repo Repository
+	repo        Repository
+	invalidator cache.Invalidator
 }
 
-func NewHandler(repo Repository) *Handler {
-	return &Handler{repo: repo}
+func NewHandler(repo Repository, invalidator cache.Invalidator) *Handler {
+	return &Handler{repo: repo, invalidator: invalidator}
 }
 
 // CreateGroup godoc
 // @Summary Create a new group
-// @Description Create a new group with permissions
+// @Description Create a new group with permissions and optional parent groups to inherit from
 // @Tags Group
 // @Accept json
 // @Produce json
@@ -30,7 +37,7 @@ func NewHandler(repo Repository) *Handler {
 // @Router /v1/groups [post]
 func (h *Handler) CreateGroup(c *fiber.Ctx) error {
 	var req CreateGroupRequest
-	
+
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
@@ -58,9 +65,10 @@ func (h *Handler) CreateGroup(c *fiber.Ctx) error {
 		})
 	}
 
-	// Add permissions if provided
+	// Add permissions if provided, as the first version of the group's permissions
 	if len(req.PermissionIDs) > 0 {
-		if err := h.repo.UpdateGroupPermissions(group.ID, req.PermissionIDs); err != nil {
+		change := GroupPermissionVersion{Kind: VersionCreate, ActorID: actorID(c)}
+		if _, err := h.repo.ReplaceGroupPermissions(group.ID, req.PermissionIDs, change); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Group created but failed to assign permissions",
@@ -68,6 +76,17 @@ func (h *Handler) CreateGroup(c *fiber.Ctx) error {
 		}
 	}
 
+	// Add parents if provided
+	if len(req.ParentIDs) > 0 {
+		if err := h.repo.UpdateGroupParents(group.ID, req.ParentIDs); err != nil {
+			status, message := parentsError(err)
+			return c.Status(status).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Group created but failed to assign parents: " + message,
+			})
+		}
+	}
+
 	// Fetch the group with permissions
 	groupWithPermissions, err := h.repo.GetGroupWithPermissions(group.ID)
 	if err != nil {
@@ -111,7 +130,7 @@ func (h *Handler) GetGroups(c *fiber.Ctx) error {
 
 // GetGroup godoc
 // @Summary Get group by ID
-// @Description Get a specific group by its ID with permissions
+// @Description Get a specific group by its ID with its parents, direct permissions and permissions inherited from ancestor groups
 // @Tags Group
 // @Accept json
 // @Produce json
@@ -147,9 +166,171 @@ func (h *Handler) GetGroup(c *fiber.Ctx) error {
 	})
 }
 
+// UpdateGroup godoc
+// @Summary Update group
+// @Description Rename a group or change its description. Omitted fields are left unchanged.
+// @Tags Group
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Group ID"
+// @Param group body UpdateGroupRequest true "Name and description"
+// @Success 200 {object} Group
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{id} [patch]
+func (h *Handler) UpdateGroup(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+
+	var req UpdateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	group, err := h.repo.GetGroupByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group not found",
+		})
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Name cannot be empty",
+			})
+		}
+		if err := h.ensureNameFree(name, group.ID); err != nil {
+			return err
+		}
+		group.Name = name
+	}
+	if req.Description != nil {
+		group.Description = *req.Description
+	}
+
+	if err := h.repo.UpdateGroup(group); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update group",
+		})
+	}
+
+	// Cached principals carry the group name
+	h.invalidateGroup(group.ID)
+
+	updated, err := h.repo.GetGroupWithPermissions(group.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group updated but failed to fetch details",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   updated,
+	})
+}
+
+// CloneGroup godoc
+// @Summary Clone group
+// @Description Create a group with the permissions, deny rules and parents of another group. The clone has no members.
+// @Tags Group
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID of the group to copy"
+// @Param group body CloneGroupRequest true "Name and optional description of the clone"
+// @Success 201 {object} Group
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{id}/clone [post]
+func (h *Handler) CloneGroup(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+
+	var req CloneGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Name is required",
+		})
+	}
+
+	source, err := h.repo.GetGroupByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group not found",
+		})
+	}
+	if err := h.ensureNameFree(name, 0); err != nil {
+		return err
+	}
+
+	description := source.Description
+	if req.Description != nil {
+		description = *req.Description
+	}
+	clone, err := h.repo.CloneGroup(source.ID, name, description)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to clone group",
+		})
+	}
+
+	group, err := h.repo.GetGroupWithPermissions(clone.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group cloned but failed to fetch details",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": fmt.Sprintf("Group '%s' cloned from '%s'", group.Name, source.Name),
+		"data":    group,
+	})
+}
+
 // UpdateGroupPermissions godoc
 // @Summary Update group permissions
-// @Description Update permissions assigned to a group
+// @Description Replace the permissions assigned to a group. Each change is recorded as a new version with its actor and diff, see GET /v1/groups/{id}/history.
 // @Tags Group
 // @Accept json
 // @Produce json
@@ -180,13 +361,24 @@ func (h *Handler) UpdateGroupPermissions(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.repo.UpdateGroupPermissions(uint(id), req.PermissionIDs); err != nil {
+	change := GroupPermissionVersion{Kind: VersionUpdate, ActorID: actorID(c)}
+	version, err := h.repo.ReplaceGroupPermissions(uint(id), req.PermissionIDs, change)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Group not found",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to update group permissions",
 		})
 	}
 
+	// Members must not keep using cached permissions
+	h.invalidateGroup(uint(id))
+
 	// Fetch updated group with permissions
 	group, err := h.repo.GetGroupWithPermissions(uint(id))
 	if err != nil {
@@ -196,6 +388,194 @@ func (h *Handler) UpdateGroupPermissions(c *fiber.Ctx) error {
 		})
 	}
 
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"data":    group,
+		"version": version,
+	})
+}
+
+// GetGroupHistory godoc
+// @Summary Get group permission history
+// @Description List the versions of a group's permissions, newest first, with the actor, time and the permissions each change added and removed
+// @Tags Group
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Group ID"
+// @Success 200 {array} GroupPermissionVersion
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{id}/history [get]
+func (h *Handler) GetGroupHistory(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+
+	if _, err := h.repo.GetGroupByID(uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Group not found",
+		})
+	}
+
+	versions, err := h.repo.GetPermissionHistory(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to fetch group history",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   versions,
+	})
+}
+
+// RollbackGroupPermissions godoc
+// @Summary Roll back group permissions
+// @Description Restore the permissions a group had at an earlier version. The rollback is recorded as a new version. Permissions that have since been deleted are skipped.
+// @Tags Group
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Group ID"
+// @Param version path int true "Version to restore"
+// @Success 200 {object} Group
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{id}/rollback/{version} [post]
+func (h *Handler) RollbackGroupPermissions(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+	number, err := strconv.Atoi(c.Params("version"))
+	if err != nil || number < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid version",
+		})
+	}
+
+	target, err := h.repo.GetPermissionVersion(uint(id), number)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Version not found",
+		})
+	}
+
+	change := GroupPermissionVersion{Kind: VersionRollback, RolledBackTo: &target.Version, ActorID: actorID(c)}
+	version, err := h.repo.ReplaceGroupPermissions(uint(id), target.PermissionIDs, change)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Group not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to roll back group permissions",
+		})
+	}
+
+	// Members must not keep using cached permissions
+	h.invalidateGroup(uint(id))
+
+	group, err := h.repo.GetGroupWithPermissions(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Permissions rolled back but failed to fetch group details",
+		})
+	}
+
+	message := fmt.Sprintf("Permissions rolled back to version %d", target.Version)
+	if skipped := subtractKeys(target.Permissions, version.Permissions); len(skipped) > 0 {
+		message += "; skipped permissions that are no longer active: " + strings.Join(skipped, ", ")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    group,
+		"version": version,
+	})
+}
+
+// UpdateGroupDeniedPermissions godoc
+// @Summary Update group deny rules
+// @Description Replace the deny rules of a group. A denied permission is refused to every member and to every group inheriting from it, even when a group, a wildcard or a direct grant allows it.
+// @Tags Group
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Group ID"
+// @Param permissions body UpdateGroupDeniedPermissionsRequest true "Permission IDs to deny"
+// @Success 200 {object} Group
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{id}/denied-permissions [put]
+func (h *Handler) UpdateGroupDeniedPermissions(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+
+	var req UpdateGroupDeniedPermissionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := h.repo.UpdateGroupDeniedPermissions(uint(id), req.PermissionIDs); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Group not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to update group deny rules",
+		})
+	}
+
+	// Members must not keep using cached permissions
+	h.invalidateGroup(uint(id))
+
+	group, err := h.repo.GetGroupWithPermissions(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Deny rules updated but failed to fetch group details",
+		})
+	}
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status": "success",
 		"data":   group,
@@ -204,16 +584,18 @@ func (h *Handler) UpdateGroupPermissions(c *fiber.Ctx) error {
 
 // DeleteGroup godoc
 // @Summary Delete group
-// @Description Delete a group by ID
+// @Description Delete a group by ID. A group that is still the primary or an additional group of an access is only deleted when reassign_to names an active group to move those accesses to.
 // @Tags Group
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Group ID"
+// @Param reassign_to query int false "Group receiving the members of the deleted group"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /v1/groups/{id} [delete]
 func (h *Handler) DeleteGroup(c *fiber.Ctx) error {
@@ -226,15 +608,267 @@ func (h *Handler) DeleteGroup(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.repo.DeleteGroup(uint(id)); err != nil {
+	// The target group can be given in the query or in a JSON body
+	var req DeleteGroupRequest
+	if err := c.QueryParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid reassign_to",
+		})
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	// Moved members must not gain privileges the actor does not hold
+	if req.ReassignTo != 0 {
+		if err := h.checkReassignTarget(c, uint(id), req.ReassignTo); err != nil {
+			return err
+		}
+	}
+
+	members, err := h.repo.DeleteGroup(uint(id), req.ReassignTo)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Group not found",
+			})
+		case errors.Is(err, ErrGroupHasMembers):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"status":  "error",
+				"message": fmt.Sprintf("Group still has %d members; move them first or give reassign_to", members),
+				"members": members,
+			})
+		case errors.Is(err, ErrReassignTargetNotFound):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "reassign_to must be another active group",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to delete group",
 		})
 	}
 
+	// Members must not keep using cached permissions
+	h.invalidateGroup(uint(id))
+
+	message := "Group deleted successfully"
+	if members > 0 {
+		message = fmt.Sprintf("Group deleted successfully; %d members moved to group %d", members, req.ReassignTo)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+	})
+}
+
+// RestoreGroup godoc
+// @Summary Restore group
+// @Description Restore a deleted group with the permissions, deny rules and parents it had. Members moved away when it was deleted are not moved back.
+// @Tags Group
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Group ID"
+// @Success 200 {object} Group
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{id}/restore [post]
+func (h *Handler) RestoreGroup(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+
+	group, err := h.repo.RestoreGroup(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Deleted group not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to restore group",
+		})
+	}
+
+	// Groups inheriting from it get its permissions back
+	h.invalidateGroup(group.ID)
+
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Group deleted successfully",
+		"message": "Group restored successfully",
+		"data":    group,
+	})
+}
+
+// UpdateGroupParents godoc
+// @Summary Update group parents
+// @Description Replace the parent groups a group inherits permissions from. Inheritance is transitive and cycles are rejected.
+// @Tags Group
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Group ID"
+// @Param parents body UpdateGroupParentsRequest true "Parent group IDs"
+// @Success 200 {object} Group
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /v1/groups/{id}/parents [put]
+func (h *Handler) UpdateGroupParents(c *fiber.Ctx) error {
+	idStr := c.Params("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid group ID",
+		})
+	}
+
+	var req UpdateGroupParentsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := h.repo.UpdateGroupParents(uint(id), req.ParentIDs); err != nil {
+		status, message := parentsError(err)
+		return c.Status(status).JSON(fiber.Map{
+			"status":  "error",
+			"message": message,
+		})
+	}
+
+	// Members of the group and of every group inheriting from it must not keep using cached permissions
+	h.invalidateGroup(uint(id))
+
+	group, err := h.repo.GetGroupWithPermissions(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Parents updated but failed to fetch group details",
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": "success",
+		"data":   group,
 	})
-}
\ No newline at end of file
+}
+
+// invalidateGroup drops the cached principals of a group and of every group inheriting from it
+func (h *Handler) invalidateGroup(id uint) {
+	descendants, err := h.repo.GetDescendantIDs(id)
+	if err != nil {
+		h.invalidator.InvalidateAll()
+		return
+	}
+	h.invalidator.InvalidateGroup(id)
+	for _, descendantID := range descendants {
+		h.invalidator.InvalidateGroup(descendantID)
+	}
+}
+
+// ensureNameFree responds with a conflict when another group, active or deleted, uses the name
+func (h *Handler) ensureNameFree(name string, exceptID uint) error {
+	taken, err := h.repo.IsNameTaken(name, exceptID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check the group name")
+	}
+	if taken {
+		return fiber.NewError(fiber.StatusConflict, "A group named '"+name+"' already exists")
+	}
+	return nil
+}
+
+// checkReassignTarget fails unless targetID is another active group whose effective permissions the
+// actor holds, the check PUT /v1/access/{id}/group applies before assigning a group
+func (h *Handler) checkReassignTarget(c *fiber.Ctx, groupID, targetID uint) error {
+	if targetID == groupID {
+		return fiber.NewError(fiber.StatusBadRequest, "reassign_to must be another active group")
+	}
+	target, err := h.repo.GetGroupWithPermissions(targetID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fiber.NewError(fiber.StatusBadRequest, "reassign_to must be another active group")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to load the reassign_to group")
+	}
+
+	actor, ok := c.Locals("user").(principal)
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "User not authenticated")
+	}
+	missing := permission.Missing(actor.GetPermissions(), actor.GetDeniedPermissions(), target.EffectivePermissions())
+	if len(missing) > 0 {
+		return fiber.NewError(fiber.StatusForbidden,
+			"Cannot move members to a group with more privileges than your own: "+strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// principal is the part of types.User used here; the types package imports this one
+type principal interface {
+	GetPermissions() []permission.Permission
+	GetDeniedPermissions() []permission.Permission
+}
+
+// actorID returns the ID of the authenticated access making the request, if any
+func actorID(c *fiber.Ctx) *string {
+	if user, ok := c.Locals("user").(interface{ GetID() string }); ok {
+		id := user.GetID()
+		return &id
+	}
+	return nil
+}
+
+// subtractKeys returns the keys of from that are not in keys
+func subtractKeys(from, keys []string) []string {
+	present := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		present[key] = true
+	}
+	var missing []string
+	for _, key := range from {
+		if !present[key] {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// parentsError maps an UpdateGroupParents error to a status code and message
+func parentsError(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrGroupCycle):
+		return fiber.StatusConflict, "A group cannot inherit from itself or from one of its descendants"
+	case errors.Is(err, ErrParentNotFound):
+		return fiber.StatusBadRequest, "Parent group not found or inactive"
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.StatusNotFound, "Group not found"
+	default:
+		return fiber.StatusInternalServerError, "Failed to update group parents"
+	}
+}
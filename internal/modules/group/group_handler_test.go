@@ -0,0 +1,329 @@
+// USAGE
+//   go test ./internal/modules/group -v -run 'TestDeleteGroup|TestUpdateGroupPermissions|TestRollbackGroupPermissions'
+
+package group
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"apiserver/internal/cache"
+	"apiserver/internal/modules/permission"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// stubRepository serves fixed groups, records deletions and keeps permission versions in memory
+type stubRepository struct {
+	Repository
+	groups     map[uint]*Group
+	members    int64
+	deleted    uint
+	reassigned uint
+	active     []permission.Permission // Permissions that are not deleted
+	versions   []GroupPermissionVersion
+}
+
+func (r *stubRepository) GetGroupWithPermissions(id uint) (*Group, error) {
+	if g, ok := r.groups[id]; ok {
+		return g, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *stubRepository) GetDescendantIDs(uint) ([]uint, error) {
+	return nil, nil
+}
+
+func (r *stubRepository) DeleteGroup(id, reassignTo uint) (int64, error) {
+	if _, ok := r.groups[id]; !ok {
+		return 0, gorm.ErrRecordNotFound
+	}
+	if r.members > 0 && reassignTo == 0 {
+		return r.members, ErrGroupHasMembers
+	}
+	r.deleted, r.reassigned = id, reassignTo
+	return r.members, nil
+}
+
+func (r *stubRepository) ReplaceGroupPermissions(groupID uint, permissionIDs []uint, change GroupPermissionVersion) (*GroupPermissionVersion, error) {
+	g, ok := r.groups[groupID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var permissions []permission.Permission
+	for _, p := range r.active {
+		for _, id := range permissionIDs {
+			if p.ID == id {
+				permissions = append(permissions, p)
+				break
+			}
+		}
+	}
+
+	var latest GroupPermissionVersion
+	if len(r.versions) > 0 {
+		latest = r.versions[len(r.versions)-1]
+	}
+	versions := newVersions(latest, groupID, g.Permissions, permissions, change)
+	r.versions = append(r.versions, versions...)
+	g.Permissions = permissions
+	return &versions[len(versions)-1], nil
+}
+
+func (r *stubRepository) GetPermissionVersion(groupID uint, version int) (*GroupPermissionVersion, error) {
+	for i := range r.versions {
+		if r.versions[i].GroupID == groupID && r.versions[i].Version == version {
+			return &r.versions[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// actor is an authenticated access with fixed permissions
+type actor struct {
+	permissions []permission.Permission
+	denies      []permission.Permission
+}
+
+func (a actor) GetID() string                                 { return "actor" }
+func (a actor) GetPermissions() []permission.Permission       { return a.permissions }
+func (a actor) GetDeniedPermissions() []permission.Permission { return a.denies }
+
+func perm(key string) permission.Permission {
+	resource, action, _ := permission.SplitKey(key)
+	return permission.Permission{Resource: resource, Action: action}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	groupManager := actor{permissions: []permission.Permission{perm("groups:manage"), perm("examples:read")}}
+	superAdmin := actor{permissions: []permission.Permission{perm("*:*")}}
+
+	tests := []struct {
+		name       string
+		actor      actor
+		members    int64
+		url        string
+		wantStatus int
+		wantMoved  bool
+	}{
+		{name: "Group without members", actor: groupManager, url: "/v1/groups/2", wantStatus: fiber.StatusOK},
+		{name: "Group with members", actor: groupManager, members: 3, url: "/v1/groups/2", wantStatus: fiber.StatusConflict},
+		{name: "Reassign to a group the actor covers", actor: groupManager, members: 3, url: "/v1/groups/2?reassign_to=3", wantStatus: fiber.StatusOK, wantMoved: true},
+		{name: "Reassign to a more privileged group", actor: groupManager, members: 3, url: "/v1/groups/2?reassign_to=1", wantStatus: fiber.StatusForbidden},
+		{name: "Reassign to a denied permission", actor: actor{permissions: superAdmin.permissions, denies: []permission.Permission{perm("examples:delete")}}, members: 3, url: "/v1/groups/2?reassign_to=1", wantStatus: fiber.StatusForbidden},
+		{name: "Reassign by an actor holding everything", actor: superAdmin, members: 3, url: "/v1/groups/2?reassign_to=1", wantStatus: fiber.StatusOK, wantMoved: true},
+		{name: "Reassign to the deleted group", actor: superAdmin, members: 3, url: "/v1/groups/2?reassign_to=2", wantStatus: fiber.StatusBadRequest},
+		{name: "Reassign to a missing group", actor: superAdmin, members: 3, url: "/v1/groups/2?reassign_to=9", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubRepository{members: tt.members, groups: map[uint]*Group{
+				1: {ID: 1, Name: "Admin", Permissions: []permission.Permission{perm("*:*")}},
+				2: {ID: 2, Name: "Editor", Permissions: []permission.Permission{perm("examples:read")}},
+				3: {ID: 3, Name: "Viewer", Permissions: []permission.Permission{perm("examples:read")}},
+			}}
+			handler := NewHandler(repo, cache.NopInvalidator{})
+
+			app := fiber.New()
+			app.Delete("/v1/groups/:id", func(c *fiber.Ctx) error {
+				c.Locals("user", tt.actor)
+				return c.Next()
+			}, handler.DeleteGroup)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, tt.url, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if moved := repo.reassigned != 0; moved != tt.wantMoved {
+				t.Errorf("members moved = %v, want %v", moved, tt.wantMoved)
+			}
+		})
+	}
+}
+
+// Permissions known to the version tests; examplesDelete has been deleted
+var (
+	examplesRead   = permission.Permission{ID: 1, Resource: "examples", Action: "read"}
+	examplesUpdate = permission.Permission{ID: 2, Resource: "examples", Action: "update"}
+	examplesDelete = permission.Permission{ID: 3, Resource: "examples", Action: "delete"}
+)
+
+// describeVersion summarizes a version as "<version> <kind>[ to <version>] <permissions> +<added> -<removed>"
+func describeVersion(v GroupPermissionVersion) string {
+	kind := v.Kind
+	if v.RolledBackTo != nil {
+		kind += fmt.Sprintf(" to %d", *v.RolledBackTo)
+	}
+	return fmt.Sprintf("%d %s %v +%v -%v", v.Version, kind, v.Permissions, v.Added, v.Removed)
+}
+
+// recordedVersion builds a stored version of group 2 holding permissions
+func recordedVersion(version int, kind string, permissions ...permission.Permission) GroupPermissionVersion {
+	v := GroupPermissionVersion{GroupID: 2, Version: version, Kind: kind}
+	setSnapshot(&v, permissions)
+	return v
+}
+
+func TestUpdateGroupPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []permission.Permission // Direct permissions of the group before the change
+		versions    []GroupPermissionVersion
+		body        string
+		wantHistory []string
+	}{
+		{name: "First change records the initial permissions", permissions: []permission.Permission{examplesRead},
+			body: `{"permission_ids":[1,2]}`,
+			wantHistory: []string{
+				"1 initial [examples:read] +[examples:read] -[]",
+				"2 update [examples:read examples:update] +[examples:update] -[]",
+			}},
+		{name: "Group without permissions has no initial version",
+			body:        `{"permission_ids":[1]}`,
+			wantHistory: []string{"1 update [examples:read] +[examples:read] -[]"}},
+		{name: "Change after a recorded version", permissions: []permission.Permission{examplesRead, examplesUpdate},
+			versions: []GroupPermissionVersion{recordedVersion(1, VersionUpdate, examplesRead, examplesUpdate)},
+			body:     `{"permission_ids":[2]}`,
+			wantHistory: []string{
+				"1 update [examples:read examples:update] +[] -[]",
+				"2 update [examples:update] +[] -[examples:read]",
+			}},
+		{name: "Deleted permissions are not granted", permissions: []permission.Permission{examplesRead},
+			versions: []GroupPermissionVersion{recordedVersion(1, VersionCreate, examplesRead)},
+			body:     `{"permission_ids":[1,3]}`,
+			wantHistory: []string{
+				"1 create [examples:read] +[] -[]",
+				"2 update [examples:read] +[] -[]",
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubRepository{
+				groups:   map[uint]*Group{2: {ID: 2, Name: "Editor", Permissions: tt.permissions}},
+				active:   []permission.Permission{examplesRead, examplesUpdate},
+				versions: tt.versions,
+			}
+			handler := NewHandler(repo, cache.NopInvalidator{})
+
+			app := fiber.New()
+			app.Put("/v1/groups/:id/permissions", func(c *fiber.Ctx) error {
+				c.Locals("user", actor{})
+				return c.Next()
+			}, handler.UpdateGroupPermissions)
+
+			req := httptest.NewRequest(fiber.MethodPut, "/v1/groups/2/permissions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+			}
+
+			var body struct {
+				Version GroupPermissionVersion `json:"version"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if got, want := describeVersion(body.Version), tt.wantHistory[len(tt.wantHistory)-1]; got != want {
+				t.Errorf("version = %q, want %q", got, want)
+			}
+			if body.Version.ActorID == nil || *body.Version.ActorID != "actor" {
+				t.Errorf("actor = %v, want actor", body.Version.ActorID)
+			}
+			if len(repo.versions) != len(tt.wantHistory) {
+				t.Fatalf("%d versions recorded, want %d", len(repo.versions), len(tt.wantHistory))
+			}
+			for i, v := range repo.versions {
+				if got := describeVersion(v); got != tt.wantHistory[i] {
+					t.Errorf("version %d = %q, want %q", i+1, got, tt.wantHistory[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRollbackGroupPermissions(t *testing.T) {
+	tests := []struct {
+		name        string
+		versions    []GroupPermissionVersion
+		url         string
+		wantStatus  int
+		wantVersion string
+		wantMessage string
+	}{
+		{name: "Roll back to an earlier version",
+			versions: []GroupPermissionVersion{
+				recordedVersion(1, VersionInitial, examplesRead),
+				recordedVersion(2, VersionUpdate, examplesRead, examplesUpdate),
+			},
+			url: "/v1/groups/2/rollback/1", wantStatus: fiber.StatusOK,
+			wantVersion: "3 rollback to 1 [examples:read] +[] -[examples:update]",
+			wantMessage: "Permissions rolled back to version 1"},
+		{name: "Deleted permissions are skipped",
+			versions: []GroupPermissionVersion{
+				recordedVersion(1, VersionCreate, examplesRead, examplesDelete),
+				recordedVersion(2, VersionUpdate, examplesRead, examplesUpdate),
+			},
+			url: "/v1/groups/2/rollback/1", wantStatus: fiber.StatusOK,
+			wantVersion: "3 rollback to 1 [examples:read] +[] -[examples:update]",
+			wantMessage: "Permissions rolled back to version 1; skipped permissions that are no longer active: examples:delete"},
+		{name: "Missing version", versions: []GroupPermissionVersion{recordedVersion(1, VersionCreate, examplesRead)},
+			url: "/v1/groups/2/rollback/5", wantStatus: fiber.StatusNotFound},
+		{name: "Invalid version", url: "/v1/groups/2/rollback/0", wantStatus: fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubRepository{
+				groups:   map[uint]*Group{2: {ID: 2, Name: "Editor", Permissions: []permission.Permission{examplesRead, examplesUpdate}}},
+				active:   []permission.Permission{examplesRead, examplesUpdate},
+				versions: tt.versions,
+			}
+			handler := NewHandler(repo, cache.NopInvalidator{})
+
+			app := fiber.New()
+			app.Post("/v1/groups/:id/rollback/:version", func(c *fiber.Ctx) error {
+				c.Locals("user", actor{})
+				return c.Next()
+			}, handler.RollbackGroupPermissions)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, tt.url, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			var body struct {
+				Message string                 `json:"message"`
+				Version GroupPermissionVersion `json:"version"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if got := describeVersion(body.Version); got != tt.wantVersion {
+				t.Errorf("version = %q, want %q", got, tt.wantVersion)
+			}
+			if body.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", body.Message, tt.wantMessage)
+			}
+		})
+	}
+}
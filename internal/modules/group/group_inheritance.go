@@ -0,0 +1,184 @@
+package group
+
+import (
+	"apiserver/internal/modules/permission"
+
+	"gorm.io/gorm"
+)
+
+// LoadInheritedPermissions fills InheritedPermissions and InheritedDenies of each group from its
+// active ancestors. Ancestors are visited nearest first, so a permission is attributed to the closest
+// group granting it. Inactive groups stop the inheritance chain, and cycles in stored links are ignored.
+func LoadInheritedPermissions(db *gorm.DB, groups ...*Group) error {
+	var ids []uint
+	for _, g := range groups {
+		if g != nil {
+			g.InheritedPermissions = nil
+			g.InheritedDenies = nil
+			ids = append(ids, g.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	edges, err := loadEdges(db, ids, true)
+	if err != nil || len(edges) == 0 {
+		return err
+	}
+
+	var ancestorIDs []uint
+	for _, parents := range edges {
+		ancestorIDs = append(ancestorIDs, parents...)
+	}
+	var ancestors []Group
+	if err := db.Preload("Permissions", "status_id = ?", 0).
+		Preload("DeniedPermissions", "status_id = ?", 0).
+		Where("id IN ? AND status_id = ?", ancestorIDs, 0).
+		Find(&ancestors).Error; err != nil {
+		return err
+	}
+	active := make(map[uint]*Group, len(ancestors))
+	for i := range ancestors {
+		active[ancestors[i].ID] = &ancestors[i]
+	}
+
+	for _, g := range groups {
+		if g == nil {
+			continue
+		}
+		isActive := func(id uint) bool { return active[id] != nil }
+		var inherited []*Group
+		for _, ancestorID := range reachable(edges, g.ID, isActive) {
+			inherited = append(inherited, active[ancestorID])
+		}
+		g.InheritedPermissions = inherit(g.Permissions, inherited, func(a *Group) []permission.Permission { return a.Permissions })
+		g.InheritedDenies = inherit(g.DeniedPermissions, inherited, func(a *Group) []permission.Permission { return a.DeniedPermissions })
+	}
+	return nil
+}
+
+// LoadAncestorIDs returns the active ancestors of each group, keyed by group ID, nearest first.
+// Like LoadInheritedPermissions, inactive groups stop the inheritance chain.
+func LoadAncestorIDs(db *gorm.DB, ids ...uint) (map[uint][]uint, error) {
+	ancestors := make(map[uint][]uint, len(ids))
+	if len(ids) == 0 {
+		return ancestors, nil
+	}
+	edges, err := loadEdges(db, ids, true)
+	if err != nil || len(edges) == 0 {
+		return ancestors, err
+	}
+
+	var linked, activeIDs []uint
+	for _, parents := range edges {
+		linked = append(linked, parents...)
+	}
+	if err := db.Model(&Group{}).Where("id IN ? AND status_id = ?", linked, 0).Pluck("id", &activeIDs).Error; err != nil {
+		return nil, err
+	}
+	active := make(map[uint]bool, len(activeIDs))
+	for _, id := range activeIDs {
+		active[id] = true
+	}
+
+	for _, id := range ids {
+		ancestors[id] = reachable(edges, id, func(ancestorID uint) bool { return active[ancestorID] })
+	}
+	return ancestors, nil
+}
+
+// inherit collects the permissions of the ancestors that the group does not hold itself
+func inherit(own []permission.Permission, ancestors []*Group, list func(*Group) []permission.Permission) []InheritedPermission {
+	held := make(map[string]bool)
+	for _, p := range own {
+		held[p.Resource+":"+p.Action] = true
+	}
+
+	var inherited []InheritedPermission
+	for _, ancestor := range ancestors {
+		for _, p := range list(ancestor) {
+			key := p.Resource + ":" + p.Action
+			if held[key] {
+				continue
+			}
+			held[key] = true
+			inherited = append(inherited, InheritedPermission{
+				Permission:    p,
+				InheritedFrom: GroupRef{ID: ancestor.ID, Name: ancestor.Name},
+			})
+		}
+	}
+	return inherited
+}
+
+// loadEdges loads the group_parents links reachable from the start groups as an adjacency map,
+// following parents when up is true and children otherwise
+func loadEdges(db *gorm.DB, start []uint, up bool) (map[uint][]uint, error) {
+	from := "group_id"
+	if !up {
+		from = "parent_id"
+	}
+
+	edges := make(map[uint][]uint)
+	visited := make(map[uint]bool)
+	for _, id := range start {
+		visited[id] = true
+	}
+	for frontier := start; len(frontier) > 0; {
+		var links []GroupParent
+		if err := db.Where(from+" IN ?", frontier).Find(&links).Error; err != nil {
+			return nil, err
+		}
+
+		frontier = nil
+		for _, link := range links {
+			src, dst := link.GroupID, link.ParentID
+			if !up {
+				src, dst = link.ParentID, link.GroupID
+			}
+			edges[src] = append(edges[src], dst)
+			if !visited[dst] {
+				visited[dst] = true
+				frontier = append(frontier, dst)
+			}
+		}
+	}
+	return edges, nil
+}
+
+// createsCycle reports whether one of parentIDs is the group itself or one of its descendants,
+// given the links from each group to its children
+func createsCycle(children map[uint][]uint, groupID uint, parentIDs []uint) bool {
+	descendants := map[uint]bool{groupID: true}
+	for _, id := range reachable(children, groupID, nil) {
+		descendants[id] = true
+	}
+	for _, id := range parentIDs {
+		if descendants[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// reachable lists the groups reachable from id in breadth-first order, excluding id itself.
+// Groups rejected by allow (when set) are neither listed nor traversed.
+func reachable(edges map[uint][]uint, id uint, allow func(uint) bool) []uint {
+	seen := map[uint]bool{id: true}
+	queue := []uint{id}
+	var result []uint
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[current] {
+			if seen[next] || (allow != nil && !allow(next)) {
+				continue
+			}
+			seen[next] = true
+			result = append(result, next)
+			queue = append(queue, next)
+		}
+	}
+	return result
+}
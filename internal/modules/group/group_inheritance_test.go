@@ -0,0 +1,107 @@
+// USAGE
+//   go test ./internal/modules/group -v -run 'TestReachable|TestInherit|TestCreatesCycle'
+
+package group
+
+import (
+	"reflect"
+	"testing"
+
+	"apiserver/internal/modules/permission"
+)
+
+func TestReachable(t *testing.T) {
+	tests := []struct {
+		name     string
+		edges    map[uint][]uint // Group to its parents
+		inactive []uint
+		want     []uint
+	}{
+		{name: "No parents", edges: map[uint][]uint{}},
+		{name: "Nearest first", edges: map[uint][]uint{1: {2, 3}, 2: {4}, 3: {5}}, want: []uint{2, 3, 4, 5}},
+		{name: "Shared ancestor listed once", edges: map[uint][]uint{1: {2, 3}, 2: {4}, 3: {4}}, want: []uint{2, 3, 4}},
+		{name: "Self parent ignored", edges: map[uint][]uint{1: {1, 2}}, want: []uint{2}},
+		{name: "Stored cycle ignored", edges: map[uint][]uint{1: {2}, 2: {3}, 3: {1}}, want: []uint{2, 3}},
+		{name: "Inactive group stops the chain", edges: map[uint][]uint{1: {2, 3}, 2: {4}}, inactive: []uint{2}, want: []uint{3}},
+		{name: "Ancestor also reachable through an active path", edges: map[uint][]uint{1: {2, 3}, 2: {4}, 3: {4}}, inactive: []uint{2}, want: []uint{3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active := func(id uint) bool {
+				for _, inactive := range tt.inactive {
+					if id == inactive {
+						return false
+					}
+				}
+				return true
+			}
+			if got := reachable(tt.edges, 1, active); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reachable = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInherit(t *testing.T) {
+	read := permission.Permission{ID: 1, Resource: "examples", Action: "read"}
+	write := permission.Permission{ID: 2, Resource: "examples", Action: "update"}
+	editor := &Group{ID: 2, Name: "Editor", Permissions: []permission.Permission{read, write}}
+	viewer := &Group{ID: 3, Name: "Viewer", Permissions: []permission.Permission{read}}
+	direct := func(g *Group) []permission.Permission { return g.Permissions }
+
+	tests := []struct {
+		name      string
+		own       []permission.Permission
+		ancestors []*Group // Nearest first
+		want      []InheritedPermission
+	}{
+		{name: "No ancestors"},
+		{name: "Nearest ancestor wins attribution", ancestors: []*Group{editor, viewer}, want: []InheritedPermission{
+			{Permission: read, InheritedFrom: GroupRef{ID: 2, Name: "Editor"}},
+			{Permission: write, InheritedFrom: GroupRef{ID: 2, Name: "Editor"}},
+		}},
+		{name: "Farther ancestor attributed what nearer ones lack", ancestors: []*Group{viewer, editor}, want: []InheritedPermission{
+			{Permission: read, InheritedFrom: GroupRef{ID: 3, Name: "Viewer"}},
+			{Permission: write, InheritedFrom: GroupRef{ID: 2, Name: "Editor"}},
+		}},
+		{name: "Own permissions are not inherited", own: []permission.Permission{read}, ancestors: []*Group{editor}, want: []InheritedPermission{
+			{Permission: write, InheritedFrom: GroupRef{ID: 2, Name: "Editor"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inherit(tt.own, tt.ancestors, direct); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inherit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreatesCycle(t *testing.T) {
+	// 1 is extended by 2, which is extended by 3; 4 is unrelated
+	children := map[uint][]uint{1: {2}, 2: {3}}
+
+	tests := []struct {
+		name      string
+		groupID   uint
+		parentIDs []uint
+		want      bool
+	}{
+		{name: "Self parent", groupID: 4, parentIDs: []uint{4}, want: true},
+		{name: "Child as parent", groupID: 1, parentIDs: []uint{2}, want: true},
+		{name: "Indirect cycle", groupID: 1, parentIDs: []uint{4, 3}, want: true},
+		{name: "Ancestor as parent", groupID: 3, parentIDs: []uint{1}},
+		{name: "Unrelated parent", groupID: 1, parentIDs: []uint{4}},
+		{name: "No parents", groupID: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := createsCycle(children, tt.groupID, tt.parentIDs); got != tt.want {
+				t.Errorf("createsCycle = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
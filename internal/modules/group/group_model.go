@@ -1,6 +1,7 @@
 package group
 
 import (
+	"errors"
 	"time"
 
 	"apiserver/internal/modules/permission"
@@ -13,22 +14,140 @@ type Group struct {
 	Name        string                  `json:"name" gorm:"uniqueIndex;not null"`
 	Description string                  `json:"description"`
 	Permissions []permission.Permission `json:"permissions" gorm:"many2many:group_permissions;"`
+	Parents     []Group                 `json:"parents,omitempty" gorm:"many2many:group_parents;joinForeignKey:GroupID;joinReferences:ParentID"`
 	CreatedAt   time.Time               `json:"-"`
 	UpdatedAt   time.Time               `json:"-"`
 	DeletedAt   gorm.DeletedAt          `json:"-" gorm:"index"`
 	StatusID    *int16                  `json:"status_id" gorm:"type:smallint;not null;default:1;index"`
+
+	// DeniedPermissions are never granted to members, whatever allows them
+	DeniedPermissions []permission.Permission `json:"denied_permissions,omitempty" gorm:"many2many:group_denied_permissions;joinForeignKey:GroupID;joinReferences:PermissionID"`
+	// InheritedPermissions are granted by ancestor groups (not persisted, see LoadInheritedPermissions)
+	InheritedPermissions []InheritedPermission `json:"inherited_permissions,omitempty" gorm:"-"`
+	// InheritedDenies are deny rules of ancestor groups (not persisted, see LoadInheritedPermissions)
+	InheritedDenies []InheritedPermission `json:"inherited_denied_permissions,omitempty" gorm:"-"`
+}
+
+// InheritedPermission is a permission a group receives from one of its ancestors
+type InheritedPermission struct {
+	permission.Permission
+	InheritedFrom GroupRef `json:"inherited_from"`
+}
+
+// GroupRef identifies a group without its permissions
+type GroupRef struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// GroupParent links a group to a parent group whose permissions it inherits
+type GroupParent struct {
+	GroupID  uint `gorm:"primaryKey"`
+	ParentID uint `gorm:"primaryKey"`
+}
+
+func (GroupParent) TableName() string {
+	return "group_parents"
+}
+
+// Errors returned by Repository.UpdateGroupParents
+var (
+	ErrGroupCycle     = errors.New("group inheritance cycle")
+	ErrParentNotFound = errors.New("parent group not found or inactive")
+)
+
+// Errors returned by Repository.DeleteGroup
+var (
+	ErrGroupHasMembers        = errors.New("group still has members")
+	ErrReassignTargetNotFound = errors.New("reassignment target group not found or inactive")
+)
+
+// EffectivePermissions returns the direct permissions of the group followed by the inherited ones
+func (g *Group) EffectivePermissions() []permission.Permission {
+	permissions := make([]permission.Permission, 0, len(g.Permissions)+len(g.InheritedPermissions))
+	permissions = append(permissions, g.Permissions...)
+	for _, p := range g.InheritedPermissions {
+		permissions = append(permissions, p.Permission)
+	}
+	return permissions
+}
+
+// EffectiveDenies returns the deny rules of the group followed by the inherited ones
+func (g *Group) EffectiveDenies() []permission.Permission {
+	denies := make([]permission.Permission, 0, len(g.DeniedPermissions)+len(g.InheritedDenies))
+	denies = append(denies, g.DeniedPermissions...)
+	for _, p := range g.InheritedDenies {
+		denies = append(denies, p.Permission)
+	}
+	return denies
 }
 
 type CreateGroupRequest struct {
 	Name         string `json:"name" validate:"required"`
 	Description  string `json:"description"`
 	PermissionIDs []uint `json:"permission_ids"`
+	ParentIDs     []uint `json:"parent_ids"` // Groups whose permissions are inherited
 }
 
 type UpdateGroupPermissionsRequest struct {
 	PermissionIDs []uint `json:"permission_ids"`
 }
 
+type UpdateGroupDeniedPermissionsRequest struct {
+	PermissionIDs []uint `json:"permission_ids"`
+}
+
+type UpdateGroupParentsRequest struct {
+	ParentIDs []uint `json:"parent_ids"`
+}
+
+// UpdateGroupRequest renames or re-describes a group; omitted fields are left unchanged
+type UpdateGroupRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// CloneGroupRequest names the copy of a group; the description defaults to the source's
+type CloneGroupRequest struct {
+	Name        string  `json:"name" validate:"required"`
+	Description *string `json:"description"`
+}
+
+// DeleteGroupRequest moves the members of a deleted group to another group
+type DeleteGroupRequest struct {
+	ReassignTo uint `json:"reassign_to" query:"reassign_to"` // Group receiving the members; 0 refuses to delete a group with members
+}
+
 func (Group) TableName() string {
 	return "groups"
+}
+
+// Kinds of group permission versions
+const (
+	VersionInitial   = "initial"    // Permissions the group had before its first recorded change
+	VersionCreate    = "create"     // Permissions the group was created with
+	VersionUpdate    = "update"     // Permissions replaced through the API
+	VersionRollback  = "rollback"   // Permissions restored from an earlier version
+	VersionPolicy    = "policy"     // Permissions set by applying a policy file
+	VersionCLI       = "cli"        // Permissions granted or revoked with the permission manager
+	VersionRouteSync = "route-sync" // Route permissions granted by the route permission policy at startup
+)
+
+// GroupPermissionVersion is a snapshot of the direct permissions of a group after a change
+type GroupPermissionVersion struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	GroupID       uint      `json:"group_id" gorm:"not null;uniqueIndex:idx_group_permission_version"`
+	Version       int       `json:"version" gorm:"not null;uniqueIndex:idx_group_permission_version"`
+	Kind          string    `json:"kind" gorm:"size:16;not null"`                    // initial, create, update, rollback, policy, cli or route-sync
+	RolledBackTo  *int      `json:"rolled_back_to,omitempty"`                        // Version restored by a rollback
+	PermissionIDs []uint    `json:"permission_ids" gorm:"serializer:json;type:text"` // Direct permissions after the change
+	Permissions   []string  `json:"permissions" gorm:"serializer:json;type:text"`    // Their "resource:action" keys
+	Added         []string  `json:"added" gorm:"serializer:json;type:text"`          // Keys granted by the change
+	Removed       []string  `json:"removed" gorm:"serializer:json;type:text"`        // Keys revoked by the change
+	ActorID       *string   `json:"actor_id" gorm:"type:uuid;index"`                 // Access that made the change
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (GroupPermissionVersion) TableName() string {
+	return "group_permission_versions"
 }
\ No newline at end of file
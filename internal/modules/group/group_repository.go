@@ -1,6 +1,8 @@
 package group
 
 import (
+	"errors"
+
 	"apiserver/internal/modules/permission"
 
 	"gorm.io/gorm"
@@ -10,10 +12,20 @@ type Repository interface {
 	CreateGroup(group *Group) error
 	GetAllGroups() ([]Group, error)
 	GetGroupByID(id uint) (*Group, error)
+	GetGroupByName(name string) (*Group, error)
 	GetGroupWithPermissions(id uint) (*Group, error)
 	UpdateGroup(group *Group) error
-	DeleteGroup(id uint) error
-	UpdateGroupPermissions(groupID uint, permissionIDs []uint) error
+	IsNameTaken(name string, exceptID uint) (bool, error)
+	DeleteGroup(id, reassignTo uint) (int64, error)
+	RestoreGroup(id uint) (*Group, error)
+	CountMembers(id uint) (int64, error)
+	UpdateGroupDeniedPermissions(groupID uint, permissionIDs []uint) error
+	UpdateGroupParents(groupID uint, parentIDs []uint) error
+	GetDescendantIDs(groupID uint) ([]uint, error)
+	CloneGroup(sourceID uint, name, description string) (*Group, error)
+	ReplaceGroupPermissions(groupID uint, permissionIDs []uint, change GroupPermissionVersion) (*GroupPermissionVersion, error)
+	GetPermissionHistory(groupID uint) ([]GroupPermissionVersion, error)
+	GetPermissionVersion(groupID uint, version int) (*GroupPermissionVersion, error)
 }
 
 type repository struct {
@@ -30,8 +42,19 @@ func (r *repository) CreateGroup(group *Group) error {
 
 func (r *repository) GetAllGroups() ([]Group, error) {
 	var groups []Group
-	err := r.db.Preload("Permissions", "status_id = ?", 0).Where("status_id = ?", 0).Find(&groups).Error
-	return groups, err
+	err := r.db.Preload("Permissions", "status_id = ?", 0).
+		Preload("DeniedPermissions", "status_id = ?", 0).
+		Preload("Parents", "status_id = ?", 0).
+		Where("status_id = ?", 0).Find(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]*Group, len(groups))
+	for i := range groups {
+		refs[i] = &groups[i]
+	}
+	return groups, LoadInheritedPermissions(r.db, refs...)
 }
 
 func (r *repository) GetGroupByID(id uint) (*Group, error) {
@@ -43,12 +66,28 @@ func (r *repository) GetGroupByID(id uint) (*Group, error) {
 	return &group, nil
 }
 
+func (r *repository) GetGroupByName(name string) (*Group, error) {
+	var group Group
+	err := r.db.Where("name = ? AND status_id = ?", name, 0).First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetGroupWithPermissions returns an active group with its direct, parent and inherited permissions
 func (r *repository) GetGroupWithPermissions(id uint) (*Group, error) {
 	var group Group
-	err := r.db.Preload("Permissions", "status_id = ?", 0).Where("id = ? AND status_id = ?", id, 0).First(&group).Error
+	err := r.db.Preload("Permissions", "status_id = ?", 0).
+		Preload("DeniedPermissions", "status_id = ?", 0).
+		Preload("Parents", "status_id = ?", 0).
+		Where("id = ? AND status_id = ?", id, 0).First(&group).Error
 	if err != nil {
 		return nil, err
 	}
+	if err := LoadInheritedPermissions(r.db, &group); err != nil {
+		return nil, err
+	}
 	return &group, nil
 }
 
@@ -56,29 +95,317 @@ func (r *repository) UpdateGroup(group *Group) error {
 	return r.db.Save(group).Error
 }
 
-func (r *repository) DeleteGroup(id uint) error {
-	return r.db.Model(&Group{}).Where("id = ?", id).Update("status_id", 1).Error
+// IsNameTaken reports whether a group other than exceptID uses the name. Deleted groups keep
+// their name, which stays unique so that they can be restored.
+func (r *repository) IsNameTaken(name string, exceptID uint) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&Group{}).Where("name = ? AND id <> ?", name, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteGroup deactivates an active group and returns the number of its members. Accesses that
+// belong to it, as their primary or an additional group, are moved to the reassignTo group.
+// When reassignTo is 0 a group with members is kept and ErrGroupHasMembers is returned.
+func (r *repository) DeleteGroup(id, reassignTo uint) (int64, error) {
+	var members int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var group Group
+		if err := tx.Where("id = ? AND status_id = ?", id, 0).First(&group).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if members, err = countMembers(tx, id); err != nil {
+			return err
+		}
+		if members > 0 {
+			if reassignTo == 0 {
+				return ErrGroupHasMembers
+			}
+			if err := reassignMembers(tx, id, reassignTo); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&group).Update("status_id", 1).Error
+	})
+	return members, err
+}
+
+// RestoreGroup reactivates a deleted group with the permissions, deny rules and parents it had
+func (r *repository) RestoreGroup(id uint) (*Group, error) {
+	result := r.db.Model(&Group{}).Where("id = ? AND status_id = ?", id, 1).Update("status_id", 0)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.GetGroupWithPermissions(id)
+}
+
+// CountMembers returns the number of accesses whose primary or additional group is the group
+func (r *repository) CountMembers(id uint) (int64, error) {
+	return countMembers(r.db, id)
+}
+
+func countMembers(db *gorm.DB, groupID uint) (int64, error) {
+	var count int64
+	err := db.Table("access").
+		Where("deleted_at IS NULL").
+		Where("(group_id = ? OR id IN (SELECT access_id FROM access_groups WHERE group_id = ?))", groupID, groupID).
+		Count(&count).Error
+	return count, err
 }
 
-func (r *repository) UpdateGroupPermissions(groupID uint, permissionIDs []uint) error {
+// reassignMembers moves the accesses of a group to an active target group. An access that
+// already belongs to the target keeps a single membership.
+func reassignMembers(tx *gorm.DB, fromID, toID uint) error {
+	if fromID == toID {
+		return ErrReassignTargetNotFound
+	}
+	var target Group
+	err := tx.Where("id = ? AND status_id = ?", toID, 0).First(&target).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrReassignTargetNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Exec("UPDATE access SET group_id = ? WHERE group_id = ?", toID, fromID).Error; err != nil {
+		return err
+	}
+	err = tx.Exec(`INSERT INTO access_groups (access_id, group_id)
+		SELECT m.access_id, ? FROM access_groups m
+		WHERE m.group_id = ?
+		AND NOT EXISTS (SELECT 1 FROM access_groups t WHERE t.access_id = m.access_id AND t.group_id = ?)
+		AND NOT EXISTS (SELECT 1 FROM access a WHERE a.id = m.access_id AND a.group_id = ?)`,
+		toID, fromID, toID, toID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec("DELETE FROM access_groups WHERE group_id = ?", fromID).Error
+}
+
+// UpdateGroupDeniedPermissions replaces the deny rules of a group
+func (r *repository) UpdateGroupDeniedPermissions(groupID uint, permissionIDs []uint) error {
 	var group Group
 	if err := r.db.Where("id = ? AND status_id = ?", groupID, 0).First(&group).Error; err != nil {
 		return err
 	}
 
-	// Clear existing permissions
-	if err := r.db.Model(&group).Association("Permissions").Clear(); err != nil {
+	if len(permissionIDs) == 0 {
+		return r.db.Model(&group).Association("DeniedPermissions").Clear()
+	}
+
+	var permissions []permission.Permission
+	if err := r.db.Where("id IN ? AND status_id = ?", permissionIDs, 0).Find(&permissions).Error; err != nil {
 		return err
 	}
+	return r.db.Model(&group).Association("DeniedPermissions").Replace(permissions)
+}
+
+// UpdateGroupParents replaces the parents of a group. It fails with ErrGroupCycle when a
+// parent is the group itself or one of its descendants, and ErrParentNotFound when a parent is not active.
+func (r *repository) UpdateGroupParents(groupID uint, parentIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var group Group
+		if err := tx.Where("id = ? AND status_id = ?", groupID, 0).First(&group).Error; err != nil {
+			return err
+		}
+		if len(parentIDs) == 0 {
+			return tx.Model(&group).Association("Parents").Clear()
+		}
+
+		unique := make(map[uint]bool)
+		for _, id := range parentIDs {
+			unique[id] = true
+		}
+		var parents []Group
+		if err := tx.Where("id IN ? AND status_id = ?", parentIDs, 0).Find(&parents).Error; err != nil {
+			return err
+		}
+		if len(parents) != len(unique) {
+			return ErrParentNotFound
+		}
+
+		// A descendant cannot become a parent
+		edges, err := loadEdges(tx, []uint{groupID}, false)
+		if err != nil {
+			return err
+		}
+		if createsCycle(edges, groupID, parentIDs) {
+			return ErrGroupCycle
+		}
+
+		return tx.Model(&group).Association("Parents").Replace(parents)
+	})
+}
+
+// GetDescendantIDs returns every group that inherits from the group, directly or transitively
+func (r *repository) GetDescendantIDs(groupID uint) ([]uint, error) {
+	edges, err := loadEdges(r.db, []uint{groupID}, false)
+	if err != nil {
+		return nil, err
+	}
+	return reachable(edges, groupID, nil), nil
+}
+
+// CloneGroup creates an active group with the permissions, deny rules and parents of an active source group
+func (r *repository) CloneGroup(sourceID uint, name, description string) (*Group, error) {
+	var clone *Group
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var source Group
+		err := tx.Preload("Permissions", "status_id = ?", 0).
+			Preload("DeniedPermissions", "status_id = ?", 0).
+			Preload("Parents", "status_id = ?", 0).
+			Where("id = ? AND status_id = ?", sourceID, 0).First(&source).Error
+		if err != nil {
+			return err
+		}
+
+		status := int16(0)
+		clone = &Group{Name: name, Description: description, StatusID: &status}
+		if err := tx.Create(clone).Error; err != nil {
+			return err
+		}
+		if len(source.Permissions) > 0 {
+			if err := tx.Model(clone).Association("Permissions").Append(source.Permissions); err != nil {
+				return err
+			}
+		}
+		if len(source.DeniedPermissions) > 0 {
+			if err := tx.Model(clone).Association("DeniedPermissions").Append(source.DeniedPermissions); err != nil {
+				return err
+			}
+		}
+		if len(source.Parents) > 0 {
+			return tx.Model(clone).Association("Parents").Append(source.Parents)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// ReplaceGroupPermissions replaces the direct permissions of an active group with the active ones among
+// permissionIDs and records the result as a new version. Kind, RolledBackTo and ActorID are taken from change.
+// The state before the first recorded change is kept as an initial version so that it can be rolled back to.
+func (r *repository) ReplaceGroupPermissions(groupID uint, permissionIDs []uint, change GroupPermissionVersion) (*GroupPermissionVersion, error) {
+	var version *GroupPermissionVersion
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var group Group
+		if err := tx.Preload("Permissions").Where("id = ? AND status_id = ?", groupID, 0).First(&group).Error; err != nil {
+			return err
+		}
 
-	// Add new permissions if any
-	if len(permissionIDs) > 0 {
 		var permissions []permission.Permission
-		if err := r.db.Where("id IN ? AND status_id = ?", permissionIDs, 0).Find(&permissions).Error; err != nil {
+		if len(permissionIDs) > 0 {
+			if err := tx.Where("id IN ? AND status_id = ?", permissionIDs, 0).Order("id").Find(&permissions).Error; err != nil {
+				return err
+			}
+		}
+
+		var latest GroupPermissionVersion
+		err := tx.Where("group_id = ?", groupID).Order("version DESC").First(&latest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 			return err
 		}
-		return r.db.Model(&group).Association("Permissions").Append(permissions)
+
+		if len(permissions) == 0 {
+			if err := tx.Model(&group).Association("Permissions").Clear(); err != nil {
+				return err
+			}
+		} else if err := tx.Model(&group).Association("Permissions").Replace(permissions); err != nil {
+			return err
+		}
+
+		versions := newVersions(latest, groupID, group.Permissions, permissions, change)
+		for i := range versions {
+			if err := tx.Create(&versions[i]).Error; err != nil {
+				return err
+			}
+		}
+		version = &versions[len(versions)-1]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// GetPermissionHistory returns the permission versions of a group, newest first
+func (r *repository) GetPermissionHistory(groupID uint) ([]GroupPermissionVersion, error) {
+	var versions []GroupPermissionVersion
+	err := r.db.Where("group_id = ?", groupID).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+func (r *repository) GetPermissionVersion(groupID uint, version int) (*GroupPermissionVersion, error) {
+	var v GroupPermissionVersion
+	if err := r.db.Where("group_id = ? AND version = ?", groupID, version).First(&v).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// newVersions returns the versions recording that the direct permissions of a group went from before
+// to after, given the latest recorded version (zero when there is none). The first change of a group
+// that had permissions is preceded by an initial version holding them.
+func newVersions(latest GroupPermissionVersion, groupID uint, before, after []permission.Permission, change GroupPermissionVersion) []GroupPermissionVersion {
+	var versions []GroupPermissionVersion
+	if latest.Version == 0 && len(before) > 0 {
+		latest = GroupPermissionVersion{GroupID: groupID, Version: 1, Kind: VersionInitial, Added: permissionKeys(before)}
+		setSnapshot(&latest, before)
+		versions = append(versions, latest)
+	}
+
+	version := GroupPermissionVersion{
+		GroupID:      groupID,
+		Version:      latest.Version + 1,
+		Kind:         change.Kind,
+		RolledBackTo: change.RolledBackTo,
+		ActorID:      change.ActorID,
+		Added:        missingKeys(after, before),
+		Removed:      missingKeys(before, after),
+	}
+	setSnapshot(&version, after)
+	return append(versions, version)
+}
+
+// setSnapshot stores the permissions of a group in a version
+func setSnapshot(version *GroupPermissionVersion, permissions []permission.Permission) {
+	version.PermissionIDs = make([]uint, len(permissions))
+	for i, p := range permissions {
+		version.PermissionIDs[i] = p.ID
+	}
+	version.Permissions = permissionKeys(permissions)
+}
+
+// permissionKeys returns the "resource:action" keys of permissions
+func permissionKeys(permissions []permission.Permission) []string {
+	keys := make([]string, len(permissions))
+	for i, p := range permissions {
+		keys[i] = p.Resource + ":" + p.Action
 	}
+	return keys
+}
 
-	return nil
-}
\ No newline at end of file
+// missingKeys returns the keys of the permissions in want that are not in have
+func missingKeys(want, have []permission.Permission) []string {
+	held := make(map[uint]bool, len(have))
+	for _, p := range have {
+		held[p.ID] = true
+	}
+	keys := make([]string, 0)
+	for _, p := range want {
+		if !held[p.ID] {
+			keys = append(keys, p.Resource+":"+p.Action)
+		}
+	}
+	return keys
+}
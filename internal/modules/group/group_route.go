@@ -23,11 +23,46 @@ func RegisterGroupRoutes(app *fiber.App, handler *Handler, authMiddleware fiber.
 		rateLimitMiddleware,
 		permissionMiddleware("groups", "manage"), 
 		handler.GetGroup)
+	v1.Patch("/groups/:id",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("groups", "manage"),
+		handler.UpdateGroup)
+	v1.Post("/groups/:id/clone",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("groups", "manage"),
+		handler.CloneGroup)
+	v1.Post("/groups/:id/restore",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("groups", "manage"),
+		handler.RestoreGroup)
 	v1.Put("/groups/:id/permissions", 
 		authMiddleware, 
 		rateLimitMiddleware,
 		permissionMiddleware("groups", "manage"), 
 		handler.UpdateGroupPermissions)
+	v1.Put("/groups/:id/denied-permissions",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("groups", "manage"),
+		handler.UpdateGroupDeniedPermissions)
+	v1.Put("/groups/:id/parents",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("groups", "manage"),
+		handler.UpdateGroupParents)
+	v1.Get("/groups/:id/history",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("groups", "manage"),
+		handler.GetGroupHistory)
+	v1.Post("/groups/:id/rollback/:version",
+		authMiddleware,
+		rateLimitMiddleware,
+		permissionMiddleware("groups", "manage"),
+		handler.RollbackGroupPermissions)
 	v1.Delete("/groups/:id", 
 		authMiddleware, 
 		rateLimitMiddleware,
@@ -1,18 +1,23 @@
 package permission
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
+	"apiserver/internal/cache"
+
 	"github.com/gofiber/fiber/v2"
 )
 
 type Handler struct {
-	repo Repository
+	repo        Repository
+	invalidator cache.Invalidator
+	routes      RouteLister
 }
 
-func NewHandler(repo Repository) *Handler {
-	return &Handler{repo: repo}
+func NewHandler(repo Repository, invalidator cache.Invalidator, routes RouteLister) *Handler {
+	return &Handler{repo: repo, invalidator: invalidator, routes: routes}
 }
 
 // CreatePermission godoc
@@ -30,7 +35,7 @@ func NewHandler(repo Repository) *Handler {
 // @Router /v1/permissions [post]
 func (h *Handler) CreatePermission(c *fiber.Ctx) error {
 	var req CreatePermissionRequest
-	
+
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"status":  "error",
@@ -146,16 +151,18 @@ func (h *Handler) GetPermission(c *fiber.Ctx) error {
 
 // DeletePermission godoc
 // @Summary Delete permission
-// @Description Delete a permission by ID
+// @Description Delete a permission by ID. A permission that groups or accesses are granted or denied, that unexpired temporary grants hold or that registered routes require is only deleted with force=true. Deleting it removes every grant, deny rule and temporary grant of it. The response lists what depended on it either way.
 // @Tags Permission
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Permission ID"
-// @Success 200 {object} map[string]string
+// @Param force query bool false "Delete even when groups, accesses or routes depend on the permission"
+// @Success 200 {object} Usage
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} Usage
 // @Failure 500 {object} map[string]string
 // @Router /v1/permissions/{id} [delete]
 func (h *Handler) DeletePermission(c *fiber.Ctx) error {
@@ -168,15 +175,68 @@ func (h *Handler) DeletePermission(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.repo.DeletePermission(uint(id)); err != nil {
+	permission, err := h.repo.GetPermissionByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Permission not found",
+		})
+	}
+
+	usage, err := h.repo.GetUsage(permission.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Failed to check what depends on the permission",
+		})
+	}
+	usage.Routes = h.dependentRoutes(permission)
+	inUse := usage.InUse()
+
+	if inUse && !c.QueryBool("force") {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"status": "error",
+			"message": fmt.Sprintf("Permission '%s:%s' is granted by %d groups and %d accesses, denied by %d groups and %d accesses, held by %d temporary grants and %d routes depend on it; delete it with force=true to remove it from all of them",
+				permission.Resource, permission.Action, len(usage.Groups), len(usage.Accesses), len(usage.DenyingGroups),
+				len(usage.DenyingAccesses), usage.TemporaryGrants, len(usage.Routes)),
+			"data": usage,
+		})
+	}
+
+	// References from deleted groups and accesses are removed too, so restoring them does not bring the permission back
+	removed, err := h.repo.DeletePermissionAndGrants(permission.ID)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Failed to delete permission",
 		})
 	}
+	message := "Permission deleted successfully"
+	if inUse {
+		message = fmt.Sprintf("Permission deleted and %d grants, deny rules and temporary grants of it removed; %d routes depended on it", removed, len(usage.Routes))
+	}
+
+	// Any cached principal may hold the deleted permission
+	h.invalidator.InvalidateAll()
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Permission deleted successfully",
+		"message": message,
+		"data":    usage,
 	})
-}
\ No newline at end of file
+}
+
+// dependentRoutes returns the registered routes whose required permission is granted by p,
+// through an exact match, a wildcard or an implied action
+func (h *Handler) dependentRoutes(p *Permission) []Route {
+	routes := make([]Route, 0)
+	if h.routes == nil {
+		return routes
+	}
+	for _, route := range h.routes.Routes() {
+		if p.Grants(route.Resource, route.Action) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
@@ -0,0 +1,85 @@
+// USAGE
+//   go test ./internal/modules/permission -v -run TestDeletePermission
+
+package permission
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"apiserver/internal/cache"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// stubRepository serves one permission with a fixed usage and records its deletion
+type stubRepository struct {
+	Repository
+	usage   Usage
+	deleted bool
+}
+
+func (r *stubRepository) GetPermissionByID(id uint) (*Permission, error) {
+	if id != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &Permission{ID: 1, Resource: "reports", Action: "read"}, nil
+}
+
+func (r *stubRepository) GetUsage(uint) (*Usage, error) {
+	usage := r.usage
+	return &usage, nil
+}
+
+func (r *stubRepository) DeletePermissionAndGrants(uint) (int64, error) {
+	r.deleted = true
+	return 1, nil
+}
+
+type stubRoutes []Route
+
+func (r stubRoutes) Routes() []Route { return r }
+
+func TestDeletePermission(t *testing.T) {
+	tests := []struct {
+		name        string
+		usage       Usage
+		routes      stubRoutes
+		url         string
+		wantStatus  int
+		wantDeleted bool
+	}{
+		{name: "Unused permission", url: "/v1/permissions/1", wantStatus: fiber.StatusOK, wantDeleted: true},
+		{name: "Missing permission", url: "/v1/permissions/2", wantStatus: fiber.StatusNotFound},
+		{name: "Granted by a group", usage: Usage{Groups: []GrantingGroup{{ID: 2, Name: "Editor"}}}, url: "/v1/permissions/1", wantStatus: fiber.StatusConflict},
+		{name: "Granted to an access", usage: Usage{Accesses: []GrantedAccess{{ID: "a", Email: "a@example.com"}}}, url: "/v1/permissions/1", wantStatus: fiber.StatusConflict},
+		{name: "Denied by a group", usage: Usage{DenyingGroups: []GrantingGroup{{ID: 3, Name: "Contractors"}}}, url: "/v1/permissions/1", wantStatus: fiber.StatusConflict},
+		{name: "Denied to an access", usage: Usage{DenyingAccesses: []GrantedAccess{{ID: "a", Email: "a@example.com"}}}, url: "/v1/permissions/1", wantStatus: fiber.StatusConflict},
+		{name: "Held by a temporary grant", usage: Usage{TemporaryGrants: 1}, url: "/v1/permissions/1", wantStatus: fiber.StatusConflict},
+		{name: "Required by a route", routes: stubRoutes{{Method: "GET", Path: "/v1/reports", Resource: "reports", Action: "read"}}, url: "/v1/permissions/1", wantStatus: fiber.StatusConflict},
+		{name: "Route requiring another permission", routes: stubRoutes{{Method: "DELETE", Path: "/v1/reports/:id", Resource: "reports", Action: "delete"}}, url: "/v1/permissions/1", wantStatus: fiber.StatusOK, wantDeleted: true},
+		{name: "Forced delete of a denied permission", usage: Usage{DenyingGroups: []GrantingGroup{{ID: 3, Name: "Contractors"}}}, url: "/v1/permissions/1?force=true", wantStatus: fiber.StatusOK, wantDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubRepository{usage: tt.usage}
+			handler := NewHandler(repo, cache.NopInvalidator{}, tt.routes)
+
+			app := fiber.New()
+			app.Delete("/v1/permissions/:id", handler.DeletePermission)
+
+			resp, err := app.Test(httptest.NewRequest(fiber.MethodDelete, tt.url, nil))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if repo.deleted != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %v", repo.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
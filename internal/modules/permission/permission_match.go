@@ -0,0 +1,123 @@
+package permission
+
+import "strings"
+
+// Wildcard matches any resource or any action in a granted permission, e.g. "*:*", "examples:*" or "*:read"
+const Wildcard = "*"
+
+// ActionManage is the hierarchical action that implies the CRUD actions on a resource
+const ActionManage = "manage"
+
+// impliedActions lists the actions granted by a higher level action
+var impliedActions = map[string][]string{
+	ActionManage: {"create", "read", "update", "delete"},
+}
+
+// Matches reports whether a granted resource/action pair covers a required one.
+// A wildcard grants every resource or action, and "manage" implies create, read, update and delete.
+func Matches(grantedResource, grantedAction, resource, action string) bool {
+	if grantedResource != Wildcard && grantedResource != resource {
+		return false
+	}
+	if grantedAction == Wildcard || grantedAction == action {
+		return true
+	}
+	for _, implied := range impliedActions[grantedAction] {
+		if implied == action {
+			return true
+		}
+	}
+	return false
+}
+
+// ImpliedActions returns the actions granted by a hierarchical action such as "manage"
+func ImpliedActions(action string) []string {
+	return impliedActions[action]
+}
+
+// Grants reports whether this permission covers resource:action
+func (p Permission) Grants(resource, action string) bool {
+	return Matches(p.Resource, p.Action, resource, action)
+}
+
+// Allows reports whether any of the permissions covers resource:action
+func Allows(permissions []Permission, resource, action string) bool {
+	_, ok := Find(permissions, resource, action)
+	return ok
+}
+
+// Find returns the first of the permissions covering resource:action
+func Find(permissions []Permission, resource, action string) (Permission, bool) {
+	for _, p := range permissions {
+		if p.Grants(resource, action) {
+			return p, true
+		}
+	}
+	return Permission{}, false
+}
+
+// Overlaps reports whether two permissions cover at least one common resource:action pair, whichever
+// is broader: "examples:*" overlaps "examples:delete", "examples:delete" overlaps "examples:manage",
+// and "examples:*" overlaps "*:read"
+func Overlaps(a, b Permission) bool {
+	if a.Resource != Wildcard && b.Resource != Wildcard && a.Resource != b.Resource {
+		return false
+	}
+	if a.Action == Wildcard || b.Action == Wildcard || a.Action == b.Action {
+		return true
+	}
+	return containsAction(impliedActions[a.Action], b.Action) || containsAction(impliedActions[b.Action], a.Action)
+}
+
+// OverlapsAny reports whether p overlaps any of the permissions
+func OverlapsAny(permissions []Permission, p Permission) bool {
+	_, ok := FindOverlapping(permissions, p)
+	return ok
+}
+
+// FindOverlapping returns the first of the permissions overlapping p
+func FindOverlapping(permissions []Permission, p Permission) (Permission, bool) {
+	for _, other := range permissions {
+		if Overlaps(other, p) {
+			return other, true
+		}
+	}
+	return Permission{}, false
+}
+
+// Missing lists the "resource:action" pairs of the target permissions that are not covered by held
+// or that overlap one of denies. An actor can only hand out permissions it is not missing, so a deny
+// on examples:delete blocks granting examples:delete, examples:manage and examples:*.
+func Missing(held, denies, target []Permission) []string {
+	var missing []string
+	for _, p := range target {
+		if !Allows(held, p.Resource, p.Action) || OverlapsAny(denies, p) {
+			missing = append(missing, p.Resource+":"+p.Action)
+		}
+	}
+	return missing
+}
+
+func containsAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitKey splits a "resource:action" pair
+func SplitKey(key string) (string, string, bool) {
+	resource, action, ok := strings.Cut(key, ":")
+	if !ok || resource == "" || action == "" {
+		return "", "", false
+	}
+	return resource, action, true
+}
+
+// KeyMatches is Matches for a granted "resource:action" pair
+func KeyMatches(granted, resource, action string) bool {
+	grantedResource, grantedAction, ok := SplitKey(granted)
+	return ok && Matches(grantedResource, grantedAction, resource, action)
+}
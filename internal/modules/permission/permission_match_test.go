@@ -0,0 +1,75 @@
+// USAGE
+//   go test ./internal/modules/permission -v -run 'TestMatches|TestOverlaps'
+
+package permission
+
+import (
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  string
+		required string
+		expected bool
+	}{
+		{name: "Exact match", granted: "examples:read", required: "examples:read", expected: true},
+		{name: "Different action", granted: "examples:read", required: "examples:delete", expected: false},
+		{name: "Different resource", granted: "examples:read", required: "groups:read", expected: false},
+		{name: "Everything", granted: "*:*", required: "groups:manage", expected: true},
+		{name: "Any action on resource", granted: "examples:*", required: "examples:delete", expected: true},
+		{name: "Any action on other resource", granted: "examples:*", required: "groups:read", expected: false},
+		{name: "Action on any resource", granted: "*:read", required: "audit:read", expected: true},
+		{name: "Other action on any resource", granted: "*:read", required: "audit:manage", expected: false},
+		{name: "Manage implies CRUD", granted: "examples:manage", required: "examples:update", expected: true},
+		{name: "Manage on any resource", granted: "*:manage", required: "groups:create", expected: true},
+		{name: "CRUD does not imply manage", granted: "examples:delete", required: "examples:manage", expected: false},
+		{name: "Manage does not imply other actions", granted: "examples:manage", required: "examples:export", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, action, _ := SplitKey(tt.required)
+			if result := KeyMatches(tt.granted, resource, action); result != tt.expected {
+				t.Errorf("KeyMatches(%q, %q) = %v, want %v", tt.granted, tt.required, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected bool
+	}{
+		{name: "Same permission", a: "examples:delete", b: "examples:delete", expected: true},
+		{name: "Wildcard action covers deny", a: "examples:delete", b: "examples:*", expected: true},
+		{name: "Manage covers deny", a: "examples:delete", b: "examples:manage", expected: true},
+		{name: "Deny covers narrower grant", a: "examples:*", b: "examples:read", expected: true},
+		{name: "Everything", a: "groups:update", b: "*:*", expected: true},
+		{name: "Crossing wildcards", a: "examples:*", b: "*:read", expected: true},
+		{name: "Different action", a: "examples:delete", b: "examples:read", expected: false},
+		{name: "Different resource", a: "examples:*", b: "groups:read", expected: false},
+		{name: "Manage and other action", a: "examples:manage", b: "examples:export", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := permissionOf(tt.a), permissionOf(tt.b)
+			if result := Overlaps(a, b); result != tt.expected {
+				t.Errorf("Overlaps(%q, %q) = %v, want %v", tt.a, tt.b, result, tt.expected)
+			}
+			if result := Overlaps(b, a); result != tt.expected {
+				t.Errorf("Overlaps(%q, %q) = %v, want %v", tt.b, tt.a, result, tt.expected)
+			}
+		})
+	}
+}
+
+func permissionOf(key string) Permission {
+	resource, action, _ := SplitKey(key)
+	return Permission{Resource: resource, Action: action}
+}
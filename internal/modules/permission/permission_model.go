@@ -27,4 +27,50 @@ type CreatePermissionRequest struct {
 
 func (Permission) TableName() string {
 	return "permissions"
+}
+
+// Route is a registered route and the resource/action pair it requires
+type Route struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+// Key returns the "resource:action" form of the permission
+func (r Route) Key() string {
+	return r.Resource + ":" + r.Action
+}
+
+// RouteLister lists the permissions required by registered routes, see middleware.RoutePermissions
+type RouteLister interface {
+	Routes() []Route
+}
+
+// GrantingGroup is an active group that grants or denies a permission directly
+type GrantingGroup struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// GrantedAccess is an active access that is granted or denied a permission directly
+type GrantedAccess struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+// Usage lists what depends on a permission
+type Usage struct {
+	Groups          []GrantingGroup `json:"groups"`           // Groups granting it
+	Accesses        []GrantedAccess `json:"accesses"`         // Accesses granted it directly
+	DenyingGroups   []GrantingGroup `json:"denying_groups"`   // Groups with a deny rule for it
+	DenyingAccesses []GrantedAccess `json:"denying_accesses"` // Accesses with a deny rule for it
+	TemporaryGrants int64           `json:"temporary_grants"` // Unexpired temporary grants of it
+	Routes          []Route         `json:"routes"`           // Routes whose required permission it grants
+}
+
+// InUse reports whether deleting the permission changes what a group, an access or a route allows
+func (u *Usage) InUse() bool {
+	return len(u.Groups) > 0 || len(u.Accesses) > 0 || len(u.DenyingGroups) > 0 ||
+		len(u.DenyingAccesses) > 0 || u.TemporaryGrants > 0 || len(u.Routes) > 0
 }
\ No newline at end of file
@@ -1,6 +1,8 @@
 package permission
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -10,7 +12,10 @@ type Repository interface {
 	GetPermissionByID(id uint) (*Permission, error)
 	UpdatePermission(permission *Permission) error
 	DeletePermission(id uint) error
+	DeletePermissionAndGrants(id uint) (int64, error)
+	GetUsage(id uint) (*Usage, error)
 	GetPermissionsByIDs(ids []uint) ([]Permission, error)
+	GetPermissionByKey(resource, action string) (*Permission, error)
 }
 
 type repository struct {
@@ -48,8 +53,92 @@ func (r *repository) DeletePermission(id uint) error {
 	return r.db.Model(&Permission{}).Where("id = ?", id).Update("status_id", 1).Error
 }
 
+// referenceTables are the tables referring to permissions through a permission_id column
+var referenceTables = []string{
+	"group_permissions",
+	"access_permissions",
+	"group_denied_permissions",
+	"access_denied_permissions",
+	"temporary_grants",
+}
+
+// DeletePermissionAndGrants deactivates a permission and removes every grant, deny rule and temporary
+// grant of it, from active and deleted groups and accesses alike, so that restoring or recreating it
+// later neither grants nor denies it again. It returns the number of references removed.
+func (r *repository) DeletePermissionAndGrants(id uint) (int64, error) {
+	var removed int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range referenceTables {
+			result := tx.Exec("DELETE FROM "+table+" WHERE permission_id = ?", id)
+			if result.Error != nil {
+				return result.Error
+			}
+			removed += result.RowsAffected
+		}
+		return tx.Model(&Permission{}).Where("id = ?", id).Update("status_id", 1).Error
+	})
+	return removed, err
+}
+
+// GetUsage returns the active groups and accesses granting or denying a permission directly and
+// the number of its unexpired temporary grants. Routes are left to the caller.
+func (r *repository) GetUsage(id uint) (*Usage, error) {
+	usage := &Usage{}
+	var err error
+	if usage.Groups, err = r.referencingGroups("group_permissions", id); err != nil {
+		return nil, err
+	}
+	if usage.DenyingGroups, err = r.referencingGroups("group_denied_permissions", id); err != nil {
+		return nil, err
+	}
+	if usage.Accesses, err = r.referencingAccesses("access_permissions", id); err != nil {
+		return nil, err
+	}
+	if usage.DenyingAccesses, err = r.referencingAccesses("access_denied_permissions", id); err != nil {
+		return nil, err
+	}
+	err = r.db.Table("temporary_grants").
+		Where("permission_id = ? AND expires_at > ?", id, time.Now()).
+		Count(&usage.TemporaryGrants).Error
+	return usage, err
+}
+
+// referencingGroups returns the active groups linked to a permission through a join table, by name
+func (r *repository) referencingGroups(table string, id uint) ([]GrantingGroup, error) {
+	groups := make([]GrantingGroup, 0)
+	err := r.db.Table("groups").
+		Select("groups.id, groups.name").
+		Joins("JOIN "+table+" ON "+table+".group_id = groups.id").
+		Where(table+".permission_id = ? AND groups.status_id = ? AND groups.deleted_at IS NULL", id, 0).
+		Order("groups.name").
+		Scan(&groups).Error
+	return groups, err
+}
+
+// referencingAccesses returns the active accesses linked to a permission through a join table, by email
+func (r *repository) referencingAccesses(table string, id uint) ([]GrantedAccess, error) {
+	accesses := make([]GrantedAccess, 0)
+	err := r.db.Table("access").
+		Select("access.id, access.email").
+		Joins("JOIN "+table+" ON "+table+".access_id = access.id").
+		Where(table+".permission_id = ? AND access.status_id = ? AND access.deleted_at IS NULL", id, 0).
+		Order("access.email").
+		Scan(&accesses).Error
+	return accesses, err
+}
+
 func (r *repository) GetPermissionsByIDs(ids []uint) ([]Permission, error) {
 	var permissions []Permission
 	err := r.db.Where("id IN ? AND status_id = ?", ids, 0).Find(&permissions).Error
 	return permissions, err
-}
\ No newline at end of file
+}
+
+// GetPermissionByKey returns the permission for a resource/action pair whatever its status
+func (r *repository) GetPermissionByKey(resource, action string) (*Permission, error) {
+	var permission Permission
+	err := r.db.Where("resource = ? AND action = ?", resource, action).Order("status_id").First(&permission).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
@@ -0,0 +1,165 @@
+// Package policy describes permissions, groups and their grants as a file that can be
+// exported from the database, compared with it and applied to it.
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"apiserver/internal/modules/permission"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Supported file formats
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Policy is the desired RBAC state
+type Policy struct {
+	Permissions []PermissionSpec `json:"permissions" yaml:"permissions"`
+	Groups      []GroupSpec      `json:"groups" yaml:"groups"`
+}
+
+// PermissionSpec describes a permission, identified by its resource and action
+type PermissionSpec struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Resource    string `json:"resource" yaml:"resource"`
+	Action      string `json:"action" yaml:"action"`
+}
+
+// Key returns the "resource:action" form of the permission
+func (p PermissionSpec) Key() string {
+	return p.Resource + ":" + p.Action
+}
+
+// GroupSpec describes a group, identified by its name. Permissions and denied permissions
+// are "resource:action" keys of permissions in the policy, parents are group names.
+type GroupSpec struct {
+	Name              string   `json:"name" yaml:"name"`
+	Description       string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Parents           []string `json:"parents,omitempty" yaml:"parents,omitempty"`
+	Permissions       []string `json:"permissions,omitempty" yaml:"permissions,omitempty"`
+	DeniedPermissions []string `json:"denied_permissions,omitempty" yaml:"denied_permissions,omitempty"`
+}
+
+// FormatOf returns the format of a policy file from its extension, YAML unless it is .json
+func FormatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return FormatJSON
+	}
+	return FormatYAML
+}
+
+// Load reads and validates a policy file
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &Policy{}
+	if FormatOf(path) == FormatJSON {
+		err = json.Unmarshal(data, policy)
+	} else {
+		err = yaml.UnmarshalStrict(data, policy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy in %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid policy in %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// Write encodes the policy in the given format
+func (p *Policy) Write(w io.Writer, format string) error {
+	if format == FormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(p)
+	}
+	return yaml.NewEncoder(w).Encode(p)
+}
+
+// Validate checks that permissions and groups are unique, that groups only refer to
+// permissions and groups of the policy and that parents do not form a cycle
+func (p *Policy) Validate() error {
+	permissions := make(map[string]bool)
+	names := make(map[string]bool)
+	for i, perm := range p.Permissions {
+		if perm.Name == "" {
+			return fmt.Errorf("permission %d needs a name", i)
+		}
+		if _, _, ok := permission.SplitKey(perm.Key()); !ok {
+			return fmt.Errorf("permission %q needs a resource and an action", perm.Name)
+		}
+		if permissions[perm.Key()] {
+			return fmt.Errorf("permission %s is declared twice", perm.Key())
+		}
+		if names[perm.Name] {
+			return fmt.Errorf("permission name %q is used twice", perm.Name)
+		}
+		permissions[perm.Key()] = true
+		names[perm.Name] = true
+	}
+
+	groups := make(map[string]*GroupSpec)
+	for i := range p.Groups {
+		g := &p.Groups[i]
+		if g.Name == "" {
+			return fmt.Errorf("group %d needs a name", i)
+		}
+		if groups[g.Name] != nil {
+			return fmt.Errorf("group %q is declared twice", g.Name)
+		}
+		groups[g.Name] = g
+	}
+
+	for _, g := range p.Groups {
+		for _, key := range append(append([]string(nil), g.Permissions...), g.DeniedPermissions...) {
+			if !permissions[key] {
+				return fmt.Errorf("group %q refers to undeclared permission %s", g.Name, key)
+			}
+		}
+		for _, parent := range g.Parents {
+			if groups[parent] == nil {
+				return fmt.Errorf("group %q extends undeclared group %q", g.Name, parent)
+			}
+		}
+	}
+
+	// Depth-first walk: a group met again while it is still on the path closes a cycle
+	state := make(map[string]int)
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("group %q is part of an inheritance cycle", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		for _, parent := range groups[name].Parents {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		return nil
+	}
+	for _, g := range p.Groups {
+		if err := visit(g.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
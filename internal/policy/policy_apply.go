@@ -0,0 +1,347 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+
+	"gorm.io/gorm"
+)
+
+// Export reads the active permissions and groups from the database
+func Export(db *gorm.DB) (*Policy, error) {
+	permissions, err := permission.NewRepository(db).GetAllPermissions()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := group.NewRepository(db).GetAllGroups()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].ID < permissions[j].ID })
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+
+	policy := &Policy{
+		Permissions: make([]PermissionSpec, 0, len(permissions)),
+		Groups:      make([]GroupSpec, 0, len(groups)),
+	}
+	for _, perm := range permissions {
+		policy.Permissions = append(policy.Permissions, PermissionSpec{
+			Name:        perm.Name,
+			Description: perm.Description,
+			Resource:    perm.Resource,
+			Action:      perm.Action,
+		})
+	}
+	for _, g := range groups {
+		spec := GroupSpec{
+			Name:              g.Name,
+			Description:       g.Description,
+			Permissions:       permissionKeys(g.Permissions),
+			DeniedPermissions: permissionKeys(g.DeniedPermissions),
+		}
+		for _, parent := range g.Parents {
+			spec.Parents = append(spec.Parents, parent.Name)
+		}
+		sort.Strings(spec.Parents)
+		policy.Groups = append(policy.Groups, spec)
+	}
+	return policy, nil
+}
+
+// ErrRequiredByRoutes is returned when prune would delete a permission that registered routes require
+var ErrRequiredByRoutes = errors.New("registered routes require the permission")
+
+// Apply makes the database match the policy in a single transaction and returns the applied plan.
+// Nothing is written when any change fails. Prune keeps the permissions that routes require, a nil
+// routes requires none.
+func Apply(db *gorm.DB, desired *Policy, prune bool, routes permission.RouteLister) (*Plan, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+
+	var plan *Plan
+	err := db.Transaction(func(tx *gorm.DB) error {
+		current, err := Export(tx)
+		if err != nil {
+			return err
+		}
+		plan = Diff(current, desired, prune)
+		if plan.Empty() {
+			return nil
+		}
+
+		a := &applier{
+			tx:            tx,
+			permissions:   permission.NewRepository(tx),
+			groups:        group.NewRepository(tx),
+			routes:        routes,
+			permissionIDs: make(map[string]uint),
+			groupIDs:      make(map[string]uint),
+		}
+		return a.apply(current, desired, prune)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// applier writes a policy through the repositories of one transaction
+type applier struct {
+	tx            *gorm.DB
+	permissions   permission.Repository
+	groups        group.Repository
+	routes        permission.RouteLister
+	permissionIDs map[string]uint
+	groupIDs      map[string]uint
+}
+
+func (a *applier) apply(current, desired *Policy, prune bool) error {
+	for _, spec := range desired.Permissions {
+		if err := a.upsertPermission(spec); err != nil {
+			return fmt.Errorf("permission %s: %w", spec.Key(), err)
+		}
+	}
+	for _, spec := range desired.Groups {
+		if err := a.upsertGroup(spec); err != nil {
+			return fmt.Errorf("group %s: %w", spec.Name, err)
+		}
+	}
+
+	currentGroups := make(map[string]GroupSpec)
+	for _, g := range current.Groups {
+		currentGroups[g.Name] = g
+	}
+	for _, spec := range desired.Groups {
+		if err := a.linkGroup(spec, currentGroups[spec.Name], prune); err != nil {
+			return fmt.Errorf("group %s: %w", spec.Name, err)
+		}
+	}
+
+	if !prune {
+		return nil
+	}
+	return a.prune(current, desired)
+}
+
+// upsertPermission creates the permission, or reactivates and updates the existing one
+func (a *applier) upsertPermission(spec PermissionSpec) error {
+	existing, err := a.permissions.GetPermissionByKey(spec.Resource, spec.Action)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		perm := &permission.Permission{
+			Name:        spec.Name,
+			Description: spec.Description,
+			Resource:    spec.Resource,
+			Action:      spec.Action,
+			StatusID:    int16Ptr(0),
+		}
+		if err := a.permissions.CreatePermission(perm); err != nil {
+			return err
+		}
+		a.permissionIDs[spec.Key()] = perm.ID
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	a.permissionIDs[spec.Key()] = existing.ID
+	if existing.Name == spec.Name && existing.Description == spec.Description && isActive(existing.StatusID) {
+		return nil
+	}
+	existing.Name = spec.Name
+	existing.Description = spec.Description
+	existing.StatusID = int16Ptr(0)
+	return a.permissions.UpdatePermission(existing)
+}
+
+// upsertGroup creates the group, or reactivates and updates the existing one
+func (a *applier) upsertGroup(spec GroupSpec) error {
+	// Inactive groups keep their unique name, so they are looked up whatever their status
+	var existing group.Group
+	err := a.tx.Where("name = ?", spec.Name).Order("status_id").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		g := &group.Group{Name: spec.Name, Description: spec.Description, StatusID: int16Ptr(0)}
+		if err := a.groups.CreateGroup(g); err != nil {
+			return err
+		}
+		a.groupIDs[spec.Name] = g.ID
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	a.groupIDs[spec.Name] = existing.ID
+	if existing.Description == spec.Description && isActive(existing.StatusID) {
+		return nil
+	}
+	existing.Description = spec.Description
+	existing.StatusID = int16Ptr(0)
+	return a.groups.UpdateGroup(&existing)
+}
+
+// linkGroup sets the parents, grants and deny rules of a group. Without prune the
+// current links are kept next to the ones of the policy.
+func (a *applier) linkGroup(spec, current GroupSpec, prune bool) error {
+	parents, permissions, denies := spec.Parents, spec.Permissions, spec.DeniedPermissions
+	if !prune {
+		parents = append(append([]string(nil), current.Parents...), missing(parents, current.Parents)...)
+		permissions = append(append([]string(nil), current.Permissions...), missing(permissions, current.Permissions)...)
+		denies = append(append([]string(nil), current.DeniedPermissions...), missing(denies, current.DeniedPermissions)...)
+	}
+	id := a.groupIDs[spec.Name]
+
+	if !sameSet(parents, current.Parents) {
+		parentIDs := make([]uint, 0, len(parents))
+		for _, name := range parents {
+			parentID, err := a.groupID(name)
+			if err != nil {
+				return err
+			}
+			parentIDs = append(parentIDs, parentID)
+		}
+		if err := a.groups.UpdateGroupParents(id, parentIDs); err != nil {
+			return err
+		}
+	}
+	if !sameSet(permissions, current.Permissions) {
+		ids, err := a.permissionIDsOf(permissions)
+		if err != nil {
+			return err
+		}
+		if _, err := a.groups.ReplaceGroupPermissions(id, ids, group.GroupPermissionVersion{Kind: group.VersionPolicy}); err != nil {
+			return err
+		}
+	}
+	if !sameSet(denies, current.DeniedPermissions) {
+		ids, err := a.permissionIDsOf(denies)
+		if err != nil {
+			return err
+		}
+		if err := a.groups.UpdateGroupDeniedPermissions(id, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune deactivates the groups and permissions that are not in the policy and removes every grant,
+// deny rule and temporary grant of the pruned permissions. A group that accesses still belong to fails
+// the apply with group.ErrGroupHasMembers, a permission that routes require with ErrRequiredByRoutes.
+func (a *applier) prune(current, desired *Policy) error {
+	groups := make(map[string]bool)
+	for _, g := range desired.Groups {
+		groups[g.Name] = true
+	}
+	for _, g := range current.Groups {
+		if groups[g.Name] {
+			continue
+		}
+		id, err := a.groupID(g.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := a.groups.DeleteGroup(id, 0); err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+	}
+
+	permissions := make(map[string]bool)
+	for _, perm := range desired.Permissions {
+		permissions[perm.Key()] = true
+	}
+	for _, perm := range current.Permissions {
+		if permissions[perm.Key()] {
+			continue
+		}
+		if routes := a.requiringRoutes(perm.Key()); len(routes) > 0 {
+			return fmt.Errorf("permission %s: %w: %s", perm.Key(), ErrRequiredByRoutes, strings.Join(routes, ", "))
+		}
+		id, err := a.permissionID(perm.Key())
+		if err != nil {
+			return err
+		}
+		if _, err := a.permissions.DeletePermissionAndGrants(id); err != nil {
+			return fmt.Errorf("permission %s: %w", perm.Key(), err)
+		}
+	}
+	return nil
+}
+
+// requiringRoutes returns the registered routes that require the permission key
+func (a *applier) requiringRoutes(key string) []string {
+	if a.routes == nil {
+		return nil
+	}
+	var routes []string
+	for _, route := range a.routes.Routes() {
+		if route.Key() == key {
+			routes = append(routes, route.Method+" "+route.Path)
+		}
+	}
+	return routes
+}
+
+func (a *applier) groupID(name string) (uint, error) {
+	if id, ok := a.groupIDs[name]; ok {
+		return id, nil
+	}
+	g, err := a.groups.GetGroupByName(name)
+	if err != nil {
+		return 0, fmt.Errorf("group %s: %w", name, err)
+	}
+	a.groupIDs[name] = g.ID
+	return g.ID, nil
+}
+
+func (a *applier) permissionID(key string) (uint, error) {
+	if id, ok := a.permissionIDs[key]; ok {
+		return id, nil
+	}
+	resource, action, _ := permission.SplitKey(key)
+	perm, err := a.permissions.GetPermissionByKey(resource, action)
+	if err != nil {
+		return 0, fmt.Errorf("permission %s: %w", key, err)
+	}
+	a.permissionIDs[key] = perm.ID
+	return perm.ID, nil
+}
+
+func (a *applier) permissionIDsOf(keys []string) ([]uint, error) {
+	ids := make([]uint, 0, len(keys))
+	for _, key := range keys {
+		id, err := a.permissionID(key)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func permissionKeys(permissions []permission.Permission) []string {
+	var keys []string
+	for _, perm := range permissions {
+		keys = append(keys, perm.Resource+":"+perm.Action)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sameSet(a, b []string) bool {
+	return len(missing(a, b)) == 0 && len(missing(b, a)) == 0
+}
+
+func isActive(statusID *int16) bool {
+	return statusID != nil && *statusID == 0
+}
+
+func int16Ptr(v int16) *int16 {
+	return &v
+}
@@ -0,0 +1,175 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Change types
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// Kinds of objects a change applies to
+const (
+	KindPermission = "permission"
+	KindGroup      = "group"
+	KindGrant      = "grant"  // a permission granted to a group
+	KindDeny       = "deny"   // a permission denied to a group
+	KindParent     = "parent" // a group extended by a group
+)
+
+// Change is a single difference between the database and a policy
+type Change struct {
+	Type   string `json:"type"`
+	Kind   string `json:"kind"`
+	Target string `json:"target"`          // Permission key or group name
+	Value  string `json:"value,omitempty"` // Linked permission key or parent name, or the changed fields of an update
+}
+
+// String renders the change as a plan line, e.g. "+ grant Admin examples:read"
+func (c Change) String() string {
+	sign := map[string]string{ChangeCreate: "+", ChangeUpdate: "~", ChangeDelete: "-"}[c.Type]
+	switch {
+	case c.Type == ChangeUpdate:
+		return fmt.Sprintf("%s %s %s (%s)", sign, c.Kind, c.Target, c.Value)
+	case c.Value != "":
+		return fmt.Sprintf("%s %s %s %s", sign, c.Kind, c.Target, c.Value)
+	default:
+		return fmt.Sprintf("%s %s %s", sign, c.Kind, c.Target)
+	}
+}
+
+// Plan lists the changes that make the database match a policy
+type Plan struct {
+	Changes []Change `json:"changes"`
+	Prune   bool     `json:"prune"`
+}
+
+// Empty reports whether the database already matches the policy
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Count returns the number of changes of a type
+func (p *Plan) Count(changeType string) int {
+	count := 0
+	for _, c := range p.Changes {
+		if c.Type == changeType {
+			count++
+		}
+	}
+	return count
+}
+
+// Write prints the plan one change per line followed by a summary
+func (p *Plan) Write(w io.Writer) {
+	if p.Empty() {
+		fmt.Fprintln(w, "No changes. The database matches the policy.")
+		return
+	}
+	for _, c := range p.Changes {
+		fmt.Fprintln(w, c)
+	}
+	fmt.Fprintf(w, "\nPlan: %d to add, %d to change, %d to remove.\n",
+		p.Count(ChangeCreate), p.Count(ChangeUpdate), p.Count(ChangeDelete))
+}
+
+// Diff compares the current state with the desired policy. Without prune, permissions,
+// groups and links that are only in the current state are kept; with prune they are removed.
+func Diff(current, desired *Policy, prune bool) *Plan {
+	plan := &Plan{Prune: prune}
+	add := func(changeType, kind, target, value string) {
+		plan.Changes = append(plan.Changes, Change{Type: changeType, Kind: kind, Target: target, Value: value})
+	}
+
+	currentPermissions := make(map[string]PermissionSpec)
+	for _, perm := range current.Permissions {
+		currentPermissions[perm.Key()] = perm
+	}
+	desiredPermissions := make(map[string]bool)
+	for _, perm := range desired.Permissions {
+		desiredPermissions[perm.Key()] = true
+		existing, ok := currentPermissions[perm.Key()]
+		if !ok {
+			add(ChangeCreate, KindPermission, perm.Key(), "")
+			continue
+		}
+		var fields []string
+		if existing.Name != perm.Name {
+			fields = append(fields, "name")
+		}
+		if existing.Description != perm.Description {
+			fields = append(fields, "description")
+		}
+		if len(fields) > 0 {
+			add(ChangeUpdate, KindPermission, perm.Key(), strings.Join(fields, ", "))
+		}
+	}
+
+	currentGroups := make(map[string]GroupSpec)
+	for _, g := range current.Groups {
+		currentGroups[g.Name] = g
+	}
+	desiredGroups := make(map[string]bool)
+	for _, g := range desired.Groups {
+		desiredGroups[g.Name] = true
+		existing, ok := currentGroups[g.Name]
+		if !ok {
+			add(ChangeCreate, KindGroup, g.Name, "")
+		} else if existing.Description != g.Description {
+			add(ChangeUpdate, KindGroup, g.Name, "description")
+		}
+
+		links := []struct {
+			kind       string
+			have, want []string
+		}{
+			{KindParent, existing.Parents, g.Parents},
+			{KindGrant, existing.Permissions, g.Permissions},
+			{KindDeny, existing.DeniedPermissions, g.DeniedPermissions},
+		}
+		for _, link := range links {
+			for _, value := range missing(link.want, link.have) {
+				add(ChangeCreate, link.kind, g.Name, value)
+			}
+			if prune {
+				for _, value := range missing(link.have, link.want) {
+					add(ChangeDelete, link.kind, g.Name, value)
+				}
+			}
+		}
+	}
+
+	if prune {
+		for _, g := range current.Groups {
+			if !desiredGroups[g.Name] {
+				add(ChangeDelete, KindGroup, g.Name, "")
+			}
+		}
+		for _, perm := range current.Permissions {
+			if !desiredPermissions[perm.Key()] {
+				add(ChangeDelete, KindPermission, perm.Key(), "")
+			}
+		}
+	}
+	return plan
+}
+
+// missing returns the values of want that are not in have
+func missing(want, have []string) []string {
+	present := make(map[string]bool, len(have))
+	for _, value := range have {
+		present[value] = true
+	}
+	var result []string
+	for _, value := range want {
+		if !present[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,130 @@
+// USAGE
+//   go test ./internal/policy -v -run TestDiff
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	current := &Policy{
+		Permissions: []PermissionSpec{
+			{Name: "Read Examples", Resource: "examples", Action: "read"},
+			{Name: "Delete Examples", Resource: "examples", Action: "delete"},
+			{Name: "Legacy", Resource: "legacy", Action: "read"},
+		},
+		Groups: []GroupSpec{
+			{Name: "Viewer", Permissions: []string{"examples:read", "legacy:read"}},
+			{Name: "Admin", Parents: []string{"Viewer"}, Permissions: []string{"examples:delete"}},
+			{Name: "Old"},
+		},
+	}
+	desired := &Policy{
+		Permissions: []PermissionSpec{
+			{Name: "Read Examples", Description: "Read examples", Resource: "examples", Action: "read"},
+			{Name: "Delete Examples", Resource: "examples", Action: "delete"},
+			{Name: "Read Reports", Resource: "reports", Action: "read"},
+		},
+		Groups: []GroupSpec{
+			{Name: "Viewer", Permissions: []string{"examples:read", "reports:read"}},
+			{Name: "Admin", Parents: []string{"Viewer"}, Permissions: []string{"examples:delete"}},
+			{Name: "Auditor", Permissions: []string{"reports:read"}, DeniedPermissions: []string{"examples:delete"}},
+		},
+	}
+
+	t.Run("Additive plan keeps what is not in the policy", func(t *testing.T) {
+		expected := []string{
+			"~ permission examples:read (description)",
+			"+ permission reports:read",
+			"+ grant Viewer reports:read",
+			"+ group Auditor",
+			"+ grant Auditor reports:read",
+			"+ deny Auditor examples:delete",
+		}
+		if got := planLines(Diff(current, desired, false)); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Diff() = %q, want %q", got, expected)
+		}
+	})
+
+	t.Run("Prune removes what is not in the policy", func(t *testing.T) {
+		expected := []string{
+			"~ permission examples:read (description)",
+			"+ permission reports:read",
+			"+ grant Viewer reports:read",
+			"- grant Viewer legacy:read",
+			"+ group Auditor",
+			"+ grant Auditor reports:read",
+			"+ deny Auditor examples:delete",
+			"- group Old",
+			"- permission legacy:read",
+		}
+		if got := planLines(Diff(current, desired, true)); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Diff() = %q, want %q", got, expected)
+		}
+	})
+
+	t.Run("Matching state has no changes", func(t *testing.T) {
+		if plan := Diff(desired, desired, true); !plan.Empty() {
+			t.Errorf("Diff() = %q, want no changes", planLines(plan))
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	permissions := []PermissionSpec{{Name: "Read Examples", Resource: "examples", Action: "read"}}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{
+			name: "Valid policy",
+			policy: Policy{Permissions: permissions, Groups: []GroupSpec{
+				{Name: "Viewer", Permissions: []string{"examples:read"}},
+				{Name: "Editor", Parents: []string{"Viewer"}},
+			}},
+		},
+		{
+			name:    "Duplicate permission",
+			policy:  Policy{Permissions: append(permissions, PermissionSpec{Name: "Other", Resource: "examples", Action: "read"})},
+			wantErr: true,
+		},
+		{
+			name:    "Undeclared permission",
+			policy:  Policy{Permissions: permissions, Groups: []GroupSpec{{Name: "Viewer", Permissions: []string{"reports:read"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "Undeclared parent",
+			policy:  Policy{Groups: []GroupSpec{{Name: "Editor", Parents: []string{"Viewer"}}}},
+			wantErr: true,
+		},
+		{
+			name: "Inheritance cycle",
+			policy: Policy{Groups: []GroupSpec{
+				{Name: "A", Parents: []string{"B"}},
+				{Name: "B", Parents: []string{"A"}},
+			}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func planLines(plan *Plan) []string {
+	var lines []string
+	for _, c := range plan.Changes {
+		lines = append(lines, c.String())
+	}
+	return lines
+}
@@ -1,17 +1,45 @@
 package types
 
-import "apiserver/internal/modules/group"
+import (
+	"errors"
+
+	"apiserver/internal/modules/group"
+	"apiserver/internal/modules/permission"
+)
 
 // User interface untuk menghindari circular dependency
 type User interface {
 	GetID() string
 	GetName() string
 	GetEmail() string
-	GetGroup() *group.Group
+	GetGroup() *group.Group                        // Primary group
+	GetGroups() []group.Group                      // Every group the user belongs to, primary group first
+	GetPermissions() []permission.Permission       // Effective permissions: all groups plus direct grants
+	GetDeniedPermissions() []permission.Permission // Deny rules, which override any permission
 	GetRateLimit() int
+	GetScopes() []string // Scopes of the key used for the request; empty means unrestricted
+	IsIPAllowed(ip string) bool
 }
 
+// Authentication errors returned by AuthRepository.FindByAPIKey
+var (
+	ErrInvalidAPIKey   = errors.New("invalid API key")
+	ErrAccessExpired   = errors.New("access expired")
+	ErrAccessSuspended = errors.New("access suspended")
+	ErrAccessRevoked   = errors.New("access revoked")
+	ErrAccessPending   = errors.New("access pending activation")
+	ErrIPNotAllowed    = errors.New("IP address not allowed")
+	ErrInvalidToken    = errors.New("invalid access token")
+	ErrTokenExpired    = errors.New("access token expired")
+)
+
 // AuthRepository interface untuk menghindari circular dependency
 type AuthRepository interface {
 	FindByAPIKey(apiKey string) (User, error)
-}
\ No newline at end of file
+	FindSigningSecret(accessID string) (User, string, error) // Access and plaintext HMAC signing secret
+}
+
+// TokenVerifier validates self-contained access tokens (JWTs) without a database lookup
+type TokenVerifier interface {
+	VerifyToken(token string) (User, error)
+}
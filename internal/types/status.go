@@ -2,10 +2,10 @@ package types
 
 // Status constants for all entities
 const (
-	StatusActive   int16 = 0 // Active/Default
-	StatusInactive int16 = 1 // Deleted/Inactive
-	StatusPending  int16 = 2 // Pending (for future use)
-	StatusSuspended int16 = 3 // Suspended (for future use)
+	StatusActive    int16 = 0 // Active/Default
+	StatusInactive  int16 = 1 // Deleted/Inactive
+	StatusPending   int16 = 2 // Pending activation
+	StatusSuspended int16 = 3 // Suspended, can be reactivated
 )
 
 // Status descriptions
@@ -32,4 +32,4 @@ func GetStatusDescription(statusID int16) string {
 		return desc
 	}
 	return "Unknown"
-}
\ No newline at end of file
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// APIKeyPrefixLength is the number of leading characters of an API key that
+// are stored in plaintext so a key can be recognised without revealing it
+const APIKeyPrefixLength = 11
+
+// apiKeyPepper is an optional server-side secret mixed into API key digests
+var apiKeyPepper []byte
+
+// SetAPIKeyPepper sets the server-side secret used by HashAPIKey.
+// Changing the pepper invalidates every stored API key digest.
+func SetAPIKeyPepper(pepper string) {
+	apiKeyPepper = []byte(pepper)
+}
+
+// HashAPIKey returns the hex encoded digest of an API key as stored in the database.
+// It uses HMAC-SHA256 with the configured pepper, or plain SHA-256 when no pepper is set.
+func HashAPIKey(apiKey string) string {
+	if len(apiKeyPepper) > 0 {
+		mac := hmac.New(sha256.New, apiKeyPepper)
+		mac.Write([]byte(apiKey))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix returns the short visible prefix of an API key
+func APIKeyPrefix(apiKey string) string {
+	if len(apiKey) <= APIKeyPrefixLength {
+		return apiKey
+	}
+	return apiKey[:APIKeyPrefixLength]
+}
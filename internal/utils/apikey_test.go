@@ -0,0 +1,74 @@
+// USAGE
+//   go test ./internal/utils -v -run TestHashAPIKey
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestHashAPIKey(t *testing.T) {
+	defer SetAPIKeyPepper("")
+
+	t.Run("SHA-256 without pepper", func(t *testing.T) {
+		SetAPIKeyPepper("")
+		// echo -n "test-api-key-123" | sha256sum
+		expected := "a2e4ab0472c808a1ff2ce147ae4f6cd9ecd8bcc8a49c48350f97e6811ace7464"
+		if got := HashAPIKey("test-api-key-123"); got != expected {
+			t.Errorf("HashAPIKey() = %q, want %q", got, expected)
+		}
+	})
+
+	t.Run("Pepper changes the digest", func(t *testing.T) {
+		SetAPIKeyPepper("")
+		plain := HashAPIKey("test-api-key-123")
+
+		SetAPIKeyPepper("server-secret")
+		peppered := HashAPIKey("test-api-key-123")
+		if peppered == plain {
+			t.Errorf("HashAPIKey() with pepper = %q, want a different digest", peppered)
+		}
+		if len(peppered) != 64 {
+			t.Errorf("HashAPIKey() with pepper length = %d, want 64", len(peppered))
+		}
+	})
+
+	t.Run("Different keys produce different digests", func(t *testing.T) {
+		SetAPIKeyPepper("")
+		if HashAPIKey("key-a") == HashAPIKey("key-b") {
+			t.Error("HashAPIKey() returned the same digest for different keys")
+		}
+	})
+}
+
+func TestAPIKeyPrefix(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Generated key",
+			input:    "sk-AbCdEfGhIjKlMnOp",
+			expected: "sk-AbCdEfGh",
+		},
+		{
+			name:     "Short key",
+			input:    "sk-abc",
+			expected: "sk-abc",
+		},
+		{
+			name:     "Empty key",
+			input:    "",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := APIKeyPrefix(tt.input); result != tt.expected {
+				t.Errorf("APIKeyPrefix(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
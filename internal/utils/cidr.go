@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NormalizeCIDR parses a CIDR range or a single IP address and returns it in
+// canonical CIDR form. A single address becomes a /32 (IPv4) or /128 (IPv6) range.
+func NormalizeCIDR(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", fmt.Errorf("invalid IP address or CIDR: %q", value)
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid IP address or CIDR: %q", value)
+	}
+	return network.String(), nil
+}
+
+// IPInCIDRs reports whether ip falls inside any of the given CIDR ranges.
+// Malformed ranges never match.
+func IPInCIDRs(ip string, cidrs []string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
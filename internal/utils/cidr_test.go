@@ -0,0 +1,90 @@
+// USAGE
+//   go test ./internal/utils -v -run TestNormalizeCIDR
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestNormalizeCIDR(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "IPv4 CIDR",
+			input:    "203.0.113.0/24",
+			expected: "203.0.113.0/24",
+		},
+		{
+			name:     "IPv4 CIDR with host bits",
+			input:    "203.0.113.77/24",
+			expected: "203.0.113.0/24",
+		},
+		{
+			name:     "Single IPv4 address",
+			input:    " 198.51.100.7 ",
+			expected: "198.51.100.7/32",
+		},
+		{
+			name:     "Single IPv6 address",
+			input:    "2001:db8::1",
+			expected: "2001:db8::1/128",
+		},
+		{
+			name:    "Invalid address",
+			input:   "not-an-ip",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid prefix length",
+			input:   "10.0.0.0/33",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NormalizeCIDR(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NormalizeCIDR(%q) expected error, got %q", tt.input, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NormalizeCIDR(%q) unexpected error: %v", tt.input, err)
+			}
+			if result != tt.expected {
+				t.Errorf("NormalizeCIDR(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIPInCIDRs(t *testing.T) {
+	cidrs := []string{"10.0.0.0/8", "198.51.100.7/32", "2001:db8::/32"}
+
+	tests := []struct {
+		name     string
+		ip       string
+		expected bool
+	}{
+		{name: "Inside range", ip: "10.20.30.40", expected: true},
+		{name: "Exact address", ip: "198.51.100.7", expected: true},
+		{name: "IPv6 inside range", ip: "2001:db8::5", expected: true},
+		{name: "Outside every range", ip: "192.0.2.1", expected: false},
+		{name: "Invalid IP", ip: "invalid", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := IPInCIDRs(tt.ip, cidrs); result != tt.expected {
+				t.Errorf("IPInCIDRs(%q) = %v, want %v", tt.ip, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocalClientIP is the fiber.Ctx local holding the address resolved by ClientIPResolver
+const LocalClientIP = "client_ip"
+
+// ClientIPResolver finds the address of the client when the API runs behind proxies.
+// Each proxy appends the address it received the request from to X-Forwarded-For, so only
+// the entries added by trusted proxies, on the right of the list, can be believed. Entries
+// further left were sent by the client and may be forged.
+type ClientIPResolver struct {
+	header       string
+	checkTrusted bool
+	trusted      []string
+}
+
+// NewClientIPResolver creates a resolver reading header (empty to use the connection address)
+// from trustedProxies, IPs or CIDR ranges. When checkTrusted is false the header is read from
+// any peer, which is only safe when the API cannot be reached without going through the proxy.
+func NewClientIPResolver(header string, trustedProxies []string, checkTrusted bool) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{header: header, checkTrusted: checkTrusted}
+	for _, proxy := range trustedProxies {
+		cidr, err := NormalizeCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		resolver.trusted = append(resolver.trusted, cidr)
+	}
+	return resolver, nil
+}
+
+// Resolve returns the client address of a request received from remoteIP with the given
+// proxy header value. The header is walked from the right and the first hop that is not a
+// trusted proxy is the client. When every hop is trusted the leftmost one is used.
+func (r *ClientIPResolver) Resolve(remoteIP, headerValue string) string {
+	if r.header == "" || headerValue == "" {
+		return remoteIP
+	}
+	if r.checkTrusted && !IPInCIDRs(remoteIP, r.trusted) {
+		return remoteIP
+	}
+
+	client := remoteIP
+	hops := strings.Split(headerValue, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == "" {
+			// Nothing left of a malformed entry can be trusted
+			break
+		}
+		client = ip
+		if !IPInCIDRs(ip, r.trusted) {
+			break
+		}
+	}
+	return client
+}
+
+// Middleware stores the client address of every request in the LocalClientIP local
+func (r *ClientIPResolver) Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var headerValue string
+		if r.header != "" {
+			headerValue = c.Get(r.header)
+		}
+		c.Locals(LocalClientIP, r.Resolve(c.Context().RemoteIP().String(), headerValue))
+		return c.Next()
+	}
+}
+
+// ClientIP returns the client address resolved by ClientIPResolver, or the connection
+// address when the resolver did not run
+func ClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals(LocalClientIP).(string); ok && ip != "" {
+		return ip
+	}
+	return c.Context().RemoteIP().String()
+}
+
+// parseHop returns the IP of an X-Forwarded-For entry, which may carry a port, or "" when invalid
+func parseHop(hop string) string {
+	hop = strings.TrimSpace(hop)
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	ip := net.ParseIP(strings.Trim(hop, "[]"))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
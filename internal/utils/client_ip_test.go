@@ -0,0 +1,137 @@
+// USAGE
+//   go test ./internal/utils -v -run TestClientIP
+
+package utils
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestClientIPResolve(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "172.16.0.1"}
+
+	tests := []struct {
+		name         string
+		header       string
+		checkTrusted bool
+		remoteIP     string
+		headerValue  string
+		expected     string
+	}{
+		{
+			name:        "No proxy header configured",
+			remoteIP:    "198.51.100.7",
+			headerValue: "203.0.113.9",
+			expected:    "198.51.100.7",
+		},
+		{
+			name:         "Client appended by the proxy",
+			header:       "X-Forwarded-For",
+			checkTrusted: true,
+			remoteIP:     "10.0.0.2",
+			headerValue:  "198.51.100.7",
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "Spoofed entry on the left is ignored",
+			header:       "X-Forwarded-For",
+			checkTrusted: true,
+			remoteIP:     "10.0.0.2",
+			headerValue:  "203.0.113.9, 198.51.100.7",
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "Chain of trusted proxies",
+			header:       "X-Forwarded-For",
+			checkTrusted: true,
+			remoteIP:     "10.0.0.2",
+			headerValue:  "203.0.113.9, 198.51.100.7, 172.16.0.1, 10.0.0.5",
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "Header from an untrusted peer is ignored",
+			header:       "X-Forwarded-For",
+			checkTrusted: true,
+			remoteIP:     "198.51.100.7",
+			headerValue:  "203.0.113.9",
+			expected:     "198.51.100.7",
+		},
+		{
+			name:         "Malformed entry stops the walk",
+			header:       "X-Forwarded-For",
+			checkTrusted: true,
+			remoteIP:     "10.0.0.2",
+			headerValue:  "203.0.113.9, garbage, 10.0.0.5",
+			expected:     "10.0.0.5",
+		},
+		{
+			name:         "Entry with a port",
+			header:       "X-Forwarded-For",
+			checkTrusted: true,
+			remoteIP:     "10.0.0.2",
+			headerValue:  "198.51.100.7:51234",
+			expected:     "198.51.100.7",
+		},
+		{
+			name:        "Without the trusted check the rightmost hop is used",
+			header:      "X-Forwarded-For",
+			remoteIP:    "10.0.0.2",
+			headerValue: "203.0.113.9, 198.51.100.7",
+			expected:    "198.51.100.7",
+		},
+		{
+			name:         "X-Real-IP set by the proxy",
+			header:       "X-Real-IP",
+			checkTrusted: true,
+			remoteIP:     "10.0.0.2",
+			headerValue:  "198.51.100.7",
+			expected:     "198.51.100.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewClientIPResolver(tt.header, proxies, tt.checkTrusted)
+			if err != nil {
+				t.Fatalf("NewClientIPResolver() error = %v", err)
+			}
+			if got := resolver.Resolve(tt.remoteIP, tt.headerValue); got != tt.expected {
+				t.Errorf("Resolve() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	// Requests made with app.Test come from 0.0.0.0, which plays the load balancer here
+	resolver, err := NewClientIPResolver("X-Forwarded-For", []string{"0.0.0.0"}, true)
+	if err != nil {
+		t.Fatalf("NewClientIPResolver() error = %v", err)
+	}
+
+	app := fiber.New(fiber.Config{ProxyHeader: "X-Forwarded-For"})
+	app.Use(resolver.Middleware())
+	app.Get("/ip", func(c *fiber.Ctx) error {
+		return c.SendString(ClientIP(c))
+	})
+
+	// The client claims to be an allowlisted address; the load balancer appends the real one
+	req := httptest.NewRequest(fiber.MethodGet, "/ip", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "198.51.100.7" {
+		t.Errorf("ClientIP() = %q, want the address appended by the proxy", body)
+	}
+
+	if _, err := NewClientIPResolver("X-Forwarded-For", []string{"not-an-ip"}, true); err == nil {
+		t.Error("NewClientIPResolver() accepted an invalid trusted proxy")
+	}
+}
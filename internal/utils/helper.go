@@ -17,6 +17,12 @@ func UintPtr(v uint) *uint {
 	return &v
 }
 
+// EscapeLike escapes the LIKE wildcards % and _, and the backslash escape character itself,
+// so that a user supplied value only matches literally inside a LIKE or ILIKE pattern
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // FormatMessage formats error messages by replacing vendor names and removing URLs
 // while preserving the original message format and case
 func FormatMessage(message string) string {
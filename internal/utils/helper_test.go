@@ -1,5 +1,5 @@
 // USAGE
-//   go test ./internal/utils -v -run TestFormatMessage
+//   go test ./internal/utils -v -run 'TestFormatMessage|TestEscapeLike'
 
 package utils
 
@@ -93,4 +93,25 @@ func TestFormatMessage(t *testing.T) {
 			}
 		})
 	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Plain text", input: "alice@example.com", expected: "alice@example.com"},
+		{name: "Percent", input: "100%", expected: `100\%`},
+		{name: "Underscore", input: "first_last", expected: `first\_last`},
+		{name: "Backslash", input: `a\b`, expected: `a\\b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EscapeLike(tt.input); result != tt.expected {
+				t.Errorf("EscapeLike(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
 }
\ No newline at end of file
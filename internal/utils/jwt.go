@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// JWTAlgorithm is the only signing algorithm issued and accepted (Ed25519)
+const JWTAlgorithm = "EdDSA"
+
+// ErrMalformedJWT is returned for tokens that are not a valid compact JWS
+var ErrMalformedJWT = errors.New("malformed JWT")
+
+// ErrInvalidJWTSignature is returned when a token's signature does not verify
+var ErrInvalidJWTSignature = errors.New("invalid JWT signature")
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// SignJWT encodes claims as a compact JWT signed with an Ed25519 key
+func SignJWT(claims interface{}, keyID string, key ed25519.PrivateKey) (string, error) {
+	header, err := json.Marshal(jwtHeader{Algorithm: JWTAlgorithm, Type: "JWT", KeyID: keyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(key, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// ParseJWT verifies a compact JWT and decodes its claims.
+// publicKey looks up the verification key for the token's key ID.
+// Time based claims such as exp are left to the caller.
+func ParseJWT(token string, publicKey func(keyID string) (ed25519.PublicKey, error), claims interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformedJWT
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrMalformedJWT
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return ErrMalformedJWT
+	}
+	if header.Algorithm != JWTAlgorithm {
+		return ErrInvalidJWTSignature
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrMalformedJWT
+	}
+	key, err := publicKey(header.KeyID)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), signature) {
+		return ErrInvalidJWTSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrMalformedJWT
+	}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return ErrMalformedJWT
+	}
+	return nil
+}
+
+// LooksLikeJWT reports whether a bearer token has the three part shape of a JWT
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
@@ -0,0 +1,80 @@
+// USAGE
+//   go test ./internal/utils -v -run TestParseJWT
+
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testClaims struct {
+	Subject string   `json:"sub"`
+	Scopes  []string `json:"scopes"`
+}
+
+func TestParseJWT(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublic, _, _ := ed25519.GenerateKey(rand.Reader)
+
+	token, err := SignJWT(testClaims{Subject: "access-1", Scopes: []string{"examples:read"}}, "kid-1", private)
+	if err != nil {
+		t.Fatalf("SignJWT() unexpected error: %v", err)
+	}
+
+	lookup := func(key ed25519.PublicKey) func(string) (ed25519.PublicKey, error) {
+		return func(keyID string) (ed25519.PublicKey, error) {
+			if keyID != "kid-1" {
+				return nil, errors.New("unknown key")
+			}
+			return key, nil
+		}
+	}
+
+	t.Run("Valid token", func(t *testing.T) {
+		var claims testClaims
+		if err := ParseJWT(token, lookup(public), &claims); err != nil {
+			t.Fatalf("ParseJWT() unexpected error: %v", err)
+		}
+		if claims.Subject != "access-1" || len(claims.Scopes) != 1 {
+			t.Errorf("ParseJWT() claims = %+v", claims)
+		}
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		var claims testClaims
+		if err := ParseJWT(token, lookup(otherPublic), &claims); !errors.Is(err, ErrInvalidJWTSignature) {
+			t.Errorf("ParseJWT() error = %v, want %v", err, ErrInvalidJWTSignature)
+		}
+	})
+
+	t.Run("Tampered payload", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		forged, _ := SignJWT(testClaims{Subject: "admin"}, "kid-1", private)
+		tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+
+		var claims testClaims
+		if err := ParseJWT(tampered, lookup(public), &claims); !errors.Is(err, ErrInvalidJWTSignature) {
+			t.Errorf("ParseJWT() error = %v, want %v", err, ErrInvalidJWTSignature)
+		}
+	})
+
+	t.Run("Malformed token", func(t *testing.T) {
+		var claims testClaims
+		if err := ParseJWT("sk-not-a-jwt", lookup(public), &claims); !errors.Is(err, ErrMalformedJWT) {
+			t.Errorf("ParseJWT() error = %v, want %v", err, ErrMalformedJWT)
+		}
+	})
+}
+
+func TestLooksLikeJWT(t *testing.T) {
+	if !LooksLikeJWT("aaa.bbb.ccc") {
+		t.Error("LooksLikeJWT() = false for a three part token")
+	}
+	if LooksLikeJWT("sk-AbCdEfGhIjKlMnOp") {
+		t.Error("LooksLikeJWT() = true for an API key")
+	}
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrSecretEncryptionDisabled is returned when no secret encryption key is configured
+var ErrSecretEncryptionDisabled = errors.New("secret encryption key is not configured")
+
+// secretEncryptionKey is the AES-256 key used to store signing secrets
+var secretEncryptionKey []byte
+
+// SetSecretEncryptionKey sets the server-side key used by EncryptSecret and DecryptSecret.
+// An empty key disables secret storage and with it HMAC request signing.
+func SetSecretEncryptionKey(key string) {
+	if key == "" {
+		secretEncryptionKey = nil
+		return
+	}
+	sum := sha256.Sum256([]byte(key))
+	secretEncryptionKey = sum[:]
+}
+
+// SecretEncryptionEnabled reports whether a secret encryption key is configured
+func SecretEncryptionEnabled() bool {
+	return len(secretEncryptionKey) > 0
+}
+
+// GenerateSigningSecret returns a new random secret for HMAC request signing
+func GenerateSigningSecret() string {
+	bytes := make([]byte, 32)
+	rand.Read(bytes)
+	return "ss-" + strings.TrimRight(base64.URLEncoding.EncodeToString(bytes), "=")
+}
+
+// EncryptSecret encrypts a secret with AES-256-GCM for storage in the database
+func EncryptSecret(plain string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret reverses EncryptSecret
+func DecryptSecret(encrypted string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	if !SecretEncryptionEnabled() {
+		return nil, ErrSecretEncryptionDisabled
+	}
+	block, err := aes.NewCipher(secretEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RequestSigningString builds the canonical string a client signs:
+// method, path (with query string), timestamp, nonce and the hex SHA-256 of the body, one per line
+func RequestSigningString(method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// SignRequest returns the hex HMAC-SHA256 signature of a request
+func SignRequest(secret, method, path, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(RequestSigningString(method, path, timestamp, nonce, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRequestSignature checks a hex signature in constant time
+func VerifyRequestSignature(signature, secret, method, path, timestamp, nonce string, body []byte) bool {
+	expected := SignRequest(secret, method, path, timestamp, nonce, body)
+	return hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected))
+}
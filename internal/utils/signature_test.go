@@ -0,0 +1,81 @@
+// USAGE
+//   go test ./internal/utils -v -run TestSignRequest
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestRequestSigningString(t *testing.T) {
+	got := RequestSigningString("post", "/v1/examples?page=1", "1700000000", "abc123", []byte(""))
+	// echo -n "" | sha256sum
+	expected := "POST\n/v1/examples?page=1\n1700000000\nabc123\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got != expected {
+		t.Errorf("RequestSigningString() = %q, want %q", got, expected)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	secret := "ss-secret"
+	body := []byte(`{"name":"test"}`)
+	signature := SignRequest(secret, "POST", "/v1/examples", "1700000000", "nonce-1", body)
+
+	tests := []struct {
+		name     string
+		secret   string
+		method   string
+		path     string
+		nonce    string
+		body     []byte
+		expected bool
+	}{
+		{name: "Valid signature", secret: secret, method: "POST", path: "/v1/examples", nonce: "nonce-1", body: body, expected: true},
+		{name: "Wrong secret", secret: "ss-other", method: "POST", path: "/v1/examples", nonce: "nonce-1", body: body, expected: false},
+		{name: "Tampered method", secret: secret, method: "DELETE", path: "/v1/examples", nonce: "nonce-1", body: body, expected: false},
+		{name: "Tampered path", secret: secret, method: "POST", path: "/v1/groups", nonce: "nonce-1", body: body, expected: false},
+		{name: "Tampered nonce", secret: secret, method: "POST", path: "/v1/examples", nonce: "nonce-2", body: body, expected: false},
+		{name: "Tampered body", secret: secret, method: "POST", path: "/v1/examples", nonce: "nonce-1", body: []byte(`{}`), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VerifyRequestSignature(signature, tt.secret, tt.method, tt.path, "1700000000", tt.nonce, tt.body)
+			if result != tt.expected {
+				t.Errorf("VerifyRequestSignature() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEncryptSecret(t *testing.T) {
+	defer SetSecretEncryptionKey("")
+
+	SetSecretEncryptionKey("")
+	if _, err := EncryptSecret("ss-secret"); err != ErrSecretEncryptionDisabled {
+		t.Errorf("EncryptSecret() without key error = %v, want %v", err, ErrSecretEncryptionDisabled)
+	}
+
+	SetSecretEncryptionKey("server-key")
+	encrypted, err := EncryptSecret("ss-secret")
+	if err != nil {
+		t.Fatalf("EncryptSecret() unexpected error: %v", err)
+	}
+	if encrypted == "ss-secret" {
+		t.Error("EncryptSecret() returned the plaintext")
+	}
+
+	decrypted, err := DecryptSecret(encrypted)
+	if err != nil {
+		t.Fatalf("DecryptSecret() unexpected error: %v", err)
+	}
+	if decrypted != "ss-secret" {
+		t.Errorf("DecryptSecret() = %q, want %q", decrypted, "ss-secret")
+	}
+
+	SetSecretEncryptionKey("other-key")
+	if _, err := DecryptSecret(encrypted); err == nil {
+		t.Error("DecryptSecret() with a different key expected error")
+	}
+}
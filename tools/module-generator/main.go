@@ -465,185 +465,8 @@ const mainUpdateTemplate = `
 {{.LowerModule}}Repo := {{.Package}}.NewRepository(db)
 {{.LowerModule}}Handler := {{.Package}}.NewHandler({{.LowerModule}}Repo)
 
-// Add {{.Package}} Routes
-{{.Package}}.Register{{.Module}}Routes(app, {{.LowerModule}}Handler, authMiddleware, rateLimitMiddleware, middleware.RequirePermission)
-`
-
-const permissionScriptTemplate = `package main
-
-import (
-	"fmt"
-	"log"
-	"time"
-
-	"apiserver/configs"
-	"apiserver/internal/database"
-	"apiserver/internal/modules/group"
-	"apiserver/internal/modules/permission"
-)
-
-// Helper function to create int16 pointer
-func int16Ptr(v int16) *int16 {
-	return &v
-}
-
-func main() {
-	// Load configuration
-	config := configs.LoadConfig()
-
-	// Initialize database
-	database.InitDatabase(config)
-	db := database.GetDB()
-
-	// Create {{.LowerModule}} permissions
-	{{.LowerModule}}Permissions := []permission.Permission{
-		{
-			Name:        "Create {{.ModulePlural}}",
-			Description: "Permission to create new {{.LowerModulePlural}}",
-			Resource:    "{{.LowerModulePlural}}",
-			Action:      "create",
-			StatusID:    int16Ptr(0), // Active
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		},
-		{
-			Name:        "Read {{.ModulePlural}}",
-			Description: "Permission to read {{.LowerModulePlural}}",
-			Resource:    "{{.LowerModulePlural}}",
-			Action:      "read",
-			StatusID:    int16Ptr(0), // Active
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		},
-		{
-			Name:        "Update {{.ModulePlural}}",
-			Description: "Permission to update {{.LowerModulePlural}}",
-			Resource:    "{{.LowerModulePlural}}",
-			Action:      "update",
-			StatusID:    int16Ptr(0), // Active
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		},
-		{
-			Name:        "Delete {{.ModulePlural}}",
-			Description: "Permission to delete {{.LowerModulePlural}}",
-			Resource:    "{{.LowerModulePlural}}",
-			Action:      "delete",
-			StatusID:    int16Ptr(0), // Active
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
-		},
-	}
-
-	// Add permissions to database
-	createdPermissions := make(map[string]uint)
-	for _, p := range {{.LowerModule}}Permissions {
-		var existingPermission permission.Permission
-		result := db.Where("name = ?", p.Name).First(&existingPermission)
-
-		if result.Error != nil {
-			// Permission doesn't exist, create it
-			if err := db.Create(&p).Error; err != nil {
-				log.Printf("Failed to create permission %s: %v", p.Name, err)
-			} else {
-				log.Printf("Created permission: %s with ID: %d", p.Name, p.ID)
-				createdPermissions[p.Action] = p.ID
-			}
-		} else {
-			log.Printf("Permission %s already exists with ID: %d", p.Name, existingPermission.ID)
-			createdPermissions[p.Action] = existingPermission.ID
-		}
-	}
-
-	// Get groups
-	var adminGroup, editorGroup, viewerGroup, generalClientGroup group.Group
-	db.Where("name = ?", "Admin").First(&adminGroup)
-	db.Where("name = ?", "Editor").First(&editorGroup)
-	db.Where("name = ?", "Viewer").First(&viewerGroup)
-	db.Where("name = ?", "General client").First(&generalClientGroup)
-
-	// Assign permissions to groups
-	if adminGroup.ID > 0 {
-		// Admin gets all permissions
-		for _, permID := range createdPermissions {
-			var perm permission.Permission
-			if err := db.First(&perm, permID).Error; err == nil {
-				if err := db.Exec("INSERT INTO group_permissions (group_id, permission_id) VALUES (?, ?) ON CONFLICT DO NOTHING", adminGroup.ID, permID).Error; err != nil {
-					log.Printf("Failed to assign permission %d to Admin group: %v", permID, err)
-				} else {
-					log.Printf("Assigned permission %d to Admin group", permID)
-				}
-			}
-		}
-	}
-
-	if editorGroup.ID > 0 {
-		// Editor gets create, read, update
-		for _, action := range []string{"create", "read", "update"} {
-			if permID, exists := createdPermissions[action]; exists {
-				if err := db.Exec("INSERT INTO group_permissions (group_id, permission_id) VALUES (?, ?) ON CONFLICT DO NOTHING", editorGroup.ID, permID).Error; err != nil {
-					log.Printf("Failed to assign permission %d to Editor group: %v", permID, err)
-				} else {
-					log.Printf("Assigned permission %d to Editor group", permID)
-				}
-			}
-		}
-	}
-
-	if viewerGroup.ID > 0 {
-		// Viewer gets read only
-		if permID, exists := createdPermissions["read"]; exists {
-			if err := db.Exec("INSERT INTO group_permissions (group_id, permission_id) VALUES (?, ?) ON CONFLICT DO NOTHING", viewerGroup.ID, permID).Error; err != nil {
-				log.Printf("Failed to assign permission %d to Viewer group: %v", permID, err)
-			} else {
-				log.Printf("Assigned permission %d to Viewer group", permID)
-			}
-		}
-	}
-
-	if generalClientGroup.ID > 0 {
-		// General client gets read only
-		if permID, exists := createdPermissions["read"]; exists {
-			if err := db.Exec("INSERT INTO group_permissions (group_id, permission_id) VALUES (?, ?) ON CONFLICT DO NOTHING", generalClientGroup.ID, permID).Error; err != nil {
-				log.Printf("Failed to assign permission %d to General client group: %v", permID, err)
-			} else {
-				log.Printf("Assigned permission %d to General client group", permID)
-			}
-		}
-	}
-
-	fmt.Println("{{.Module}} permissions have been added and assigned to groups!")
-	fmt.Println("\nPermissions created:")
-	fmt.Println("- Create {{.ModulePlural}} (Admin, Editor)")
-	fmt.Println("- Read {{.ModulePlural}} (Admin, Editor, Viewer, General client)")
-	fmt.Println("- Update {{.ModulePlural}} (Admin, Editor)")
-	fmt.Println("- Delete {{.ModulePlural}} (Admin only)")
-}
-`
-
-const permissionSQLTemplate = `-- Add {{.LowerModule}} permissions
-INSERT INTO permissions (name, description, resource, action, status_id, created_at, updated_at)
-VALUES
-('Create {{.ModulePlural}}', 'Permission to create new {{.LowerModulePlural}}', '{{.LowerModulePlural}}', 'create', 0, NOW(), NOW()),
-('Read {{.ModulePlural}}', 'Permission to read {{.LowerModulePlural}}', '{{.LowerModulePlural}}', 'read', 0, NOW(), NOW()),
-('Update {{.ModulePlural}}', 'Permission to update {{.LowerModulePlural}}', '{{.LowerModulePlural}}', 'update', 0, NOW(), NOW()),
-('Delete {{.ModulePlural}}', 'Permission to delete {{.LowerModulePlural}}', '{{.LowerModulePlural}}', 'delete', 0, NOW(), NOW());
-
--- Assign permissions to Editor group (assuming group_id = 2)
-INSERT INTO group_permissions (group_id, permission_id)
-SELECT 2, id FROM permissions WHERE resource = '{{.LowerModulePlural}}' AND action IN ('create', 'read', 'update');
-
--- Assign read permission to Viewer group (assuming group_id = 3)
-INSERT INTO group_permissions (group_id, permission_id)
-SELECT 3, id FROM permissions WHERE resource = '{{.LowerModulePlural}}' AND action = 'read';
-
--- Assign read permission to General client group (assuming group_id = 4)
-INSERT INTO group_permissions (group_id, permission_id)
-SELECT 4, id FROM permissions WHERE resource = '{{.LowerModulePlural}}' AND action = 'read';
-
--- Assign all permissions to Admin group (assuming group_id = 1)
-INSERT INTO group_permissions (group_id, permission_id)
-SELECT 1, id FROM permissions WHERE resource = '{{.LowerModulePlural}}';
+// Add {{.Package}} Routes under "// Register your module route here", before routePermissions.Seal()
+{{.Package}}.Register{{.Module}}Routes(app, {{.LowerModule}}Handler, authMiddleware, rateLimitMiddleware, requirePermission)
 `
 
 const testHTTPTemplate = `# {{.Module}} API Test File
@@ -807,9 +630,8 @@ func main() {
 	// Create route file
 	createFileFromTemplate(filepath.Join(moduleDir, fmt.Sprintf("%s_route.go", moduleName)), routeTemplate, data)
 
-	// Create permission script if requested
+	// Create test HTTP file if requested
 	if withPermissions {
-		createPermissionScript(moduleName, data)
 		createTestHTTPFile(moduleName, data)
 	}
 
@@ -833,13 +655,12 @@ func main() {
 		os.Exit(1)
 	}
 
-	if withPermissions {
-		fmt.Println("\n🔐 Permission script created!")
-		fmt.Printf("Run the following command to add permissions for %s module:\n", moduleName)
-		fmt.Println("# go run cmd/api/main.go --seed");
-		fmt.Printf("# go run scripts/add-%s-permissions.go\n", moduleName)
-	} else {
-		fmt.Println("\n💡 Tip: Use --with-permissions flag to automatically generate permission script")
+	fmt.Println("\n🔐 Permissions are registered automatically!")
+	fmt.Printf("The %s permissions used by Register%sRoutes are created when the server starts.\n", data.LowerModulePlural, data.Module)
+	fmt.Println("Grant them to groups with ROUTE_PERMISSION_POLICY_FILE (see configs/route-permission-policy.example.json).")
+
+	if !withPermissions {
+		fmt.Println("\n💡 Tip: Use --with-permissions flag to also generate a test HTTP file")
 		fmt.Printf("Example: go run tools/module-generator/main.go %s --with-permissions\n", moduleName)
 	}
 }
@@ -871,24 +692,6 @@ func createFileFromTemplate(filePath, templateContent string, data TemplateData)
 	fmt.Printf("Created %s\n", filePath)
 }
 
-// createPermissionScript creates a permission script for the module
-func createPermissionScript(moduleName string, data TemplateData) {
-	// Create scripts directory if it doesn't exist
-	scriptsDir := "scripts"
-	if err := os.MkdirAll(scriptsDir, 0755); err != nil {
-		fmt.Printf("Error creating scripts directory: %v\n", err)
-		return
-	}
-
-	// Create Go permission script
-	permissionScriptPath := filepath.Join(scriptsDir, fmt.Sprintf("add-%s-permissions.go", moduleName))
-	createFileFromTemplate(permissionScriptPath, permissionScriptTemplate, data)
-
-	// Create SQL permission script
-	sqlScriptPath := filepath.Join(scriptsDir, fmt.Sprintf("add-%s-permissions.sql", moduleName))
-	createFileFromTemplate(sqlScriptPath, permissionSQLTemplate, data)
-}
-
 // createTestHTTPFile creates a test HTTP file for the module
 func createTestHTTPFile(moduleName string, data TemplateData) {
 	// Create test directory if it doesn't exist